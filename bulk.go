@@ -0,0 +1,309 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChunkRows   = 500
+	defaultChunkBytes  = 1 << 20
+	defaultConcurrency = 4
+)
+
+// BulkOption configures BulkInsert.
+type BulkOption func(*bulkOptions)
+
+type bulkOptions struct {
+	chunkRows   int
+	chunkBytes  int
+	concurrency int
+}
+
+// WithChunkRows limits the number of rows in a single INSERT statement, default to 500.
+func WithChunkRows(n int) BulkOption {
+	return func(o *bulkOptions) { o.chunkRows = n }
+}
+
+// WithChunkBytes limits the estimated request size of a single INSERT statement, default to 1 MiB.
+// A chunk always contains at least one row.
+func WithChunkBytes(n int) BulkOption {
+	return func(o *bulkOptions) { o.chunkBytes = n }
+}
+
+// WithConcurrency sets the maximum number of chunks inserted at the same time, default to 4.
+func WithConcurrency(n int) BulkOption {
+	return func(o *bulkOptions) { o.concurrency = n }
+}
+
+// ChunkError is the error inserting a chunk of rows.
+//
+// The failed rows are rows[Offset : Offset+Rows] of the rows passed to BulkInsert.
+type ChunkError struct {
+	Index  int // index of chunk
+	Offset int // index of the first row in chunk
+	Rows   int // number of rows in chunk
+	Err    error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d (rows %d-%d): %s", e.Index, e.Offset, e.Offset+e.Rows-1, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// BulkInsertError reports the chunks failed in BulkInsert, sorted by chunk index.
+// Rows in other chunks are inserted.
+type BulkInsertError struct {
+	Chunks []*ChunkError
+}
+
+func (e *BulkInsertError) Error() string {
+	msgs := make([]string, len(e.Chunks))
+	for i, c := range e.Chunks {
+		msgs[i] = c.Error()
+	}
+	return fmt.Sprintf("bulk insert: %d chunks failed: %s", len(e.Chunks), strings.Join(msgs, "; "))
+}
+
+func (e *BulkInsertError) Unwrap() []error {
+	errs := make([]error, len(e.Chunks))
+	for i, c := range e.Chunks {
+		errs[i] = c
+	}
+	return errs
+}
+
+// BulkInsert inserts rows into table.
+//
+// Rows are split into chunks, each chunk inserted by a multi-row
+// `INSERT INTO table (columns...) VALUES (?, ...), (?, ...)` statement,
+// and chunks are inserted concurrently. If columns is empty, values in row
+// should be in the order of table schema. Nil values, nil pointers and invalid
+// nullable values, e.g. Null[T], are inserted as NULL.
+//
+// A *BulkInsertError returned if any chunk failed, so caller may retry the failed chunks only.
+func BulkInsert(ctx context.Context, db *sql.DB, table string, columns []string, rows [][]any, opts ...BulkOption) error {
+	o := bulkOptions{
+		chunkRows:   defaultChunkRows,
+		chunkBytes:  defaultChunkBytes,
+		concurrency: defaultConcurrency,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.chunkRows <= 0 || o.concurrency <= 0 {
+		return errors.New("bulk insert: chunk rows and concurrency must be positive")
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	width := len(columns)
+	if width == 0 {
+		width = len(rows[0])
+	}
+	for i, row := range rows {
+		if len(row) != width || width == 0 {
+			return fmt.Errorf("bulk insert: row %d has %d values, expect %d", i, len(row), width)
+		}
+	}
+
+	chunks := splitChunks(rows, o.chunkRows, o.chunkBytes)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []*ChunkError
+		sem    = make(chan struct{}, o.concurrency)
+	)
+	for i, c := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			// chunks not started yet are failed
+			mu.Lock()
+			for j := i; j < len(chunks); j++ {
+				failed = append(failed, &ChunkError{j, chunks[j].offset, chunks[j].rows, err})
+			}
+			mu.Unlock()
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, c chunk) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			stmt, args := insertStmt(table, columns, rows[c.offset:c.offset+c.rows])
+			if _, err := db.ExecContext(ctx, stmt, args...); err != nil {
+				mu.Lock()
+				failed = append(failed, &ChunkError{i, c.offset, c.rows, err})
+				mu.Unlock()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	if len(failed) == 0 {
+		return nil
+	}
+	slices.SortFunc(failed, func(a, b *ChunkError) int { return a.Index - b.Index })
+	return &BulkInsertError{Chunks: failed}
+}
+
+// BulkInsertStructs inserts items into table by BulkInsert.
+//
+// Columns are the fields of T, named by `openmldb` tag or field name, as in ScanAll.
+func BulkInsertStructs[T any](ctx context.Context, db *sql.DB, table string, items []T, opts ...BulkOption) error {
+	fields, err := structFieldsOf(reflect.TypeFor[T]())
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = f.name
+	}
+
+	rows := make([][]any, len(items))
+	for i := range items {
+		v := reflect.ValueOf(&items[i]).Elem()
+		row := make([]any, len(fields))
+		for j, f := range fields {
+			fv, ok := fieldByIndexNoAlloc(v, f.index)
+			if ok {
+				row[j] = fv.Interface()
+			}
+		}
+		rows[i] = row
+	}
+
+	return BulkInsert(ctx, db, table, columns, rows, opts...)
+}
+
+// fieldByIndexNoAlloc is like reflect.Value.FieldByIndex, reports false
+// if the field is in a nil embedded struct
+func fieldByIndexNoAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+type chunk struct {
+	offset int
+	rows   int
+}
+
+func splitChunks(rows [][]any, maxRows int, maxBytes int) []chunk {
+	var chunks []chunk
+	cur := chunk{}
+	size := 0
+	for i, row := range rows {
+		rowSize := estimateRowSize(row)
+		if cur.rows > 0 && (cur.rows >= maxRows || (maxBytes > 0 && size+rowSize > maxBytes)) {
+			chunks = append(chunks, cur)
+			cur = chunk{offset: i}
+			size = 0
+		}
+		cur.rows++
+		size += rowSize
+	}
+	return append(chunks, cur)
+}
+
+// estimateRowSize estimates the size of a row in request, including the placeholders in SQL
+func estimateRowSize(row []any) int {
+	size := 2
+	for _, v := range row {
+		size += 3
+		switch vv := v.(type) {
+		case string:
+			size += len(vv) + 2
+		case []byte:
+			size += len(vv) + 2
+		case time.Time:
+			size += 13
+		default:
+			size += 8
+		}
+	}
+	return size
+}
+
+// insertStmt returns the INSERT statement of rows and its parameters. NULL values are
+// rendered as NULL literal, as the type of a nil parameter is unknown to api server.
+func insertStmt(table string, columns []string, rows [][]any) (string, []any) {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(QuoteIdentifier(table))
+	if len(columns) > 0 {
+		b.WriteString(" (")
+		for i, c := range columns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(QuoteIdentifier(c))
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(" VALUES ")
+
+	var args []any
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for j, v := range row {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			if isNullValue(v) {
+				b.WriteString("NULL")
+				continue
+			}
+			b.WriteString("?")
+			args = append(args, v)
+		}
+		b.WriteString(")")
+	}
+	return b.String(), args
+}
+
+// isNullValue reports whether v is sent as NULL: nil, a nil pointer, or a driver.Valuer of
+// nil value, e.g. Null[T] not valid
+func isNullValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return true
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		return err == nil && dv == nil
+	}
+	return false
+}
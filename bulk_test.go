@@ -0,0 +1,150 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+func TestInsertStmt(t *testing.T) {
+	stmt, args := insertStmt("demo", []string{"c1", "c2"}, [][]any{{int64(1), "aa"}, {int64(2), "bb"}})
+	assert.Equal(t, "INSERT INTO `demo` (`c1`, `c2`) VALUES (?, ?), (?, ?)", stmt)
+	assert.Equal(t, []any{int64(1), "aa", int64(2), "bb"}, args)
+
+	var s *string
+	stmt, args = insertStmt("demo", nil, [][]any{{nil, s, Null[int32]{}, NullDate{}, Null[int32]{Null: sql.Null[int32]{V: 1, Valid: true}}}})
+	assert.Equal(t, "INSERT INTO `demo` VALUES (NULL, NULL, NULL, NULL, ?)", stmt)
+	assert.Equal(t, []any{Null[int32]{Null: sql.Null[int32]{V: 1, Valid: true}}}, args)
+}
+
+func TestSplitChunks(t *testing.T) {
+	rows := make([][]any, 5)
+	for i := range rows {
+		rows[i] = []any{int64(i), "bb"}
+	}
+
+	assert.Equal(t, []chunk{{0, 2}, {2, 2}, {4, 1}}, splitChunks(rows, 2, 0))
+	assert.Equal(t, []chunk{{0, 5}}, splitChunks(rows, 10, 0))
+
+	size := estimateRowSize(rows[0])
+	assert.Equal(t, []chunk{{0, 3}, {3, 2}}, splitChunks(rows, 10, 3*size))
+	// at least one row in chunk
+	assert.Equal(t, []chunk{{0, 1}, {1, 1}, {2, 1}, {3, 1}, {4, 1}}, splitChunks(rows, 10, 1))
+}
+
+func TestBulkInsert(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		stmts []string
+	)
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		var req queryReq
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Input == nil {
+			// ping
+			w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+			return
+		}
+
+		mu.Lock()
+		stmts = append(stmts, req.SQL)
+		mu.Unlock()
+
+		if slices.Contains(req.Input.Data, any("bad")) {
+			w.Write([]byte(`{"code": -1, "msg": "insert failed"}`))
+			return
+		}
+		w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+	})
+
+	rows := [][]any{
+		{int64(1), "aa"},
+		{int64(2), "bb"},
+		{int64(3), "bad"},
+		{int64(4), "dd"},
+		{int64(5), "ee"},
+	}
+
+	err := BulkInsert(context.Background(), db, "demo", []string{"c1", "c2"}, rows, WithChunkRows(2), WithConcurrency(2))
+
+	var bulkErr *BulkInsertError
+	assert.True(t, errors.As(err, &bulkErr))
+	assert.Len(t, bulkErr.Chunks, 1)
+	assert.Equal(t, 1, bulkErr.Chunks[0].Index)
+	assert.Equal(t, 2, bulkErr.Chunks[0].Offset)
+	assert.Equal(t, 2, bulkErr.Chunks[0].Rows)
+	assert.EqualError(t, bulkErr.Chunks[0].Err, "execute error: insert failed")
+
+	slices.Sort(stmts)
+	assert.Equal(t, []string{
+		"INSERT INTO `demo` (`c1`, `c2`) VALUES (?, ?)",
+		"INSERT INTO `demo` (`c1`, `c2`) VALUES (?, ?), (?, ?)",
+		"INSERT INTO `demo` (`c1`, `c2`) VALUES (?, ?), (?, ?)",
+	}, stmts)
+}
+
+func TestBulkInsertStructs(t *testing.T) {
+	var req queryReq
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		var got queryReq
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got.Input != nil {
+			req = got
+		}
+		w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+	})
+
+	type demo struct {
+		scanBase
+		C2 string `openmldb:"c2"`
+	}
+
+	err := BulkInsertStructs(context.Background(), db, "demo", []demo{{scanBase{1}, "aa"}, {scanBase{2}, "bb"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `demo` (`C1`, `c2`) VALUES (?, ?), (?, ?)", req.SQL)
+	assert.Equal(t, []string{"int64", "string", "int64", "string"}, req.Input.Schema)
+}
+
+func TestBulkInsertNull(t *testing.T) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	db, err := sql.Open("openmldb", srv.DSN("test_db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE demo (c1 int, c2 string, c3 int)")
+	require.NoError(t, err)
+
+	type demo struct {
+		C1 int32       `openmldb:"c1"`
+		C2 *string     `openmldb:"c2"`
+		C3 Null[int32] `openmldb:"c3"`
+	}
+	require.NoError(t, BulkInsertStructs(ctx, db, "demo", []demo{{C1: 1}}))
+	require.NoError(t, BulkInsert(ctx, db, "demo", nil, [][]any{{int32(2), nil, nil}, {int32(3), "cc", int32(3)}}))
+
+	rows, err := db.QueryContext(ctx, "SELECT c1, c2, c3 FROM demo")
+	require.NoError(t, err)
+	got, err := ScanAll[demo](rows)
+	require.NoError(t, err)
+	slices.SortFunc(got, func(a, b demo) int { return int(a.C1 - b.C1) })
+	cc := "cc"
+	assert.Equal(t, []demo{{C1: 1}, {C1: 2}, {C1: 3, C2: &cc, C3: Null[int32]{Null: sql.Null[int32]{V: 3, Valid: true}}}}, got)
+}
+
+func TestBulkInsertInvalidRow(t *testing.T) {
+	db := newTestDB(t, respondWith(`{"code": 0, "msg": "ok"}`))
+
+	err := BulkInsert(context.Background(), db, "demo", []string{"c1", "c2"}, [][]any{{int64(1)}})
+	assert.Error(t, err)
+}
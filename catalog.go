@@ -0,0 +1,250 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// tableDesc is the table returned by table API of api server
+type tableDesc struct {
+	Name         string       `json:"name"`
+	ColumnDesc   []columnDesc `json:"column_desc"`
+	ColumnKey    []columnKey  `json:"column_key"`
+	PartitionNum int          `json:"partition_num"`
+	ReplicaNum   int          `json:"replica_num"`
+	StorageMode  string       `json:"storage_mode"`
+}
+
+type columnKey struct {
+	IndexName string   `json:"index_name"`
+	ColName   []string `json:"col_name"`
+	TSName    string   `json:"ts_name"`
+	TTL       struct {
+		TTLType string `json:"ttl_type"`
+		AbsTTL  int64  `json:"abs_ttl"`
+		LatTTL  int64  `json:"lat_ttl"`
+	} `json:"ttl"`
+}
+
+type tablesResp struct {
+	Code   int         `json:"code"`
+	Msg    string      `json:"msg"`
+	Tables []tableDesc `json:"tables"`
+}
+
+type deploymentsResp struct {
+	Code        int      `json:"code"`
+	Msg         string   `json:"msg"`
+	Deployments []string `json:"deployments"`
+}
+
+type deploymentDescResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data *struct {
+		Name      string `json:"name"`
+		Procedure string `json:"procedure"`
+	} `json:"data"`
+}
+
+type dbsResp struct {
+	Code int      `json:"code"`
+	Msg  string   `json:"msg"`
+	DBs  []string `json:"dbs"`
+}
+
+// TableInfo is the schema of a table.
+type TableInfo struct {
+	Name    string
+	Columns []ColumnInfo
+	Indexes []IndexInfo
+	// PartitionNum and ReplicaNum are the number of partitions and replicas.
+	PartitionNum int
+	ReplicaNum   int
+	// StorageMode is memory, ssd or hdd.
+	StorageMode string
+}
+
+// ColumnInfo is a column of table.
+type ColumnInfo struct {
+	Name string
+	// Type is the type of column in SQL, e.g. int, bigint, string or timestamp.
+	Type    string
+	NotNull bool
+}
+
+// IndexInfo is an index of table.
+type IndexInfo struct {
+	Name string
+	// Keys are the key columns, TS is the ts column, empty if none.
+	Keys []string
+	TS   string
+	// TTLType is absolute, latest, absorlat or absandlat.
+	TTLType string
+	// AbsTTL is the absolute TTL, LatTTL is the latest TTL in number of rows of a key, 0 for no limit.
+	AbsTTL time.Duration
+	LatTTL int64
+}
+
+// DeploymentInfo is a deployment of database.
+type DeploymentInfo struct {
+	Name string
+	// SQL is the deployed query.
+	SQL string
+}
+
+// Databases lists the databases of cluster.
+func Databases(ctx context.Context, db *sql.DB) ([]string, error) {
+	var r dbsResp
+	err := withConn(ctx, db, func(c *conn) error {
+		return c.doJSON(ctx, "GET", "/dbs", nil, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if r.Code != 0 {
+		return nil, fmt.Errorf("list databases error: %s", r.Msg)
+	}
+	return r.DBs, nil
+}
+
+// CurrentDatabase returns the database of DSN, which statements and Describe functions use.
+func CurrentDatabase(ctx context.Context, db *sql.DB) (string, error) {
+	var name string
+	err := withConn(ctx, db, func(c *conn) error {
+		name = c.db
+		return nil
+	})
+	return name, err
+}
+
+// DescribeTables returns the schema of all tables in database.
+func DescribeTables(ctx context.Context, db *sql.DB) ([]TableInfo, error) {
+	var r tablesResp
+	err := withConn(ctx, db, func(c *conn) error {
+		return c.doJSON(ctx, "GET", fmt.Sprintf("/dbs/%s/tables", url.PathEscape(c.db)), nil, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if r.Code != 0 {
+		return nil, fmt.Errorf("list tables error: %s", r.Msg)
+	}
+
+	tables := make([]TableInfo, len(r.Tables))
+	for i, t := range r.Tables {
+		tables[i] = t.info()
+	}
+	return tables, nil
+}
+
+// DescribeDeployments returns all deployments in database.
+func DescribeDeployments(ctx context.Context, db *sql.DB) ([]DeploymentInfo, error) {
+	var deployments []DeploymentInfo
+	err := withConn(ctx, db, func(c *conn) error {
+		var r deploymentsResp
+		if err := c.doJSON(ctx, "GET", fmt.Sprintf("/dbs/%s/deployments", url.PathEscape(c.db)), nil, &r); err != nil {
+			return err
+		}
+		if r.Code != 0 {
+			return fmt.Errorf("list deployments error: %s", r.Msg)
+		}
+
+		for _, name := range r.Deployments {
+			var d deploymentDescResp
+			path := fmt.Sprintf("/dbs/%s/deployments/%s", url.PathEscape(c.db), url.PathEscape(name))
+			if err := c.doJSON(ctx, "GET", path, nil, &d); err != nil {
+				return err
+			}
+			if d.Code != 0 || d.Data == nil {
+				return fmt.Errorf("get deployment error: %s", d.Msg)
+			}
+			deployments = append(deployments, DeploymentInfo{Name: name, SQL: d.Data.Procedure})
+		}
+		return nil
+	})
+	return deployments, err
+}
+
+// withConn runs fn with the openmldb connection of a connection from db
+func withConn(ctx context.Context, db *sql.DB, fn func(c *conn) error) error {
+	dbConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	return dbConn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return fmt.Errorf("not an openmldb connection: %T", driverConn)
+		}
+		return fn(c)
+	})
+}
+
+func (t *tableDesc) info() TableInfo {
+	info := TableInfo{
+		Name:         t.Name,
+		PartitionNum: t.PartitionNum,
+		ReplicaNum:   t.ReplicaNum,
+		StorageMode:  t.StorageMode,
+	}
+	for _, c := range t.ColumnDesc {
+		info.Columns = append(info.Columns, ColumnInfo{Name: c.Name, Type: sqlTypeName(c.DataType), NotNull: c.NotNull})
+	}
+	for _, k := range t.ColumnKey {
+		info.Indexes = append(info.Indexes, IndexInfo{
+			Name:    k.IndexName,
+			Keys:    k.ColName,
+			TS:      k.TSName,
+			TTLType: ttlTypeName(k.TTL.TTLType),
+			AbsTTL:  time.Duration(k.TTL.AbsTTL) * time.Minute,
+			LatTTL:  k.TTL.LatTTL,
+		})
+	}
+	return info
+}
+
+// sqlTypeName returns SQL type of column data type in table API, e.g. kInt32 as int
+func sqlTypeName(dataType string) string {
+	switch dataType {
+	case "kBool":
+		return "bool"
+	case "kSmallInt":
+		return "smallint"
+	case "kInt":
+		return "int"
+	case "kBigInt":
+		return "bigint"
+	case "kFloat":
+		return "float"
+	case "kDouble":
+		return "double"
+	case "kVarchar", "kString":
+		return "string"
+	case "kDate":
+		return "date"
+	case "kTimestamp":
+		return "timestamp"
+	default:
+		return dataType
+	}
+}
+
+// ttlTypeName returns SQL TTL type of ttl type in table API, e.g. kAbsoluteTime as absolute
+func ttlTypeName(ttlType string) string {
+	switch ttlType {
+	case "kLatestTime":
+		return "latest"
+	case "kAbsOrLat":
+		return "absorlat"
+	case "kAbsAndLat":
+		return "absandlat"
+	default:
+		return "absolute"
+	}
+}
@@ -0,0 +1,50 @@
+package openmldb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribe(t *testing.T) {
+	db := openHookedDB(t)
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string NOT NULL, c2 bigint, ts timestamp, "+
+		"INDEX(KEY=(c1, c2), TS=ts, TTL_TYPE=absorlat, TTL=(1d, 10))) OPTIONS (PARTITIONNUM=2)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DEPLOY d1 SELECT c1 FROM t1")
+	require.NoError(t, err)
+
+	dbs, err := Databases(ctx, db)
+	require.NoError(t, err)
+	assert.Contains(t, dbs, "test_db")
+
+	current, err := CurrentDatabase(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, "test_db", current)
+
+	tables, err := DescribeTables(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	assert.Equal(t, TableInfo{
+		Name: "t1",
+		Columns: []ColumnInfo{
+			{Name: "c1", Type: "string", NotNull: true},
+			{Name: "c2", Type: "bigint"},
+			{Name: "ts", Type: "timestamp"},
+		},
+		Indexes: []IndexInfo{
+			{Name: "INDEX_0", Keys: []string{"c1", "c2"}, TS: "ts", TTLType: "absorlat", AbsTTL: 24 * time.Hour, LatTTL: 10},
+		},
+		PartitionNum: 2,
+		ReplicaNum:   1,
+		StorageMode:  "memory",
+	}, tables[0])
+
+	deployments, err := DescribeDeployments(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, []DeploymentInfo{{Name: "d1", SQL: "SELECT c1 FROM t1"}}, deployments)
+}
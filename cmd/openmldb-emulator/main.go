@@ -0,0 +1,25 @@
+// Command openmldb-emulator serves an in-memory OpenMLDB emulator with the HTTP API of api server,
+// so that applications using the driver can run without an OpenMLDB cluster.
+//
+// Usage:
+//
+//	openmldb-emulator [-listen :9527]
+//
+// Then connect with DSN openmldb://127.0.0.1:9527/<db>. Data is lost when it exits.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+func main() {
+	listen := flag.String("listen", ":9527", "address to listen on")
+	flag.Parse()
+
+	log.Printf("openmldb emulator listening on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, emulator.New()))
+}
@@ -0,0 +1,134 @@
+// Command openmldb-migrate runs schema migrations of an OpenMLDB database, see package migrate.
+//
+// Usage:
+//
+//	openmldb-migrate -dsn openmldb://127.0.0.1:9080/db -dir migrations [flags] <command>
+//
+// Commands:
+//
+//	up [version]   apply pending migrations, up to version if given
+//	down [steps]   revert the last steps applied migrations, default to 1
+//	status         list migrations and whether applied
+//	force version  mark migrations up to version applied without executing, after fixing a dirty migration
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/migrate"
+)
+
+func main() {
+	dsn := flag.String("dsn", "", "DSN of database, e.g. openmldb://127.0.0.1:9080/db")
+	dir := flag.String("dir", "migrations", "directory of migration files")
+	table := flag.String("table", "schema_migrations", "table tracking versions")
+	dryRun := flag.Bool("dry-run", false, "print statements instead of executing them")
+	offlineIndex := flag.Bool("offline-index", false, "allow CREATE INDEX on tables with data, loaded from offline data")
+	lockTTL := flag.Duration("lock-ttl", time.Hour, "how long the lock is held if not released")
+	lockSettle := flag.Duration("lock-settle", time.Second, "how long to wait before checking the lock again")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] up [version] | down [steps] | status | force version\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *dsn == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, *dsn, *dir, flag.Args(), []migrate.Option{
+		migrate.WithTable(*table),
+		migrate.WithLockTTL(*lockTTL),
+		migrate.WithLockSettle(*lockSettle),
+	}, *dryRun, *offlineIndex); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dsn string, dir string, args []string, opts []migrate.Option, dryRun bool, offlineIndex bool) error {
+	migrations, err := migrate.Load(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		opts = append(opts, migrate.WithDryRun(os.Stdout))
+	}
+	if offlineIndex {
+		opts = append(opts, migrate.WithOfflineIndex())
+	}
+
+	db, err := sql.Open("openmldb", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := migrate.New(db, migrations, opts...)
+	if err != nil {
+		return err
+	}
+
+	arg := func(def uint64) (uint64, error) {
+		if len(args) < 2 {
+			return def, nil
+		}
+		return strconv.ParseUint(args[1], 10, 64)
+	}
+	switch args[0] {
+	case "up":
+		version, err := arg(^uint64(0))
+		if err != nil {
+			return err
+		}
+		return m.UpTo(ctx, version)
+	case "down":
+		steps, err := arg(1)
+		if err != nil {
+			return err
+		}
+		return m.Down(ctx, int(steps))
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("force: version required")
+		}
+		version, err := arg(0)
+		if err != nil {
+			return err
+		}
+		return m.Force(ctx, version)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAT")
+		for _, st := range statuses {
+			state, at := "pending", ""
+			switch {
+			case st.Dirty:
+				state = "dirty"
+			case st.Applied:
+				state = "applied"
+			}
+			if !st.At.IsZero() {
+				at = st.At.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", st.Version, st.Name, state, at)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
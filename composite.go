@@ -0,0 +1,363 @@
+package openmldb
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scalarTypes are the types of columns and elements of MAP and ARRAY, in lowercase
+var scalarTypes = map[string]bool{
+	"bool":      true,
+	"int16":     true,
+	"int32":     true,
+	"int64":     true,
+	"float":     true,
+	"double":    true,
+	"string":    true,
+	"timestamp": true,
+	"date":      true,
+}
+
+// dataType is a type in schema of api server response, e.g. int32, map<string, int64>
+// or array<array<double>>
+type dataType struct {
+	// name is the scalar type name in lowercase, or map or array
+	name string
+	// key and elem are the key and value types of map, elem is the element type of array
+	key, elem *dataType
+}
+
+// parseDataType parses type in schema, case insensitive.
+func parseDataType(s string) (*dataType, error) {
+	t, rest, ok := parseTypePrefix(s)
+	if !ok || strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("invalid type %s", s)
+	}
+	return t, nil
+}
+
+// parseTypePrefix parses the type at beginning of s, returns the rest of s
+func parseTypePrefix(s string) (t *dataType, rest string, ok bool) {
+	i := strings.IndexAny(s, "<,>")
+	if i < 0 {
+		i = len(s)
+	}
+	name, rest := strings.ToLower(strings.TrimSpace(s[:i])), s[i:]
+	if scalarTypes[name] {
+		return &dataType{name: name}, rest, true
+	}
+	if (name != "map" && name != "array") || !strings.HasPrefix(rest, "<") {
+		return nil, "", false
+	}
+
+	t = &dataType{name: name}
+	rest = rest[1:]
+	if name == "map" {
+		// keys are of scalar types
+		if t.key, rest, ok = parseTypePrefix(rest); !ok || !t.key.scalar() || !strings.HasPrefix(rest, ",") {
+			return nil, "", false
+		}
+		rest = rest[1:]
+	}
+	if t.elem, rest, ok = parseTypePrefix(rest); !ok {
+		return nil, "", false
+	}
+	rest = strings.TrimLeft(rest, " ")
+	if !strings.HasPrefix(rest, ">") {
+		return nil, "", false
+	}
+	return t, rest[1:], true
+}
+
+func (t *dataType) scalar() bool {
+	return t.key == nil && t.elem == nil
+}
+
+// String returns the type in schema of api server request.
+func (t *dataType) String() string {
+	switch t.name {
+	case "map":
+		return fmt.Sprintf("map<%s, %s>", t.key, t.elem)
+	case "array":
+		return fmt.Sprintf("array<%s>", t.elem)
+	default:
+		return t.name
+	}
+}
+
+// goType returns the go type values of t decoded into by tc, e.g. map[string]int64
+func (t *dataType) goType(tc timeCodec) reflect.Type {
+	switch t.name {
+	case "bool":
+		return reflect.TypeFor[bool]()
+	case "int16":
+		return reflect.TypeFor[int16]()
+	case "int32":
+		return reflect.TypeFor[int32]()
+	case "int64":
+		return reflect.TypeFor[int64]()
+	case "float":
+		return reflect.TypeFor[float32]()
+	case "double":
+		return reflect.TypeFor[float64]()
+	case "string":
+		return reflect.TypeFor[string]()
+	case "timestamp":
+		return reflect.TypeOf(tc.timestamp(0))
+	case "date":
+		if tc.raw {
+			return reflect.TypeFor[string]()
+		}
+		return reflect.TypeFor[time.Time]()
+	case "map":
+		return reflect.MapOf(t.key.goType(tc), t.elem.goType(tc))
+	default:
+		return reflect.SliceOf(t.elem.goType(tc))
+	}
+}
+
+// decode converts JSON value v not null into go value of t, MAP as JSON object into
+// map[K]V, and ARRAY into []T. Elements of MAP and ARRAY can't be null.
+func (t *dataType) decode(v any, tc timeCodec) (any, error) {
+	switch t.name {
+	case "bool":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "int16", "int32", "int64", "float", "double", "timestamp":
+		f, ok := v.(float64)
+		if !ok {
+			break
+		}
+		switch t.name {
+		case "int16":
+			return int16(f), nil
+		case "int32":
+			return int32(f), nil
+		case "int64":
+			return int64(f), nil
+		case "float":
+			return float32(f), nil
+		case "double":
+			return f, nil
+		default:
+			// timestamp value returned as int64 millisecond unix epoch time
+			return tc.timestamp(int64(f)), nil
+		}
+	case "string":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "date":
+		if s, ok := v.(string); ok {
+			return tc.date(s)
+		}
+	case "map":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			break
+		}
+		m := reflect.MakeMapWithSize(t.goType(tc), len(obj))
+		for k, e := range obj {
+			key, err := t.key.decodeKey(k, tc)
+			if err != nil {
+				return nil, err
+			}
+			value, err := t.elem.decodeElem(e, tc)
+			if err != nil {
+				return nil, err
+			}
+			m.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+		}
+		return m.Interface(), nil
+	case "array":
+		list, ok := v.([]any)
+		if !ok {
+			break
+		}
+		s := reflect.MakeSlice(t.goType(tc), len(list), len(list))
+		for i, e := range list {
+			value, err := t.elem.decodeElem(e, tc)
+			if err != nil {
+				return nil, err
+			}
+			s.Index(i).Set(reflect.ValueOf(value))
+		}
+		return s.Interface(), nil
+	}
+	return nil, fmt.Errorf("invalid %s value %v", t, v)
+}
+
+func (t *dataType) decodeElem(v any, tc timeCodec) (any, error) {
+	if v == nil {
+		return nil, fmt.Errorf("null element of %s", t)
+	}
+	return t.decode(v, tc)
+}
+
+// decodeKey converts map key in JSON object into go value of t, integers parsed exactly
+func (t *dataType) decodeKey(k string, tc timeCodec) (any, error) {
+	var (
+		v   any
+		n   int64
+		err error
+	)
+	switch t.name {
+	case "bool":
+		v, err = strconv.ParseBool(k)
+	case "int16":
+		n, err = strconv.ParseInt(k, 10, 16)
+		v = int16(n)
+	case "int32":
+		n, err = strconv.ParseInt(k, 10, 32)
+		v = int32(n)
+	case "int64":
+		v, err = strconv.ParseInt(k, 10, 64)
+	case "timestamp":
+		n, err = strconv.ParseInt(k, 10, 64)
+		v = tc.timestamp(n)
+	case "float", "double":
+		var f float64
+		if f, err = strconv.ParseFloat(k, 64); err == nil {
+			return t.decode(f, tc)
+		}
+	default:
+		return t.decode(k, tc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s key %q", t, k)
+	}
+	return v, nil
+}
+
+// compositeType returns the type of go type as MAP or ARRAY, ok is false if it isn't
+// a map or slice of supported types. []byte is not an ARRAY.
+func compositeType(typ reflect.Type) (t *dataType, ok bool) {
+	if typ == nil || typ.Kind() != reflect.Map && (typ.Kind() != reflect.Slice || typ.Elem().Kind() == reflect.Uint8) {
+		return nil, false
+	}
+	return goDataType(typ)
+}
+
+func goDataType(typ reflect.Type) (*dataType, bool) {
+	if typ == reflect.TypeFor[time.Time]() {
+		return &dataType{name: "timestamp"}, true
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &dataType{name: "bool"}, true
+	case reflect.Int16:
+		return &dataType{name: "int16"}, true
+	case reflect.Int32:
+		return &dataType{name: "int32"}, true
+	case reflect.Int, reflect.Int64:
+		return &dataType{name: "int64"}, true
+	case reflect.Float32:
+		return &dataType{name: "float"}, true
+	case reflect.Float64:
+		return &dataType{name: "double"}, true
+	case reflect.String:
+		return &dataType{name: "string"}, true
+	case reflect.Map:
+		key, ok := goDataType(typ.Key())
+		if !ok || !key.scalar() {
+			return nil, false
+		}
+		if elem, ok := goDataType(typ.Elem()); ok {
+			return &dataType{name: "map", key: key, elem: elem}, true
+		}
+	case reflect.Slice:
+		if elem, ok := goDataType(typ.Elem()); ok && typ.Elem().Kind() != reflect.Uint8 {
+			return &dataType{name: "array", elem: elem}, true
+		}
+	}
+	return nil, false
+}
+
+// encode converts go value v of t into JSON value of api server request, the reverse of
+// decode. Nil maps and slices are null.
+func (t *dataType) encode(v reflect.Value) any {
+	switch t.name {
+	case "timestamp":
+		return v.Interface().(time.Time).UnixMilli()
+	case "map":
+		if v.IsNil() {
+			return nil
+		}
+		obj := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			obj[fmt.Sprint(t.key.encode(iter.Key()))] = t.elem.encode(iter.Value())
+		}
+		return obj
+	case "array":
+		if v.IsNil() {
+			return nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = t.elem.encode(v.Index(i))
+		}
+		return list
+	default:
+		return v.Interface()
+	}
+}
+
+// convertComposite sets dst to src of map or slice, converting keys and elements of
+// different types, e.g. map[string]int32 into map[string]int64
+func convertComposite(dst, src reflect.Value) error {
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+
+	switch {
+	case dst.Kind() == reflect.Interface && dst.NumMethod() == 0:
+		dst.Set(src)
+		return nil
+	case dst.Kind() == reflect.Map && src.Kind() == reflect.Map:
+		m := reflect.MakeMapWithSize(dst.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			k, v := reflect.New(dst.Type().Key()).Elem(), reflect.New(dst.Type().Elem()).Elem()
+			if err := convertComposite(k, iter.Key()); err != nil {
+				return err
+			}
+			if err := convertComposite(v, iter.Value()); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+		return nil
+	case dst.Kind() == reflect.Slice && src.Kind() == reflect.Slice:
+		s := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			if err := convertComposite(s.Index(i), src.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+	case src.CanInt() && dst.CanInt():
+		if dst.OverflowInt(src.Int()) {
+			return fmt.Errorf("value %d overflows %s", src.Int(), dst.Type())
+		}
+		dst.SetInt(src.Int())
+		return nil
+	case (src.CanInt() || src.CanFloat()) && dst.CanFloat():
+		f := float64(0)
+		if src.CanInt() {
+			f = float64(src.Int())
+		} else {
+			f = src.Float()
+		}
+		dst.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("unsupported conversion from %s to %s", src.Type(), dst.Type())
+}
@@ -0,0 +1,155 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDataType(t *testing.T) {
+	for s, expect := range map[string]string{
+		"Int32":                             "int32",
+		"MAP<String, Int64>":                "map<string, int64>",
+		" array< double > ":                 "array<double>",
+		"map<int32,array<map<date, bool>>>": "map<int32, array<map<date, bool>>>",
+	} {
+		typ, err := parseDataType(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expect, typ.String())
+	}
+	for s, msg := range map[string]string{
+		"list<int32>":            "invalid type list<int32>",
+		"array<int32":            "invalid type array<int32",
+		"array<int32>>":          "invalid type array<int32>>",
+		"map<int32>":             "invalid type map<int32>",
+		"map<array<int32>, int>": "invalid type map<array<int32>, int>",
+		"map<int32, unknown>":    "invalid type map<int32, unknown>",
+	} {
+		_, err := parseDataType(s)
+		assert.EqualError(t, err, msg, s)
+	}
+}
+
+func TestDecodeComposite(t *testing.T) {
+	r, err := unmarshalQueryResponse(strings.NewReader(`{"code": 0, "msg": "ok", "data": {
+		"schema": ["Map<String, Int32>", "Array<Int64>", "array<map<int16, array<timestamp>>>", "array<date>", "map<bool, string>",
+			"map<int64, string>"],
+		"data": [
+			[{"a": 1, "b": 2}, [1, 2], [{"1": [1000]}, {}], ["2024-01-02"], {"true": "t"}, {"9007199254740993": "x"}],
+			[null, [], null, null, null, null]
+		]
+	}}`))
+	require.NoError(t, err)
+	assert.Equal(t, [][]driver.Value{
+		{map[string]int32{"a": 1, "b": 2}, []int64{1, 2}, []map[int16][]time.Time{{1: {time.UnixMilli(1000)}}, {}},
+			[]time.Time{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}, map[bool]string{true: "t"},
+			map[int64]string{1<<53 + 1: "x"}},
+		{nil, []int64{}, nil, nil, nil, nil},
+	}, r.Data.Data)
+
+	for data, msg := range map[string]string{
+		`[[1, null]]`:         "invalid response: null element of int64 at index 0",
+		`[["a"]]`:             "invalid response: invalid int64 value a at index 0",
+		`[{"a": 1}]`:          "invalid response: invalid array<int64> value map[a:1] at index 0",
+		`[[1], {"x": 1}]`:     "invalid response: invalid int16 key \"x\" at index 1",
+		`[[1], {"40000": 1}]`: "invalid response: invalid int16 key \"40000\" at index 1",
+	} {
+		_, err := unmarshalQueryResponse(strings.NewReader(`{"code": 0, "msg": "ok", "data": {
+			"schema": ["array<int64>", "map<int16, int16>"], "data": [` + data + `]}}`))
+		assert.EqualError(t, err, msg, data)
+	}
+}
+
+func TestMarshalComposite(t *testing.T) {
+	actual, err := marshalQueryRequest("online", "SELECT ?, ?, ?, ?, ?, ?", 0,
+		map[string]int32{"a": 1},
+		[]int64{1, 2},
+		map[int32][]time.Time{2: {time.UnixMilli(1000)}},
+		NullMap[string, float64]{},
+		NullArray[string]{sql.Null[[]string]{V: []string{"x"}, Valid: true}},
+		[]bool(nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"mode": "online",
+		"sql": "SELECT ?, ?, ?, ?, ?, ?",
+		"input": {
+			"schema": ["map<string, int32>", "array<int64>", "map<int32, array<timestamp>>", "map<string, double>",
+				"array<string>", "array<bool>"],
+			"data": [{"a": 1}, [1, 2], {"2": [1000]}, null, ["x"], null]
+		}
+	}`, string(actual))
+
+	_, err = marshalQueryRequest("online", "SELECT ?", 0, []uint{1})
+	assert.EqualError(t, err, "unknown type at index 0")
+	_, err = marshalQueryRequest("online", "SELECT ?", 0, NullArray[uint]{})
+	assert.EqualError(t, err, "unknown type at index 0")
+}
+
+func TestCheckComposite(t *testing.T) {
+	c := &conn{}
+	for _, v := range []any{map[string]int32{"a": 1}, []int64{1}, NullArray[string]{}} {
+		assert.NoError(t, c.CheckNamedValue(&driver.NamedValue{Value: v}), "%T", v)
+	}
+	// converted by Value, not sent as ARRAY
+	assert.Equal(t, driver.ErrSkip, c.CheckNamedValue(&driver.NamedValue{Value: tags{"a", "b"}}))
+	assert.Equal(t, driver.ErrSkip, c.CheckNamedValue(&driver.NamedValue{Value: []uint{1}}))
+}
+
+// tags is a slice sent as string by its Value
+type tags []string
+
+func (t tags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+func TestScanComposite(t *testing.T) {
+	var req queryReq
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte(`{"code": 0, "msg": "ok", "data": {
+			"schema": ["map<string, int32>", "array<int32>", "array<array<int16>>", "array<int32>"],
+			"data": [[{"a": 1}, [1, 2], [[1], [2, 3]], null]]
+		}}`))
+	})
+
+	rows, err := db.QueryContext(context.Background(), "SELECT c1, c2, c3, c4 FROM t1 WHERE c5 = ? AND c6 = ?",
+		map[string]int64{"k": 1}, NullArray[int32]{})
+	require.NoError(t, err)
+	defer rows.Close()
+	assert.Equal(t, []string{"map<string, int64>", "array<int32>"}, req.Input.Schema)
+
+	types, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	assert.Equal(t, "MAP<STRING, INT32>", types[0].DatabaseTypeName())
+	assert.Equal(t, "map[string]int32", types[0].ScanType().String())
+	assert.Equal(t, "[][]int16", types[2].ScanType().String())
+
+	var (
+		m    NullMap[string, int64]
+		a    NullArray[float64]
+		nest NullArray[[]int64]
+		null NullArray[int32]
+	)
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&m, &a, &nest, &null))
+	assert.Equal(t, NullMap[string, int64]{sql.Null[map[string]int64]{V: map[string]int64{"a": 1}, Valid: true}}, m)
+	assert.Equal(t, []float64{1, 2}, a.V)
+	assert.Equal(t, [][]int64{{1}, {2, 3}}, nest.V)
+	assert.False(t, null.Valid)
+
+	var direct map[string]int32
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT c1 FROM t1").Scan(&direct, &a, &nest, &null))
+	assert.Equal(t, map[string]int32{"a": 1}, direct)
+
+	var s NullArray[string]
+	err = db.QueryRowContext(context.Background(), "SELECT c1 FROM t1").Scan(&direct, &s, &nest, &null)
+	assert.ErrorContains(t, err, "unsupported conversion from int32 to string")
+}
@@ -10,8 +10,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"strings"
 	"time"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
 )
 
 // compile time validation that our types implements the expected interfaces
@@ -31,7 +34,11 @@ var (
 	_ driver.ExecerContext  = (*conn)(nil)
 	_ driver.QueryerContext = (*conn)(nil)
 
-	_ driver.Rows = (*respDataRows)(nil)
+	_ driver.NamedValueChecker = (*conn)(nil)
+
+	_ driver.Rows                           = (*respDataRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*respDataRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*respDataRows)(nil)
 )
 
 type queryMode string
@@ -42,6 +49,8 @@ func (m queryMode) String() string {
 		return "offsync"
 	case ModeOffasync:
 		return "offasync"
+	case ModeOnline:
+		return "online"
 	default:
 		return "unknown"
 	}
@@ -64,7 +73,30 @@ type conn struct {
 	host   string // host or host:port
 	db     string // database name
 	mode   queryMode
-	closed bool
+	client *http.Client
+	hooks  []Hook
+	// interpolate is when parameters are rendered into SQL
+	interpolate InterpolateMode
+	times       timeCodec
+	closed      bool
+}
+
+// APIError is an error response of api server, with the error code and message in its body.
+type APIError struct {
+	Op   Op
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	switch e.Op {
+	case OpDeployment:
+		return "call deployment error: " + e.Msg
+	case OpPut:
+		return "put error: " + e.Msg
+	default:
+		return "execute error: " + e.Msg
+	}
 }
 
 type queryResp struct {
@@ -81,6 +113,17 @@ type respData struct {
 type respDataRows struct {
 	respData
 	i int
+
+	// dec is set if rows are decoded from response body on demand,
+	// instead of buffered in respData.Data
+	dec   *json.Decoder
+	body  io.ReadCloser
+	times timeCodec
+
+	// n counts rows read, and err is the error reading them, for onClose
+	n       int
+	err     error
+	onClose func(n int, err error)
 }
 
 // Columns implements driver.Rows.
@@ -89,16 +132,47 @@ type respDataRows struct {
 // columns of the result is inferred from the length of the
 // slice. If a particular column name isn't known, an empty
 // string should be returned for that entry.
-func (r respDataRows) Columns() []string {
+func (r *respDataRows) Columns() []string {
 	// FIXME(someone): current impl returns schema list, not name of columns
 	return make([]string, len(r.Schema))
 }
 
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+//
+// Returns the type name of column in uppercase, e.g. TIMESTAMP or DATE, which are both
+// scanned into time.Time, unless decoded raw by Config.RawTime.
+func (r *respDataRows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.Schema[index])
+}
+
+// ColumnTypeScanType implements driver.RowsColumnTypeScanType.
+//
+// Returns the go type values of column decoded into, e.g. map[string]int64 for MAP<STRING, INT64>
+// and []int32 for ARRAY<INT32>.
+func (r *respDataRows) ColumnTypeScanType(index int) reflect.Type {
+	t, err := parseDataType(r.Schema[index])
+	if err != nil {
+		return reflect.TypeFor[any]()
+	}
+	return t.goType(r.times)
+}
+
 // Close implements driver.Rows.
 //
 // closes the rows iterator.
 func (r *respDataRows) Close() error {
 	r.i = len(r.Data)
+	r.dec = nil
+	if r.onClose != nil {
+		onClose := r.onClose
+		r.onClose = nil
+		defer onClose(r.n, r.err)
+	}
+	if r.body != nil {
+		body := r.body
+		r.body = nil
+		return closeBody(body)
+	}
 	return nil
 }
 
@@ -114,19 +188,55 @@ func (r *respDataRows) Close() error {
 // should be taken when closing Rows not to modify
 // a buffer held in dest.
 func (r *respDataRows) Next(dest []driver.Value) error {
+	if r.dec != nil {
+		row, err := r.nextStreamRow()
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+			}
+			return err
+		}
+		copy(dest, row)
+		r.n++
+		return nil
+	}
+
 	if r.i >= len(r.Data) {
 		return io.EOF
 	}
 
 	copy(dest, r.Data[r.i])
 	r.i++
+	r.n++
 	return nil
 }
 
+func (r *respDataRows) nextStreamRow() ([]driver.Value, error) {
+	if !r.dec.More() {
+		// consume the closing ']' of data array, the rest of response is ignored
+		if _, err := r.dec.Token(); err != nil {
+			return nil, err
+		}
+		r.dec = nil
+		return nil, io.EOF
+	}
+
+	var row []driver.Value
+	if err := r.dec.Decode(&row); err != nil {
+		return nil, err
+	}
+	if err := decodeRow(r.Schema, row, r.times); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
 type queryReq struct {
-	Mode  string      `json:"mode"`
-	SQL   string      `json:"sql"`
-	Input *queryInput `json:"input,omitempty"`
+	Mode string `json:"mode"`
+	SQL  string `json:"sql"`
+	// Timeout is the request timeout of api server in milliseconds, 0 for none
+	Timeout int64       `json:"timeout,omitempty"`
+	Input   *queryInput `json:"input,omitempty"`
 }
 
 type queryInput struct {
@@ -134,10 +244,11 @@ type queryInput struct {
 	Data   []driver.Value `json:"data"`
 }
 
-func marshalQueryRequest(mode string, sqlStr string, input ...driver.Value) ([]byte, error) {
+func marshalQueryRequest(mode string, sqlStr string, timeout int64, input ...driver.Value) ([]byte, error) {
 	req := queryReq{
-		Mode: mode,
-		SQL:  sqlStr,
+		Mode:    mode,
+		SQL:     sqlStr,
+		Timeout: timeout,
 	}
 
 	// TODO(someone): Type infer from input slice does not work always. Consider those cases:
@@ -174,8 +285,24 @@ func marshalQueryRequest(mode string, sqlStr string, input ...driver.Value) ([]b
 				schema[i] = "timestamp"
 			case NullDate:
 				schema[i] = "date"
+			case composite:
+				t, v, valid := vv.composite()
+				if t == nil {
+					return nil, fmt.Errorf("unknown type at index %d", i)
+				}
+				schema[i] = t.String()
+				input[i] = nil
+				if valid {
+					input[i] = t.encode(v)
+				}
 			default:
-				return nil, fmt.Errorf("unknown type at index %d", i)
+				// MAP and ARRAY, e.g. map[string]int64 or []int32
+				t, ok := compositeType(reflect.TypeOf(v))
+				if !ok {
+					return nil, fmt.Errorf("unknown type at index %d", i)
+				}
+				schema[i] = t.String()
+				input[i] = t.encode(reflect.ValueOf(v))
 			}
 		}
 		req.Input = &queryInput{
@@ -188,62 +315,258 @@ func marshalQueryRequest(mode string, sqlStr string, input ...driver.Value) ([]b
 }
 
 func unmarshalQueryResponse(respBody io.Reader) (*queryResp, error) {
-	var r queryResp
-	if err := json.NewDecoder(respBody).Decode(&r); err != nil {
+	r, rows, err := decodeQueryResponse(respBody, timeCodec{})
+	if err != nil {
 		return nil, err
 	}
 
-	if r.Data != nil {
-		// queryResp.Data may nil for DDL
-		for _, row := range r.Data.Data {
-			for i, col := range row {
-				if col == nil {
-					row[i] = nil
-					continue
-				}
+	if rows != nil {
+		// drain rows into memory
+		r.Data.Data = [][]driver.Value{}
+		for {
+			row, err := rows.nextStreamRow()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			r.Data.Data = append(r.Data.Data, row)
+		}
+	}
 
-				switch strings.ToLower(r.Data.Schema[i]) {
-				case "bool":
-					row[i] = col.(bool)
-				case "int16":
-					row[i] = int16(col.(float64))
-				case "int32":
-					row[i] = int32(col.(float64))
-				case "int64":
-					row[i] = int64(col.(float64))
-				case "float":
-					row[i] = float32(col.(float64))
-				case "double":
-					row[i] = float64(col.(float64))
-				case "string":
-					row[i] = col.(string)
-				// date and timestamp values saved internally as time.Time
-				case "timestamp":
-					// timestamp value returned as int64 millisecond unix epoch time
-					row[i] = time.UnixMilli(int64(col.(float64)))
-				case "date":
-					t, err := parseDateStr(col.(string))
-					if err != nil {
-						row[i] = nil
-					}
+	return r, nil
+}
+
+// decodeQueryResponse decodes response of query API incrementally.
+//
+// Api server writes code and msg ahead of data, so once the data rows reached, they are
+// left in respBody and returned as rows to be decoded on demand, so memory usage is bounded
+// regardless of result size. In case the data comes first, the whole response is decoded
+// into memory and rows is nil. Timestamps and dates are decoded by tc.
+func decodeQueryResponse(respBody io.Reader, tc timeCodec) (r *queryResp, rows *respDataRows, err error) {
+	r = &queryResp{}
+	dec := json.NewDecoder(respBody)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, nil, err
+	}
+
+	codeSeen := false
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
 
-					row[i] = t
-				default:
-					return nil, fmt.Errorf("unknown type %s at index %d", r.Data.Schema[i], i)
+		switch key {
+		case "code":
+			codeSeen = true
+			err = dec.Decode(&r.Code)
+		case "msg":
+			err = dec.Decode(&r.Msg)
+		case "data":
+			if !codeSeen || r.Code != 0 {
+				// queryResp.Data may nil for DDL
+				if err := dec.Decode(&r.Data); err != nil {
+					return nil, nil, err
 				}
+				if r.Data != nil {
+					for _, row := range r.Data.Data {
+						if err := decodeRow(r.Data.Schema, row, tc); err != nil {
+							return nil, nil, err
+						}
+					}
+				}
+				continue
+			}
+
+			r.Data, rows, err = decodeRespData(dec, tc)
+			if err != nil {
+				return nil, nil, err
+			}
+			if rows != nil {
+				return r, rows, nil
+			}
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return r, nil, nil
+}
+
+// decodeRespData decodes the data object in query response, up to the beginning of data rows
+func decodeRespData(dec *json.Decoder, tc timeCodec) (*respData, *respDataRows, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if tok == nil {
+		return nil, nil, nil
+	} else if tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("invalid response: unexpected %v, expect object", tok)
+	}
+
+	d := &respData{}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch key {
+		case "schema":
+			err = dec.Decode(&d.Schema)
+		case "data":
+			if d.Schema == nil {
+				// schema unknown yet, rows can't decode
+				err = dec.Decode(&d.Data)
+				break
+			}
+			if err := expectDelim(dec, '['); err != nil {
+				return nil, nil, err
 			}
+			return d, &respDataRows{respData: respData{Schema: d.Schema}, dec: dec, times: tc}, nil
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, row := range d.Data {
+		if err := decodeRow(d.Schema, row, tc); err != nil {
+			return nil, nil, err
+		}
+	}
+	return d, nil, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("invalid response: unexpected %v, expect '%v'", tok, delim)
+	}
+	return nil
+}
+
+// decodeRow converts JSON values of a row into go values, based on types in schema, with
+// timestamps and dates decoded by tc
+func decodeRow(schema []string, row []driver.Value, tc timeCodec) error {
+	if len(row) > len(schema) {
+		return fmt.Errorf("invalid response: %d values in row, but schema has %d", len(row), len(schema))
+	}
+
+	for i, col := range row {
+		if col == nil {
+			row[i] = nil
+			continue
+		}
+
+		t, err := parseDataType(schema[i])
+		if err != nil {
+			return fmt.Errorf("unknown type %s at index %d", schema[i], i)
+		}
+		// date and timestamp values saved internally as time.Time, unless raw
+		if row[i], err = t.decode(col, tc); err != nil {
+			return fmt.Errorf("invalid response: %w at index %d", err, i)
+		}
+	}
+	return nil
+}
+
+// execute runs a statement in db and mode with hooks
+func (c *conn) execute(ctx context.Context, db string, mode queryMode, sql string, parameters ...driver.Value) (driver.Rows, error) {
+	parameters = c.times.dates(parameters)
+	if len(parameters) > 0 && c.interpolates(sql) {
+		args := make([]any, len(parameters))
+		for i, p := range parameters {
+			args[i] = p
+		}
+		s, err := Interpolate(sql, args...)
+		if err != nil {
+			return nil, err
+		}
+		sql, parameters = s, nil
+	}
+
+	if len(c.hooks) == 0 {
+		return c.query(ctx, db, mode, sql, parameters...)
+	}
+
+	req := &Request{Op: OpQuery, Endpoint: c.host, DB: db, Mode: mode, SQL: sql, Args: parameters}
+	ctx, res, after, err := c.before(ctx, req)
+	if err != nil {
+		after(nil, err)
+		return nil, err
+	}
+	if res != nil {
+		// short-circuited
+		res.RowCount = len(res.Rows)
+		after(res, nil)
+		if res.Types == nil && res.Rows == nil {
+			return nil, nil
 		}
+		return &respDataRows{respData: respData{Schema: res.Types, Data: res.Rows}}, nil
 	}
 
-	return &r, nil
+	rows, err := c.query(ctx, req.DB, req.Mode, req.SQL, req.Args...)
+	if err != nil || rows == nil {
+		after(nil, err)
+		return rows, err
+	}
+	dataRows := rows.(*respDataRows)
+	dataRows.onClose = func(n int, err error) {
+		after(&Result{Types: dataRows.Schema, RowCount: n}, err)
+	}
+	return dataRows, nil
+}
+
+// interpolates reports whether parameters of sql are rendered into it by mode of conn
+func (c *conn) interpolates(sql string) bool {
+	switch c.interpolate {
+	case InterpolateAll:
+		return true
+	case InterpolateAuto:
+		stmt, err := sqlparse.Classify(sql)
+		// sent as is if not classified, e.g. multiple statements
+		return err == nil && stmt.Kind != sqlparse.KindDQL && stmt.Kind != sqlparse.KindDML
+	default:
+		return false
+	}
 }
 
-func (c *conn) execute(ctx context.Context, sql string, parameters ...driver.Value) (rows driver.Rows, err error) {
+// query sends SQL to api server, rows returned is nil or *respDataRows. LOAD DATA and SELECT
+// INTO in offsync mode are run by runJob, to stop their jobs once ctx is done.
+func (c *conn) query(ctx context.Context, db string, mode queryMode, sql string, parameters ...driver.Value) (rows driver.Rows, err error) {
 	if c.closed {
 		return nil, driver.ErrBadConn
 	}
+	if ctx.Done() != nil && submitsJob(mode, sql) {
+		return c.runJob(ctx, db, sql, parameters...)
+	}
+	return c.send(ctx, db, mode, sql, parameters...)
+}
 
-	reqBody, err := marshalQueryRequest(string(c.mode), sql, parameters...)
+// send sends SQL to api server, with the request timeout by deadline of ctx
+func (c *conn) send(ctx context.Context, db string, mode queryMode, sql string, parameters ...driver.Value) (driver.Rows, error) {
+	timeout, err := requestTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reqBody, err := marshalQueryRequest(string(mode), sql, timeout, parameters...)
 	if err != nil {
 		return nil, err
 	}
@@ -253,29 +576,49 @@ func (c *conn) execute(ctx context.Context, sql string, parameters ...driver.Val
 	req, err := http.NewRequestWithContext(
 		ctx,
 		"POST",
-		fmt.Sprintf("http://%s/dbs/%s", c.host, c.db),
+		fmt.Sprintf("http://%s/dbs/%s", c.host, db),
 		bytes.NewBuffer(reqBody),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	if r, err := unmarshalQueryResponse(resp.Body); err != nil {
+	r, dataRows, err := decodeQueryResponse(resp.Body, c.times)
+	if err != nil {
+		closeBody(resp.Body)
 		return nil, err
 	} else if r.Code != 0 {
-		return nil, fmt.Errorf("execute error: %s", r.Msg)
-	} else if r.Data != nil {
-		return &respDataRows{*r.Data, 0}, nil
+		closeBody(resp.Body)
+		return nil, &APIError{Op: OpQuery, Code: r.Code, Msg: r.Msg}
+	} else if dataRows != nil {
+		// response body closed when rows closed
+		dataRows.body = resp.Body
+		return dataRows, nil
 	}
 
+	closeBody(resp.Body)
+	if r.Data != nil {
+		return &respDataRows{respData: *r.Data, times: c.times}, nil
+	}
 	return nil, nil
 }
 
+// maxDrainBody bounds the rest of response body read before closing it, the connection of
+// a larger rest is closed instead of reused
+const maxDrainBody = 256 << 10
+
+// closeBody closes response body after reading the rest of it, e.g. rows not read, so that
+// the connection is reused by the following requests
+func closeBody(body io.ReadCloser) error {
+	io.CopyN(io.Discard, body, maxDrainBody)
+	return body.Close()
+}
+
 // Prepare implements driver.Conn.
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
 	return nil, errors.New("Prepare is not implemented, use QueryContext instead")
@@ -294,7 +637,10 @@ func (c *conn) Begin() (driver.Tx, error) {
 
 // Ping implements driver.Pinger.
 func (c *conn) Ping(ctx context.Context) error {
-	_, err := c.execute(ctx, "SELECT 1")
+	rows, err := c.query(ctx, c.db, c.mode, "SELECT 1")
+	if rows != nil {
+		rows.Close()
+	}
 	return err
 }
 
@@ -312,23 +658,46 @@ func (c *conn) IsValid() bool {
 	return !c.closed
 }
 
+// CheckNamedValue implements driver.NamedValueChecker.
+//
+// NullDate arguments are kept to be sent as dates, instead of converted into time.Time and
+// sent as timestamps, so are maps, slices, NullMap and NullArray sent as MAP and ARRAY. Other
+// arguments are converted by default, including maps and slices implementing driver.Valuer.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch v := nv.Value.(type) {
+	case NullDate, composite:
+		return nil
+	case nil, driver.Valuer:
+		return driver.ErrSkip
+	default:
+		if _, ok := compositeType(reflect.TypeOf(v)); ok {
+			return nil
+		}
+	}
+	return driver.ErrSkip
+}
+
 // ExecContext implements driver.ExecerContext.
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	parameters := make([]driver.Value, len(args))
-	for i, arg := range args {
-		parameters[i] = arg.Value
+	query, parameters, err := bindNamed(query, args)
+	if err != nil {
+		return nil, err
 	}
-	if _, err := c.execute(ctx, query, parameters...); err != nil {
+	rows, err := c.execute(ctx, c.db, c.mode, query, parameters...)
+	if err != nil {
 		return nil, err
 	}
+	if rows != nil {
+		rows.Close()
+	}
 	return driver.ResultNoRows, nil
 }
 
 // QueryContext implements driver.QueryerContext.
 func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	parameters := make([]driver.Value, len(args))
-	for i, arg := range args {
-		parameters[i] = arg.Value
+	query, parameters, err := bindNamed(query, args)
+	if err != nil {
+		return nil, err
 	}
-	return c.execute(ctx, query, parameters...)
+	return c.executeMulti(ctx, query, parameters)
 }
@@ -1,13 +1,20 @@
 package openmldb
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseReqToJson(t *testing.T) {
@@ -48,7 +55,7 @@ func TestParseReqToJson(t *testing.T) {
 			}`,
 		},
 	} {
-		actual, err := marshalQueryRequest(tc.mode, tc.sql, tc.input...)
+		actual, err := marshalQueryRequest(tc.mode, tc.sql, 0, tc.input...)
 		assert.NoError(t, err)
 		assert.JSONEq(t, tc.expect, string(actual))
 	}
@@ -129,9 +136,90 @@ func TestParseRespFromJson(t *testing.T) {
 				},
 			},
 		},
+		{
+			`{
+				"data": {
+					"data": [[1, "bb"]],
+					"schema": ["Int32", "String"]
+				},
+				"msg": "ok",
+				"code": 0
+			}`,
+			queryResp{
+				Code: 0,
+				Msg:  "ok",
+				Data: &respData{
+					Schema: []string{"Int32", "String"},
+					Data: [][]driver.Value{
+						{int32(1), "bb"},
+					},
+				},
+			},
+		},
 	} {
 		actual, err := unmarshalQueryResponse(strings.NewReader(tc.resp))
 		assert.NoError(t, err)
 		assert.Equal(t, &tc.expect, actual)
 	}
+
+	_, err := unmarshalQueryResponse(strings.NewReader(`{"code": 0, "msg": "ok", "data": {"schema": ["Int32", "Date"], "data": [[1, "2022-13-01"]]}}`))
+	assert.EqualError(t, err, `invalid response: invalid date "2022-13-01" at index 1`)
+}
+
+func TestResponseBodyDrained(t *testing.T) {
+	// rows beyond the buffer of decoder, not read until rows closed, within maxDrainBody
+	data := strings.Repeat("[1], ", 40000) + "[1]"
+	srv := httptest.NewUnstartedServer(respondWith(`{"code": 0, "msg": "ok", "data": {"schema": ["Int32"], "data": [` +
+		data + `]}}`))
+	var conns atomic.Int32
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	db, err := sql.Open("openmldb", "openmldb://"+strings.TrimPrefix(srv.URL, "http://")+"/test_db")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	require.NoError(t, db.PingContext(ctx))
+	_, err = db.ExecContext(ctx, "SELECT c1 FROM t1")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, "SELECT c1 FROM t1")
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Close())
+	require.NoError(t, db.PingContext(ctx))
+
+	// rows not read are drained, so the HTTP connection is reused
+	assert.Equal(t, int32(1), conns.Load())
+}
+
+func TestAPIError(t *testing.T) {
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/deployments/") {
+			w.Write([]byte(`{"code": -2, "msg": "deployment not found"}`))
+			return
+		}
+		var req queryReq
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.SQL == "SELECT c9 FROM t1" {
+			w.Write([]byte(`{"code": 2000, "msg": "column c9 not found"}`))
+			return
+		}
+		w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+	})
+
+	_, err := db.Query("SELECT c9 FROM t1")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, &APIError{Op: OpQuery, Code: 2000, Msg: "column c9 not found"}, apiErr)
+	assert.EqualError(t, err, "execute error: column c9 not found")
+
+	_, err = CallDeployment(context.Background(), db, "d1", []any{"aa"})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, &APIError{Op: OpDeployment, Code: -2, Msg: "deployment not found"}, apiErr)
+	assert.EqualError(t, err, "call deployment error: deployment not found")
 }
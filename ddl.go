@@ -0,0 +1,372 @@
+package openmldb
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TableOption configures the table created by CreateTableFromStruct.
+type TableOption func(*tableOptions)
+
+type tableOptions struct {
+	ifNotExists  bool
+	partitionNum int
+	replicaNum   int
+	storageMode  string
+}
+
+// WithIfNotExists creates the table only if it not exists.
+func WithIfNotExists() TableOption {
+	return func(o *tableOptions) { o.ifNotExists = true }
+}
+
+// WithPartitionNum sets the number of partitions of table.
+func WithPartitionNum(n int) TableOption {
+	return func(o *tableOptions) { o.partitionNum = n }
+}
+
+// WithReplicaNum sets the number of replicas of table.
+func WithReplicaNum(n int) TableOption {
+	return func(o *tableOptions) { o.replicaNum = n }
+}
+
+// WithStorageMode sets the storage mode of table, memory, ssd or hdd.
+func WithStorageMode(mode string) TableOption {
+	return func(o *tableOptions) { o.storageMode = mode }
+}
+
+// CreateTableFromStruct returns the CREATE TABLE statement of table name, with a column for
+// each field of struct T, named by `openmldb` tag or field name, as in ScanAll.
+//
+// Column types are mapped from field types:
+//
+//	bool                    bool
+//	int16                   smallint
+//	int32                   int
+//	int, int64              bigint
+//	float32                 float
+//	float64                 double
+//	string                  string
+//	time.Time               timestamp
+//	NullDate                date
+//	Null[T], sql.Null[T]    the type of T
+//	*T                      the type of T
+//	sql.NullInt64 etc.      the type of value
+//
+// Options follow the column name in `openmldb` tag, separated by commas:
+//
+//	notnull        the column is NOT NULL
+//	default=value  the DEFAULT value of column, quoted for string, date and timestamp columns
+//	type=name      the column type instead of the mapped one, e.g. type=date for time.Time
+//
+// Indexes are declared in `openmldb_index` tag of any field, in the syntax of INDEX in
+// CREATE TABLE, separated by semicolons. KEY defaults to the column of the field:
+//
+//	type Event struct {
+//		ID string    `openmldb:"id,notnull" openmldb_index:"TS=ts, TTL=30d, TTL_TYPE=absolute"`
+//		TS time.Time `openmldb:"ts"`
+//	}
+func CreateTableFromStruct[T any](name string, opts ...TableOption) (string, error) {
+	var o tableOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t := reflect.TypeFor[T]()
+	if !isStructRow(t) {
+		return "", fmt.Errorf("create table from %s: not a struct", t)
+	}
+	fields, err := structFieldsOf(t)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) == 0 {
+		return "", fmt.Errorf("create table from %s: no columns", t)
+	}
+
+	columns := map[string]bool{}
+	for _, f := range fields {
+		columns[f.name] = true
+	}
+
+	var defs, indexes []string
+	for _, f := range fields {
+		sf := t.FieldByIndex(f.index)
+		def, err := columnDef(f.name, sf)
+		if err != nil {
+			return "", fmt.Errorf("create table from %s: field %s: %w", t, sf.Name, err)
+		}
+		defs = append(defs, def)
+
+		tag, ok := sf.Tag.Lookup("openmldb_index")
+		if !ok {
+			continue
+		}
+		for _, spec := range strings.Split(tag, ";") {
+			if strings.TrimSpace(spec) == "" {
+				continue
+			}
+			idx, err := indexDef(spec, f.name, columns)
+			if err != nil {
+				return "", fmt.Errorf("create table from %s: field %s: %w", t, sf.Name, err)
+			}
+			indexes = append(indexes, idx)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("CREATE TABLE ")
+	if o.ifNotExists {
+		b.WriteString("IF NOT EXISTS ")
+	}
+	fmt.Fprintf(&b, "%s (%s)", QuoteIdentifier(name), strings.Join(append(defs, indexes...), ", "))
+
+	var tableOpts []string
+	if o.partitionNum != 0 {
+		tableOpts = append(tableOpts, fmt.Sprintf("PARTITIONNUM=%d", o.partitionNum))
+	}
+	if o.replicaNum != 0 {
+		tableOpts = append(tableOpts, fmt.Sprintf("REPLICANUM=%d", o.replicaNum))
+	}
+	if o.storageMode != "" {
+		switch mode := strings.ToLower(o.storageMode); mode {
+		case "memory", "ssd", "hdd":
+			tableOpts = append(tableOpts, "STORAGE_MODE='"+strings.ToUpper(mode)+"'")
+		default:
+			return "", fmt.Errorf("create table from %s: invalid storage mode %s", t, o.storageMode)
+		}
+	}
+	if len(tableOpts) > 0 {
+		fmt.Fprintf(&b, " OPTIONS (%s)", strings.Join(tableOpts, ", "))
+	}
+	return b.String(), nil
+}
+
+var (
+	sqlNullTypes = map[reflect.Type]string{
+		reflect.TypeFor[sql.NullBool]():    "bool",
+		reflect.TypeFor[sql.NullInt16]():   "smallint",
+		reflect.TypeFor[sql.NullInt32]():   "int",
+		reflect.TypeFor[sql.NullInt64]():   "bigint",
+		reflect.TypeFor[sql.NullFloat64](): "double",
+		reflect.TypeFor[sql.NullString]():  "string",
+		reflect.TypeFor[sql.NullTime]():    "timestamp",
+		reflect.TypeFor[NullDate]():        "date",
+	}
+	columnTypes = map[string]bool{
+		"bool": true, "smallint": true, "int": true, "bigint": true, "float": true,
+		"double": true, "string": true, "timestamp": true, "date": true,
+	}
+)
+
+// columnType returns the column type of field type t
+func columnType(t reflect.Type) (string, error) {
+	if typ, ok := sqlNullTypes[t]; ok {
+		return typ, nil
+	}
+	if t == timeType {
+		return "timestamp", nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool", nil
+	case reflect.Int16:
+		return "smallint", nil
+	case reflect.Int32:
+		return "int", nil
+	case reflect.Int, reflect.Int64:
+		return "bigint", nil
+	case reflect.Float32:
+		return "float", nil
+	case reflect.Float64:
+		return "double", nil
+	case reflect.String:
+		return "string", nil
+	case reflect.Pointer:
+		return columnType(t.Elem())
+	case reflect.Struct:
+		// Null[T] and sql.Null[T] are of the type of their value V
+		if strings.HasPrefix(t.Name(), "Null[") {
+			if v, ok := t.FieldByName("V"); ok {
+				return columnType(v.Type)
+			}
+		}
+	}
+	return "", fmt.Errorf("unsupported type %s", t)
+}
+
+// columnDef returns the column definition of field sf named name
+func columnDef(name string, sf reflect.StructField) (string, error) {
+	tag := sf.Tag.Get("openmldb")
+	var options []string
+	if _, rest, ok := strings.Cut(tag, ","); ok {
+		options = strings.Split(rest, ",")
+	}
+
+	var (
+		typ     string
+		notNull bool
+		dflt    string
+		hasDflt bool
+	)
+	for _, opt := range options {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch strings.ToLower(key) {
+		case "notnull":
+			notNull = true
+		case "default":
+			dflt, hasDflt = value, true
+		case "type":
+			typ = strings.ToLower(value)
+			if !columnTypes[typ] {
+				return "", fmt.Errorf("unsupported column type %s", value)
+			}
+		default:
+			return "", fmt.Errorf("unknown option %s", opt)
+		}
+	}
+	if typ == "" {
+		var err error
+		if typ, err = columnType(sf.Type); err != nil {
+			return "", err
+		}
+	}
+
+	def := QuoteIdentifier(name) + " " + typ
+	if notNull {
+		def += " NOT NULL"
+	}
+	if hasDflt {
+		v, err := defaultValue(typ, dflt)
+		if err != nil {
+			return "", err
+		}
+		def += " DEFAULT " + v
+	}
+	return def, nil
+}
+
+// defaultValue returns the SQL literal of default value s of column type typ
+func defaultValue(typ string, s string) (string, error) {
+	var err error
+	switch typ {
+	case "bool":
+		_, err = strconv.ParseBool(s)
+	case "smallint", "int", "bigint":
+		_, err = strconv.ParseInt(s, 10, 64)
+	case "float", "double":
+		_, err = strconv.ParseFloat(s, 64)
+	default:
+		if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+			return s, nil
+		}
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid default %q of %s column", s, typ)
+	}
+	return s, nil
+}
+
+var (
+	absTTLPattern = regexp.MustCompile(`^\d+[mhd]?$`)
+	latTTLPattern = regexp.MustCompile(`^\d+$`)
+)
+
+// indexDef returns the INDEX definition of spec in `openmldb_index` tag of column
+func indexDef(spec string, column string, columns map[string]bool) (string, error) {
+	keys := []string{column}
+	var ts, ttl, ttlType string
+	for _, opt := range splitTopLevel(spec) {
+		key, value, ok := strings.Cut(opt, "=")
+		key, value = strings.ToUpper(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return "", fmt.Errorf("invalid index option %q", opt)
+		}
+		switch key {
+		case "KEY":
+			keys = nil
+			for _, k := range strings.Split(strings.Trim(value, "()"), ",") {
+				keys = append(keys, strings.Trim(strings.TrimSpace(k), "`"))
+			}
+		case "TS":
+			ts = strings.Trim(value, "`")
+		case "TTL":
+			ttl = strings.ToLower(strings.ReplaceAll(value, " ", ""))
+		case "TTL_TYPE":
+			ttlType = strings.ToLower(value)
+		default:
+			return "", fmt.Errorf("unknown index option %s", key)
+		}
+	}
+
+	for _, k := range append(append([]string(nil), keys...), ts) {
+		if k != "" && !columns[k] {
+			return "", fmt.Errorf("index: unknown column %s", k)
+		}
+	}
+	switch ttlType {
+	case "", "absolute", "latest", "absorlat", "absandlat":
+	default:
+		return "", fmt.Errorf("index: invalid TTL_TYPE %s", ttlType)
+	}
+	if ttl != "" {
+		valid := false
+		abs, lat, pair := strings.Cut(strings.Trim(ttl, "()"), ",")
+		switch ttlType {
+		case "", "absolute":
+			valid = !pair && absTTLPattern.MatchString(ttl)
+		case "latest":
+			valid = !pair && latTTLPattern.MatchString(ttl)
+		case "absorlat", "absandlat":
+			valid = pair && absTTLPattern.MatchString(abs) && latTTLPattern.MatchString(lat)
+			ttl = "(" + abs + ", " + lat + ")"
+		}
+		if !valid {
+			return "", fmt.Errorf("index: invalid TTL %s of TTL_TYPE %s", ttl, ttlType)
+		}
+	}
+
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		quoted[i] = QuoteIdentifier(k)
+	}
+	opts := []string{"KEY=" + quoted[0]}
+	if len(quoted) > 1 {
+		opts[0] = "KEY=(" + strings.Join(quoted, ", ") + ")"
+	}
+	if ts != "" {
+		opts = append(opts, "TS="+QuoteIdentifier(ts))
+	}
+	if ttl != "" {
+		opts = append(opts, "TTL="+ttl)
+	}
+	if ttlType != "" {
+		opts = append(opts, "TTL_TYPE="+ttlType)
+	}
+	return "INDEX(" + strings.Join(opts, ", ") + ")", nil
+}
+
+// splitTopLevel splits s on commas not in parentheses
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
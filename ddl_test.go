@@ -0,0 +1,131 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+type ddlBase struct {
+	ID string `openmldb:"id,notnull" openmldb_index:"TS=ts, TTL=30d, TTL_TYPE=absolute"`
+}
+
+type ddlEvent struct {
+	ddlBase
+	Age     int32           `openmldb:"age,default=18"`
+	Score   Null[float64]   `openmldb:"score"`
+	Count   *int64          `openmldb:"count"`
+	Flag    sql.NullBool    `openmldb:"flag"`
+	Small   int16           `openmldb:"small"`
+	Rate    float32         `openmldb:"rate"`
+	Name    string          `openmldb:"name,notnull,default=it's"`
+	TS      time.Time       `openmldb:"ts"`
+	Day     NullDate        `openmldb:"day"`
+	Birth   time.Time       `openmldb:"birth,type=date"`
+	Ignored string          `openmldb:"-"`
+	Extra   Null[time.Time] `openmldb_index:"KEY=(id, age), TS=Extra, TTL=(1d, 10), TTL_TYPE=absorlat; KEY=name, TTL=5, TTL_TYPE=latest"`
+}
+
+func TestCreateTableFromStruct(t *testing.T) {
+	ddl, err := CreateTableFromStruct[ddlEvent]("events", WithIfNotExists(), WithPartitionNum(2), WithReplicaNum(1),
+		WithStorageMode("memory"))
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `events` (`id` string NOT NULL, `age` int DEFAULT 18, "+
+		"`score` double, `count` bigint, `flag` bool, `small` smallint, `rate` float, "+
+		"`name` string NOT NULL DEFAULT 'it''s', `ts` timestamp, `day` date, `birth` date, `Extra` timestamp, "+
+		"INDEX(KEY=`id`, TS=`ts`, TTL=30d, TTL_TYPE=absolute), "+
+		"INDEX(KEY=(`id`, `age`), TS=`Extra`, TTL=(1d, 10), TTL_TYPE=absorlat), "+
+		"INDEX(KEY=`name`, TTL=5, TTL_TYPE=latest)) "+
+		"OPTIONS (PARTITIONNUM=2, REPLICANUM=1, STORAGE_MODE='MEMORY')", ddl)
+
+	stmt, err := sqlparse.Classify(ddl)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE", stmt.Verb)
+	assert.Equal(t, []string{"events"}, stmt.Tables)
+
+	db := openHookedDB(t)
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, ddl)
+	require.NoError(t, err)
+
+	tables, err := DescribeTables(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	info := tables[0]
+	assert.Equal(t, 2, info.PartitionNum)
+	assert.Equal(t, []ColumnInfo{
+		{Name: "id", Type: "string", NotNull: true},
+		{Name: "age", Type: "int"},
+		{Name: "score", Type: "double"},
+		{Name: "count", Type: "bigint"},
+		{Name: "flag", Type: "bool"},
+		{Name: "small", Type: "smallint"},
+		{Name: "rate", Type: "float"},
+		{Name: "name", Type: "string", NotNull: true},
+		{Name: "ts", Type: "timestamp"},
+		{Name: "day", Type: "date"},
+		{Name: "birth", Type: "date"},
+		{Name: "Extra", Type: "timestamp"},
+	}, info.Columns)
+	require.Len(t, info.Indexes, 3)
+	assert.Equal(t, IndexInfo{Name: "INDEX_0", Keys: []string{"id"}, TS: "ts", TTLType: "absolute",
+		AbsTTL: 30 * 24 * time.Hour}, info.Indexes[0])
+	assert.Equal(t, IndexInfo{Name: "INDEX_1", Keys: []string{"id", "age"}, TS: "Extra", TTLType: "absorlat",
+		AbsTTL: 24 * time.Hour, LatTTL: 10}, info.Indexes[1])
+	assert.Equal(t, IndexInfo{Name: "INDEX_2", Keys: []string{"name"}, TTLType: "latest", LatTTL: 5}, info.Indexes[2])
+
+	// default values are filled by the table
+	_, err = db.ExecContext(ctx, "INSERT INTO events (id, name, ts) VALUES ('a', 'b', 1000)")
+	require.NoError(t, err)
+	var age int32
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT age FROM events").Scan(&age))
+	assert.Equal(t, int32(18), age)
+}
+
+func TestCreateTableFromStructErrors(t *testing.T) {
+	_, err := CreateTableFromStruct[int]("t")
+	assert.ErrorContains(t, err, "not a struct")
+
+	_, err = CreateTableFromStruct[struct {
+		A uint32
+	}]("t")
+	assert.ErrorContains(t, err, "field A: unsupported type uint32")
+
+	// quoted defaults kept as is
+	sql, err := CreateTableFromStruct[struct {
+		A string `openmldb:"a,default='abc'"`
+	}]("t")
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE `t` (`a` string DEFAULT 'abc')", sql)
+
+	_, err = CreateTableFromStruct[struct {
+		A int32 `openmldb:"a,default=x"`
+	}]("t")
+	assert.ErrorContains(t, err, `invalid default "x" of int column`)
+
+	_, err = CreateTableFromStruct[struct {
+		A int32 `openmldb:"a,unique"`
+	}]("t")
+	assert.ErrorContains(t, err, "unknown option unique")
+
+	_, err = CreateTableFromStruct[struct {
+		A int32 `openmldb:"a" openmldb_index:"TS=ts"`
+	}]("t")
+	assert.ErrorContains(t, err, "unknown column ts")
+
+	_, err = CreateTableFromStruct[struct {
+		A int32 `openmldb:"a" openmldb_index:"TTL=1d, TTL_TYPE=latest"`
+	}]("t")
+	assert.ErrorContains(t, err, "invalid TTL 1d of TTL_TYPE latest")
+
+	_, err = CreateTableFromStruct[struct {
+		A int32 `openmldb:"a"`
+	}]("t", WithStorageMode("disk"))
+	assert.ErrorContains(t, err, "invalid storage mode disk")
+}
@@ -0,0 +1,146 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+type deploymentReq struct {
+	Input      [][]any `json:"input"`
+	NeedSchema bool    `json:"need_schema"`
+}
+
+type deploymentResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data *struct {
+		Data   [][]driver.Value `json:"data"`
+		Schema []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"schema"`
+	} `json:"data,omitempty"`
+}
+
+// DeploymentResult is the result of calling a deployment.
+type DeploymentResult struct {
+	// Columns are names of output columns.
+	Columns []string
+	// Rows are output rows, one for each request row.
+	Rows [][]any
+}
+
+// CallDeployment calls deployment name in request mode, each of rows is a request row with
+// values of all columns of the main table in the deployment, in the order of table schema.
+//
+// time.Time values are sent as timestamp, use string in the format of 2006-01-02 for date column.
+func CallDeployment(ctx context.Context, db *sql.DB, name string, rows ...[]any) (*DeploymentResult, error) {
+	dbConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer dbConn.Close()
+
+	var result *DeploymentResult
+	err = dbConn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return fmt.Errorf("not an openmldb connection: %T", driverConn)
+		}
+
+		input := make([][]any, len(rows))
+		for i, row := range rows {
+			input[i] = make([]any, len(row))
+			for j, v := range row {
+				jv, err := deploymentValue(v, c.times)
+				if err != nil {
+					return fmt.Errorf("row %d column %d: %w", i, j, err)
+				}
+				input[i][j] = jv
+			}
+		}
+
+		req := &Request{Op: OpDeployment, Endpoint: c.host, DB: c.db, Deployment: name, Rows: input}
+		res, err := c.intercept(ctx, req, c.callDeployment)
+		if err != nil {
+			return err
+		}
+
+		result = &DeploymentResult{Columns: res.Columns}
+		for _, row := range res.Rows {
+			values := make([]any, len(row))
+			for i, v := range row {
+				values[i] = v
+			}
+			result.Rows = append(result.Rows, values)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *conn) callDeployment(ctx context.Context, req *Request) (*Result, error) {
+	var r deploymentResp
+	path := fmt.Sprintf("/dbs/%s/deployments/%s", url.PathEscape(req.DB), url.PathEscape(req.Deployment))
+	if err := c.doJSON(ctx, "POST", path, deploymentReq{Input: req.Rows, NeedSchema: true}, &r); err != nil {
+		return nil, err
+	}
+	if r.Code != 0 {
+		return nil, &APIError{Op: OpDeployment, Code: r.Code, Msg: r.Msg}
+	}
+
+	res := &Result{}
+	if r.Data == nil {
+		return res, nil
+	}
+	for _, col := range r.Data.Schema {
+		res.Columns = append(res.Columns, col.Name)
+		res.Types = append(res.Types, col.Type)
+	}
+	for _, row := range r.Data.Data {
+		if err := decodeRow(res.Types, row, c.times); err != nil {
+			return nil, err
+		}
+	}
+	res.Rows = r.Data.Data
+	res.RowCount = len(res.Rows)
+	return res, nil
+}
+
+// deploymentValue converts value into JSON representation of request row, dates in the location
+// of tc
+func deploymentValue(v any, tc timeCodec) (any, error) {
+	if d, ok := v.(NullDate); ok {
+		// date, instead of timestamp by the value of d
+		if !d.Valid {
+			return nil, nil
+		}
+		return tc.dateIn(d.V).Format(time.DateOnly), nil
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+		v = dv
+	}
+
+	switch v := v.(type) {
+	case nil, bool, string,
+		int, int8, int16, int32, int64, uint8, uint16, uint32, uint64,
+		float32, float64, json.Number:
+		return v, nil
+	case time.Time:
+		return v.UnixMilli(), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
@@ -0,0 +1,43 @@
+package openmldb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallDeployment(t *testing.T) {
+	var input [][]any
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dbs/test_db/deployments/demo" {
+			w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+			return
+		}
+		var req struct {
+			Input      [][]any `json:"input"`
+			NeedSchema bool    `json:"need_schema"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.NeedSchema)
+		input = req.Input
+		w.Write([]byte(`{"code": 0, "msg": "ok", "data": {
+			"data": [["aa", 3, 1000]],
+			"schema": [{"name": "c1", "type": "string"}, {"name": "w_sum", "type": "int64"}, {"name": "ts", "type": "timestamp"}],
+			"common_cols_data": []}}`))
+	})
+
+	result, err := CallDeployment(context.Background(), db, "demo", []any{"aa", int32(1), time.UnixMilli(1000)})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]any{{"aa", float64(1), float64(1000)}}, input)
+	assert.Equal(t, &DeploymentResult{
+		Columns: []string{"c1", "w_sum", "ts"},
+		Rows:    [][]any{{"aa", int64(3), time.UnixMilli(1000)}},
+	}, result)
+
+	_, err = CallDeployment(context.Background(), db, "demo", []any{struct{}{}})
+	assert.EqualError(t, err, "row 0 column 0: unsupported type struct {}")
+}
@@ -5,8 +5,12 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func init() {
@@ -54,39 +58,197 @@ func parseDsn(dsn string) (host string, db string, mode queryMode, err error) {
 // Open implements driver.Driver.
 func (openmldbDriver) Open(name string) (driver.Conn, error) {
 	// name should be the URL of the api server, e.g. openmldb://localhost:6543/db
-	host, db, mode, err := parseDsn(name)
+	cfg, err := ParseDSN(name)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := NewConnector(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return &conn{host: host, db: db, mode: mode, closed: false}, nil
+	return connector.(*connecter).newConn(), nil
 }
 
 // OpenConnector implements driver.DriverContext.
 func (openmldbDriver) OpenConnector(name string) (driver.Connector, error) {
-	host, db, mode, err := parseDsn(name)
+	cfg, err := ParseDSN(name)
 	if err != nil {
 		return nil, err
 	}
 
-	return &connecter{host, db, mode}, nil
+	return NewConnector(cfg)
+}
+
+// Config is the configuration of connections to api server. It is parsed from DSN by ParseDSN,
+// or built in code for settings not expressible in DSN, then used with NewConnector and sql.OpenDB.
+type Config struct {
+	// Host is the host or host:port of api server.
+	Host string
+	// DB is the database name.
+	DB string
+	// Mode is the execution mode, ModeOnline if empty.
+	Mode queryMode
+	// HTTPClient sends requests to api server, http.DefaultClient if nil. Its transport can be
+	// replaced, e.g. by a recording transport in tests.
+	HTTPClient *http.Client
+	// Hooks run before and after each request, Before in order and After in reverse order.
+	Hooks []Hook
+
+	// Logger logs a summary of each request at debug level, and slow requests at warning
+	// level. Nothing is logged if nil.
+	Logger *slog.Logger
+	// SlowThreshold is the duration above which requests are logged as slow, 0 disables it.
+	SlowThreshold time.Duration
+	// LogSampleEvery logs debug summaries of 1 in every LogSampleEvery requests, all if 0 or 1.
+	// Slow requests are not sampled.
+	LogSampleEvery int
+	// LogValues logs parameter values, and SQL and errors with string literals, which are
+	// redacted by default.
+	LogValues bool
+
+	// InterpolateParams renders parameters into SQL as literals before sending it, for statements
+	// not taking parameters by api server. Parsed from DSN parameter interpolateParams.
+	InterpolateParams InterpolateMode
+
+	// ReadOnly denies statements other than queries, SHOW, USE, SET of session variables and
+	// deployment calls, e.g. rows put by PutRow or SET GLOBAL, with *PolicyError. Parsed from DSN
+	// parameter readonly.
+	ReadOnly bool
+	// Policy allows or denies statements by kind, table and mode, checked after ReadOnly.
+	Policy *Policy
+	// GuardOffline denies offline jobs, i.e. statements in offline mode, LOAD DATA and SELECT
+	// INTO, unless context is permitted by PermitOffline. Parsed from DSN parameter guardOffline.
+	GuardOffline bool
+
+	// Loc is the location timestamps of responses are decoded in, and dates are decoded at
+	// midnight in and sent in, i.e. NullDate parameters, dates put by PutRows and called by
+	// CallDeployment are sent as their calendar date in Loc. time.Time parameters are sent as
+	// instants. By default timestamps are decoded in time.Local, dates at midnight UTC, and
+	// dates are sent in their own location. Parsed from DSN parameter loc, e.g. loc=UTC or
+	// loc=Asia%2FShanghai.
+	Loc *time.Location
+	// RawTime decodes timestamps as int64 milliseconds since epoch, and dates as strings
+	// formatted yyyy-mm-dd, instead of time.Time. Parsed from DSN parameter parseTime=false.
+	RawTime bool
+}
+
+// InterpolateMode is when parameters are rendered into SQL client side by Interpolate.
+type InterpolateMode string
+
+const (
+	// InterpolateNone sends parameters to api server.
+	InterpolateNone InterpolateMode = ""
+	// InterpolateAuto interpolates parameters of statements api server can't parameterize, i.e.
+	// all but SELECT and DML, e.g. DDL, DEPLOY, LOAD DATA or SHOW.
+	InterpolateAuto InterpolateMode = "auto"
+	// InterpolateAll interpolates parameters of all statements, for api servers ignoring them.
+	InterpolateAll InterpolateMode = "true"
+)
+
+// ParseDSN parses DSN into Config.
+func ParseDSN(dsn string) (*Config, error) {
+	host, db, mode, err := parseDsn(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{Host: host, DB: db, Mode: mode}
+
+	// URL is validated by parseDsn
+	u, _ := url.Parse(dsn)
+	query := u.Query()
+	if query.Has("interpolateParams") {
+		switch v := query.Get("interpolateParams"); v {
+		case "true":
+			cfg.InterpolateParams = InterpolateAll
+		case "auto":
+			cfg.InterpolateParams = InterpolateAuto
+		case "false":
+			cfg.InterpolateParams = InterpolateNone
+		default:
+			return nil, fmt.Errorf("invalid interpolateParams: %s", v)
+		}
+	}
+	if query.Has("loc") {
+		if cfg.Loc, err = time.LoadLocation(query.Get("loc")); err != nil {
+			return nil, fmt.Errorf("invalid loc: %s", query.Get("loc"))
+		}
+	}
+	if query.Has("parseTime") {
+		parseTime, err := strconv.ParseBool(query.Get("parseTime"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid parseTime: %s", query.Get("parseTime"))
+		}
+		cfg.RawTime = !parseTime
+	}
+	for name, v := range map[string]*bool{"readonly": &cfg.ReadOnly, "guardOffline": &cfg.GuardOffline} {
+		if !query.Has(name) {
+			continue
+		}
+		if *v, err = strconv.ParseBool(query.Get(name)); err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", name, query.Get(name))
+		}
+	}
+	return cfg, nil
+}
+
+// NewConnector creates a connector by cfg, to be opened by sql.OpenDB:
+//
+//	cfg, _ := openmldb.ParseDSN("openmldb://127.0.0.1:8080/test_db")
+//	cfg.HTTPClient = &http.Client{Transport: transport}
+//	connector, _ := openmldb.NewConnector(cfg)
+//	db := sql.OpenDB(connector)
+func NewConnector(cfg *Config) (driver.Connector, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("invalid config: host not found")
+	}
+	if cfg.DB == "" {
+		return nil, fmt.Errorf("invalid config: DB name not found")
+	}
+
+	c := *cfg
+	if c.Mode == "" {
+		c.Mode = ModeOnline
+	} else if _, ok := allQueryMode[string(c.Mode)]; !ok {
+		return nil, fmt.Errorf("invalid mode: %s", string(c.Mode))
+	}
+	switch c.InterpolateParams {
+	case InterpolateNone, InterpolateAuto, InterpolateAll:
+	default:
+		return nil, fmt.Errorf("invalid interpolateParams: %s", c.InterpolateParams)
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+	if c.Logger != nil {
+		// outermost, to see the request modified by other hooks
+		c.Hooks = append([]Hook{newLogHook(&c)}, c.Hooks...)
+	}
+	if c.ReadOnly || c.Policy != nil || c.GuardOffline {
+		// innermost, to check the request sent
+		c.Hooks = append(c.Hooks[:len(c.Hooks):len(c.Hooks)], newPolicyHook(&c))
+	}
+	return &connecter{c}, nil
 }
 
 type connecter struct {
-	host string
-	db   string
-	mode queryMode
+	cfg Config
 }
 
 // Connect implements driver.Connector.
 func (c connecter) Connect(ctx context.Context) (driver.Conn, error) {
-	conn := &conn{host: c.host, db: c.db, mode: c.mode, closed: false}
+	conn := c.newConn()
 	if err := conn.Ping(ctx); err != nil {
 		return nil, err
 	}
 	return conn, nil
 }
 
+func (c connecter) newConn() *conn {
+	return &conn{host: c.cfg.Host, db: c.cfg.DB, mode: c.cfg.Mode, client: c.cfg.HTTPClient, hooks: c.cfg.Hooks,
+		interpolate: c.cfg.InterpolateParams, times: timeCodec{loc: c.cfg.Loc, raw: c.cfg.RawTime}, closed: false}
+}
+
 // Driver implements driver.Connector.
 func (connecter) Driver() driver.Driver {
 	return &openmldbDriver{}
@@ -2,6 +2,7 @@ package openmldb
 
 import (
 	"errors"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,3 +33,43 @@ func Test_parseDsn(t *testing.T) {
 		}
 	}
 }
+
+func TestNewConnector(t *testing.T) {
+	cfg, err := ParseDSN("openmldb://127.0.0.1:8080/test_db?mode=offsync")
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{Host: "127.0.0.1:8080", DB: "test_db", Mode: ModeOffsync}, cfg)
+
+	connector, err := NewConnector(&Config{Host: "127.0.0.1:8080", DB: "test_db"})
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Host: "127.0.0.1:8080", DB: "test_db", Mode: ModeOnline, HTTPClient: http.DefaultClient}, connector.(*connecter).cfg)
+
+	_, err = NewConnector(&Config{DB: "test_db"})
+	assert.EqualError(t, err, "invalid config: host not found")
+	_, err = NewConnector(&Config{Host: "127.0.0.1:8080", DB: "test_db", Mode: "request"})
+	assert.EqualError(t, err, "invalid mode: request")
+
+	cfg, err = ParseDSN("openmldb://127.0.0.1:8080/test_db?interpolateParams=auto")
+	assert.NoError(t, err)
+	assert.Equal(t, InterpolateAuto, cfg.InterpolateParams)
+	cfg, err = ParseDSN("openmldb://127.0.0.1:8080/test_db?interpolateParams=true")
+	assert.NoError(t, err)
+	assert.Equal(t, InterpolateAll, cfg.InterpolateParams)
+	_, err = ParseDSN("openmldb://127.0.0.1:8080/test_db?interpolateParams=yes")
+	assert.EqualError(t, err, "invalid interpolateParams: yes")
+	cfg, err = ParseDSN("openmldb://127.0.0.1:8080/test_db?readonly=true&guardOffline=1")
+	assert.NoError(t, err)
+	assert.True(t, cfg.ReadOnly)
+	assert.True(t, cfg.GuardOffline)
+	_, err = ParseDSN("openmldb://127.0.0.1:8080/test_db?readonly=yes")
+	assert.EqualError(t, err, "invalid readonly: yes")
+	cfg, err = ParseDSN("openmldb://127.0.0.1:8080/test_db?loc=Asia%2FShanghai&parseTime=false")
+	assert.NoError(t, err)
+	assert.Equal(t, "Asia/Shanghai", cfg.Loc.String())
+	assert.True(t, cfg.RawTime)
+	_, err = ParseDSN("openmldb://127.0.0.1:8080/test_db?loc=Mars")
+	assert.EqualError(t, err, "invalid loc: Mars")
+	_, err = ParseDSN("openmldb://127.0.0.1:8080/test_db?parseTime=no")
+	assert.EqualError(t, err, "invalid parseTime: no")
+	_, err = NewConnector(&Config{Host: "127.0.0.1:8080", DB: "test_db", InterpolateParams: "always"})
+	assert.EqualError(t, err, "invalid interpolateParams: always")
+}
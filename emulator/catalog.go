@@ -0,0 +1,202 @@
+package emulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type column struct {
+	name    string
+	typ     dataType
+	notNull bool
+	dflt    any
+}
+
+type index struct {
+	name    string
+	keys    []string
+	ts      string
+	ttl     []string
+	ttlType string
+}
+
+type table struct {
+	name    string
+	columns []column
+	indexes []index
+	options map[string]string
+	rows    [][]any
+}
+
+func (t *table) columnIndex(name string) int {
+	for i, c := range t.columns {
+		if strings.EqualFold(c.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+type deployment struct {
+	name    string
+	sql     string
+	query   *selectStmt
+	options map[string]string
+}
+
+type database struct {
+	name        string
+	tables      map[string]*table
+	deployments map[string]*deployment
+}
+
+func newDatabase(name string) *database {
+	return &database{name: name, tables: map[string]*table{}, deployments: map[string]*deployment{}}
+}
+
+// catalog holds all databases, guarded by mu
+type catalog struct {
+	mu  sync.RWMutex
+	dbs map[string]*database
+}
+
+// database returns the database by name, created on first use since the api server
+// requires database in path of every request
+func (c *catalog) database(name string) *database {
+	db, ok := c.dbs[name]
+	if !ok {
+		db = newDatabase(name)
+		c.dbs[name] = db
+	}
+	return db
+}
+
+func (db *database) table(name string) (*table, error) {
+	t, ok := db.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("table %s not found in database %s", name, db.name)
+	}
+	return t, nil
+}
+
+func (db *database) tableNames() []string {
+	names := make([]string, 0, len(db.tables))
+	for name := range db.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (db *database) deploymentNames() []string {
+	names := make([]string, 0, len(db.deployments))
+	for name := range db.deployments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (db *database) createTable(stmt *createTableStmt) error {
+	if _, ok := db.tables[stmt.name]; ok {
+		if stmt.ifNotExists {
+			return nil
+		}
+		return fmt.Errorf("table %s already exists", stmt.name)
+	}
+	if len(stmt.columns) == 0 {
+		return fmt.Errorf("table %s has no column", stmt.name)
+	}
+
+	t := &table{name: stmt.name, options: stmt.options}
+	for _, def := range stmt.columns {
+		if t.columnIndex(def.name) >= 0 {
+			return fmt.Errorf("duplicate column %s", def.name)
+		}
+		col := column{name: def.name, typ: def.typ, notNull: def.notNull}
+		if def.dflt != nil {
+			v, err := evalConst(def.dflt)
+			if err != nil {
+				return fmt.Errorf("default value of column %s: %w", def.name, err)
+			}
+			if col.dflt, err = coerce(v, def.typ); err != nil {
+				return fmt.Errorf("default value of column %s: %w", def.name, err)
+			}
+		}
+		t.columns = append(t.columns, col)
+	}
+
+	for i, def := range stmt.indexes {
+		if def.name == "" {
+			def.name = fmt.Sprintf("INDEX_%d", i)
+		}
+		if err := t.addIndex(def); err != nil {
+			return err
+		}
+	}
+	if len(t.indexes) == 0 {
+		// default index on the first column
+		t.indexes = append(t.indexes, index{name: "INDEX_0", keys: []string{t.columns[0].name}, ttlType: "absolute"})
+	}
+
+	db.tables[stmt.name] = t
+	return nil
+}
+
+func (t *table) addIndex(def indexDef) error {
+	for _, idx := range t.indexes {
+		if strings.EqualFold(idx.name, def.name) {
+			return fmt.Errorf("index %s already exists in table %s", def.name, t.name)
+		}
+	}
+	if len(def.keys) == 0 {
+		return fmt.Errorf("index %s has no key", def.name)
+	}
+	for _, key := range def.keys {
+		if t.columnIndex(key) < 0 {
+			return fmt.Errorf("index key %s not found in table %s", key, t.name)
+		}
+	}
+	if def.ts != "" {
+		i := t.columnIndex(def.ts)
+		if i < 0 {
+			return fmt.Errorf("index ts %s not found in table %s", def.ts, t.name)
+		}
+		if typ := t.columns[i].typ; typ != typeTimestamp && typ != typeInt64 {
+			return fmt.Errorf("index ts %s should be timestamp or bigint", def.ts)
+		}
+	}
+	ttlType := def.ttlType
+	if ttlType == "" {
+		ttlType = "absolute"
+	}
+	t.indexes = append(t.indexes, index{name: def.name, keys: def.keys, ts: def.ts, ttl: def.ttl, ttlType: ttlType})
+	return nil
+}
+
+// missing is the value of column not given in insert
+type missing struct{}
+
+// insert inserts a row of values by column, in the order of table schema.
+// Columns with missing value are inserted as default value, or NULL.
+func (t *table) insert(values []any) error {
+	row := make([]any, len(t.columns))
+	for i, col := range t.columns {
+		v := values[i]
+		if _, ok := v.(missing); ok {
+			v = col.dflt
+		}
+		if v == nil && col.notNull {
+			return fmt.Errorf("column %s is NOT NULL", col.name)
+		}
+		cv, err := coerce(v, col.typ)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", col.name, err)
+		}
+		row[i] = cv
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
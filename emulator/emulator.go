@@ -0,0 +1,631 @@
+// Package emulator is an in-process emulator of OpenMLDB, for local development and tests
+// without an OpenMLDB cluster.
+//
+// Emulator serves the same HTTP API as the api server the driver talks to, and keeps tables
+// in memory. It supports a subset of OpenMLDB SQL:
+//   - CREATE/DROP DATABASE, CREATE/DROP TABLE with INDEX definitions, CREATE/DROP INDEX
+//   - INSERT with multiple rows and parameters
+//   - SELECT with WHERE, LIMIT, LAST JOIN and window aggregations over ROWS or ROWS_RANGE frames
+//   - DEPLOY and DROP DEPLOYMENT, and calling deployments in request mode
+//   - SHOW TABLES/DEPLOYMENTS/DATABASES, while SET and USE are accepted and ignored
+//   - SELECT INTO OUTFILE, with the result not written
+//   - SHOW JOBS, SHOW JOB and STOP JOB of queries in offline modes, see SetJobDuration
+//
+// Online and offline share the same storage. TTL is recorded but not enforced.
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Emulator is an in-memory OpenMLDB, implements http.Handler for the api server HTTP API.
+type Emulator struct {
+	catalog catalog
+	jobs    jobs
+}
+
+// New creates an empty Emulator.
+func New() *Emulator {
+	return &Emulator{catalog: catalog{dbs: map[string]*database{}}}
+}
+
+// Server is an Emulator served over HTTP on local address.
+type Server struct {
+	*Emulator
+	srv *httptest.Server
+}
+
+// NewServer starts a new Emulator on a local address, it should be closed by Close when finished.
+func NewServer() *Server {
+	e := New()
+	return &Server{Emulator: e, srv: httptest.NewServer(e)}
+}
+
+// Host returns the host:port the server listens on.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.srv.URL, "http://")
+}
+
+// DSN returns the data source name to open database db on the server.
+func (s *Server) DSN(db string) string {
+	return fmt.Sprintf("openmldb://%s/%s", s.Host(), db)
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+type queryReq struct {
+	Mode    string `json:"mode"`
+	SQL     string `json:"sql"`
+	Timeout int64  `json:"timeout"`
+	Input   *struct {
+		Schema []string `json:"schema"`
+		Data   []any    `json:"data"`
+	} `json:"input,omitempty"`
+}
+
+type deploymentReq struct {
+	Input      [][]any `json:"input"`
+	NeedSchema bool    `json:"need_schema"`
+}
+
+type putReq struct {
+	Value [][]any `json:"value"`
+}
+
+// ServeHTTP implements http.Handler.
+func (e *Emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp, err := e.handle(r)
+	if err != nil {
+		resp = map[string]any{"code": -1, "msg": err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (e *Emulator) handle(r *http.Request) (map[string]any, error) {
+	p := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	ok := map[string]any{"code": 0, "msg": "ok"}
+
+	switch {
+	case r.Method == "GET" && len(p) == 1 && p[0] == "refresh":
+		return ok, nil
+	case r.Method == "GET" && len(p) == 1 && p[0] == "dbs":
+		ok["dbs"] = e.databases()
+		return ok, nil
+	case len(p) < 2 || p[0] != "dbs":
+		return nil, fmt.Errorf("unsupported request %s %s", r.Method, r.URL.Path)
+	}
+
+	db := p[1]
+	switch {
+	case r.Method == "POST" && len(p) == 2:
+		var req queryReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		var params []any
+		if req.Input != nil {
+			var err error
+			if params, err = decodeParams(req.Input.Schema, req.Input.Data); err != nil {
+				return nil, err
+			}
+		}
+		run := func() (*resultSet, error) { return e.exec(db, req.SQL, params...) }
+		var (
+			rs  *resultSet
+			err error
+		)
+		stmt, _ := parse(req.SQL)
+		switch {
+		case req.Mode == "offsync" && isJob(stmt):
+			rs, err = e.runJob(r.Context(), stmt, req.SQL, req.Timeout, run)
+		case req.Mode == "offasync" && isJob(stmt):
+			rs, err = e.submitJob(stmt, req.SQL, run)
+		default:
+			rs, err = run()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rs != nil {
+			ok["data"] = map[string]any{"schema": rs.typeNames(), "data": rs.encodedRows()}
+		}
+		return ok, nil
+	case r.Method == "GET" && len(p) == 3 && p[2] == "tables":
+		ok["tables"] = e.tables(db)
+		return ok, nil
+	case r.Method == "GET" && len(p) == 4 && p[2] == "tables":
+		t, err := e.table(db, p[3])
+		if err != nil {
+			return nil, err
+		}
+		ok["table"] = t
+		return ok, nil
+	case r.Method == "PUT" && len(p) == 4 && p[2] == "tables":
+		var req putReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		return ok, e.put(db, p[3], req.Value)
+	case r.Method == "GET" && len(p) == 3 && p[2] == "deployments":
+		ok["deployments"] = e.deployments(db)
+		return ok, nil
+	case r.Method == "GET" && len(p) == 4 && p[2] == "deployments":
+		d, err := e.deployment(db, p[3])
+		if err != nil {
+			return nil, err
+		}
+		ok["data"] = d
+		return ok, nil
+	case r.Method == "POST" && len(p) == 4 && p[2] == "deployments":
+		var req deploymentReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		rs, err := e.callDeployment(db, p[3], req.Input...)
+		if err != nil {
+			return nil, err
+		}
+		data := map[string]any{"data": rs.encodedRows(), "common_cols_data": []any{}}
+		if req.NeedSchema {
+			schema := make([]map[string]string, len(rs.names))
+			for i, name := range rs.names {
+				schema[i] = map[string]string{"name": name, "type": rs.types[i].String()}
+			}
+			data["schema"] = schema
+		}
+		ok["data"] = data
+		return ok, nil
+	}
+	return nil, fmt.Errorf("unsupported request %s %s", r.Method, r.URL.Path)
+}
+
+func decodeParams(schema []string, data []any) ([]any, error) {
+	if len(schema) != len(data) {
+		return nil, fmt.Errorf("invalid input: %d types in schema, but %d values", len(schema), len(data))
+	}
+	params := make([]any, len(data))
+	for i, v := range data {
+		typ, ok := parseType(schema[i])
+		if !ok {
+			return nil, fmt.Errorf("invalid input: unknown type %s", schema[i])
+		}
+		pv, err := decodeValue(v, typ)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input at %d: %w", i, err)
+		}
+		params[i] = pv
+	}
+	return params, nil
+}
+
+func (rs *resultSet) typeNames() []string {
+	names := make([]string, len(rs.types))
+	for i, t := range rs.types {
+		names[i] = t.String()
+	}
+	return names
+}
+
+func (rs *resultSet) encodedRows() [][]any {
+	rows := make([][]any, len(rs.rows))
+	for i, row := range rs.rows {
+		rows[i] = make([]any, len(row))
+		for j, v := range row {
+			rows[i][j] = encodeValue(v, rs.types[j])
+		}
+	}
+	return rows
+}
+
+// exec executes SQL in database db with parameters. Result is nil for statement not a query.
+func (e *Emulator) exec(db string, sql string, params ...any) (*resultSet, error) {
+	stmt, err := parse(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	e.catalog.mu.Lock()
+	defer e.catalog.mu.Unlock()
+
+	switch s := stmt.(type) {
+	case *createDatabaseStmt:
+		if _, ok := e.catalog.dbs[s.name]; ok && !s.ifNotExists {
+			return nil, fmt.Errorf("database %s already exists", s.name)
+		}
+		e.catalog.database(s.name)
+		return nil, nil
+	case *dropDatabaseStmt:
+		d, ok := e.catalog.dbs[s.name]
+		if !ok {
+			if s.ifExists {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("database %s not found", s.name)
+		}
+		if len(d.tables) > 0 {
+			return nil, fmt.Errorf("database %s is not empty", s.name)
+		}
+		delete(e.catalog.dbs, s.name)
+		return nil, nil
+	}
+
+	d := e.catalog.database(db)
+	switch s := stmt.(type) {
+	case *createTableStmt:
+		return nil, d.createTable(s)
+	case *dropTableStmt:
+		if _, ok := d.tables[s.name]; !ok {
+			if s.ifExists {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("table %s not found in database %s", s.name, db)
+		}
+		delete(d.tables, s.name)
+		return nil, nil
+	case *createIndexStmt:
+		t, err := d.table(s.table)
+		if err != nil {
+			return nil, err
+		}
+		return nil, t.addIndex(s.index)
+	case *dropIndexStmt:
+		t, err := d.table(s.table)
+		if err != nil {
+			return nil, err
+		}
+		for i, idx := range t.indexes {
+			if strings.EqualFold(idx.name, s.name) {
+				if len(t.indexes) == 1 {
+					return nil, fmt.Errorf("can not drop the last index of table %s", s.table)
+				}
+				t.indexes = append(t.indexes[:i], t.indexes[i+1:]...)
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("index %s not found in table %s", s.name, s.table)
+	case *insertStmt:
+		return nil, d.insert(s, params)
+	case *selectStmt:
+		if err := prepareSelect(s); err != nil {
+			return nil, err
+		}
+		rs, err := d.query(s, params, nil)
+		if s.outfile != "" {
+			return nil, err
+		}
+		return rs, err
+	case *deployStmt:
+		if _, ok := d.deployments[s.name]; ok {
+			if s.ifNotExists {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("deployment %s already exists", s.name)
+		}
+		if s.query.from.name == "" {
+			return nil, fmt.Errorf("deployment %s has no table", s.name)
+		}
+		if err := prepareSelect(s.query); err != nil {
+			return nil, err
+		}
+		// validate the query
+		if _, err := d.query(s.query, nil, nil); err != nil {
+			return nil, err
+		}
+		d.deployments[s.name] = &deployment{name: s.name, sql: s.sql, query: s.query, options: s.options}
+		return nil, nil
+	case *dropDeploymentStmt:
+		if _, ok := d.deployments[s.name]; !ok {
+			return nil, fmt.Errorf("deployment %s not found", s.name)
+		}
+		delete(d.deployments, s.name)
+		return nil, nil
+	case *showStmt:
+		rs := &resultSet{types: []dataType{typeString}}
+		var names []string
+		switch s.what {
+		case "tables":
+			rs.names, names = []string{"Tables"}, d.tableNames()
+		case "deployments":
+			rs.names, names = []string{"Deployments"}, d.deploymentNames()
+		case "jobs":
+			return e.showJobs()
+		default:
+			rs.names = []string{"Databases"}
+			for name := range e.catalog.dbs {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			rs.rows = append(rs.rows, []any{name})
+		}
+		return rs, nil
+	case *jobStmt:
+		if s.stop {
+			return e.stopJob(s.id)
+		}
+		return e.showJobs(s.id)
+	case *noopStmt:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported statement %T", stmt)
+}
+
+// isJob reports whether stmt runs as a job in offline modes, i.e. queries and SELECT INTO
+func isJob(stmt statement) bool {
+	_, ok := stmt.(*selectStmt)
+	return ok
+}
+
+func (d *database) insert(s *insertStmt, params []any) error {
+	t, err := d.table(s.table)
+	if err != nil {
+		return err
+	}
+
+	// position in table schema of each value
+	positions := make([]int, len(t.columns))
+	if len(s.columns) == 0 {
+		for i := range positions {
+			positions[i] = i
+		}
+	} else {
+		positions = positions[:0]
+		for _, name := range s.columns {
+			i := t.columnIndex(name)
+			if i < 0 {
+				return fmt.Errorf("column %s not found in table %s", name, s.table)
+			}
+			positions = append(positions, i)
+		}
+	}
+
+	paramEnv := &env{params: params}
+	var rows [][]any
+	for _, exprs := range s.rows {
+		if len(exprs) != len(positions) {
+			return fmt.Errorf("insert %d values, expect %d", len(exprs), len(positions))
+		}
+		values := make([]any, len(t.columns))
+		for i := range values {
+			values[i] = missing{}
+		}
+		for i, x := range exprs {
+			v, err := eval(x, paramEnv)
+			if err != nil {
+				return err
+			}
+			values[positions[i]] = v
+		}
+		rows = append(rows, values)
+	}
+
+	// validate all rows before insert any
+	n := len(t.rows)
+	for _, values := range rows {
+		if err := t.insert(values); err != nil {
+			t.rows = t.rows[:n]
+			return err
+		}
+	}
+	return nil
+}
+
+// callDeployment calls deployment in request mode, with each row processed as a request row.
+func (e *Emulator) callDeployment(db string, name string, rows ...[]any) (*resultSet, error) {
+	e.catalog.mu.Lock()
+	defer e.catalog.mu.Unlock()
+
+	d := e.catalog.database(db)
+	dep, ok := d.deployments[name]
+	if !ok {
+		return nil, fmt.Errorf("deployment %s not found", name)
+	}
+	main, err := d.table(dep.query.from.name)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *resultSet
+	for i, row := range rows {
+		if len(row) != len(main.columns) {
+			return nil, fmt.Errorf("request row %d has %d values, expect %d", i, len(row), len(main.columns))
+		}
+		request := make([]any, len(row))
+		for j, v := range row {
+			if request[j], err = decodeValue(v, main.columns[j].typ); err != nil {
+				return nil, fmt.Errorf("request row %d column %s: %w", i, main.columns[j].name, err)
+			}
+		}
+
+		rs, err := d.query(dep.query, nil, request)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = rs
+		} else {
+			result.rows = append(result.rows, rs.rows...)
+		}
+	}
+	if result == nil {
+		// no request row, the schema only
+		return d.query(dep.query, nil, nil)
+	}
+	return result, nil
+}
+
+func (e *Emulator) databases() []string {
+	e.catalog.mu.Lock()
+	defer e.catalog.mu.Unlock()
+
+	names := []string{}
+	for name := range e.catalog.dbs {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (e *Emulator) tables(db string) []map[string]any {
+	e.catalog.mu.Lock()
+	defer e.catalog.mu.Unlock()
+
+	d := e.catalog.database(db)
+	tables := []map[string]any{}
+	for _, name := range d.tableNames() {
+		tables = append(tables, tableInfo(d.tables[name]))
+	}
+	return tables
+}
+
+func (e *Emulator) table(db string, name string) (map[string]any, error) {
+	e.catalog.mu.Lock()
+	defer e.catalog.mu.Unlock()
+
+	t, err := e.catalog.database(db).table(name)
+	if err != nil {
+		return nil, err
+	}
+	return tableInfo(t), nil
+}
+
+// tableInfo returns table in the format of table API
+func tableInfo(t *table) map[string]any {
+	cols := make([]map[string]any, len(t.columns))
+	for i, c := range t.columns {
+		cols[i] = map[string]any{"name": c.name, "data_type": c.typ.protoName(), "not_null": c.notNull}
+	}
+	keys := make([]map[string]any, len(t.indexes))
+	for i, idx := range t.indexes {
+		absTTL, latTTL := parseTTL(idx)
+		key := map[string]any{
+			"index_name": idx.name,
+			"col_name":   idx.keys,
+			"ttl": map[string]any{
+				"ttl_type": ttlTypeName(idx.ttlType),
+				"abs_ttl":  absTTL,
+				"lat_ttl":  latTTL,
+			},
+		}
+		if idx.ts != "" {
+			key["ts_name"] = idx.ts
+		}
+		keys[i] = key
+	}
+
+	partitions, replicas, storage := 8, 1, "memory"
+	if n, err := strconv.Atoi(t.options["partitionnum"]); err == nil {
+		partitions = n
+	}
+	if n, err := strconv.Atoi(t.options["replicanum"]); err == nil {
+		replicas = n
+	}
+	if s, ok := t.options["storage_mode"]; ok {
+		storage = strings.ToLower(s)
+	}
+	return map[string]any{
+		"name":          t.name,
+		"column_desc":   cols,
+		"column_key":    keys,
+		"partition_num": partitions,
+		"replica_num":   replicas,
+		"storage_mode":  storage,
+	}
+}
+
+func ttlTypeName(ttlType string) string {
+	switch ttlType {
+	case "latest":
+		return "kLatestTime"
+	case "absorlat":
+		return "kAbsOrLat"
+	case "absandlat":
+		return "kAbsAndLat"
+	default:
+		return "kAbsoluteTime"
+	}
+}
+
+// parseTTL returns abs ttl in minutes and lat ttl in count of index
+func parseTTL(idx index) (int64, int64) {
+	parseAbs := func(s string) int64 {
+		s = strings.ToLower(strings.TrimSpace(s))
+		units := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}
+		if d, ok := units[s[max(len(s)-1, 0):]]; ok && len(s) > 1 {
+			n, _ := strconv.ParseInt(s[:len(s)-1], 10, 64)
+			return int64(time.Duration(n) * d / time.Minute)
+		}
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	}
+	parseLat := func(s string) int64 {
+		n, _ := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		return n
+	}
+
+	if len(idx.ttl) == 0 {
+		return 0, 0
+	}
+	switch idx.ttlType {
+	case "latest":
+		return 0, parseLat(idx.ttl[0])
+	case "absorlat", "absandlat":
+		if len(idx.ttl) < 2 {
+			return parseAbs(idx.ttl[0]), 0
+		}
+		return parseAbs(idx.ttl[0]), parseLat(idx.ttl[1])
+	default:
+		return parseAbs(idx.ttl[0]), 0
+	}
+}
+
+func (e *Emulator) put(db string, name string, rows [][]any) error {
+	e.catalog.mu.Lock()
+	defer e.catalog.mu.Unlock()
+
+	t, err := e.catalog.database(db).table(name)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if len(row) != len(t.columns) {
+			return fmt.Errorf("put %d values, expect %d", len(row), len(t.columns))
+		}
+		values := make([]any, len(row))
+		for i, v := range row {
+			if values[i], err = decodeValue(v, t.columns[i].typ); err != nil {
+				return fmt.Errorf("column %s: %w", t.columns[i].name, err)
+			}
+		}
+		if err := t.insert(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Emulator) deployments(db string) []string {
+	e.catalog.mu.Lock()
+	defer e.catalog.mu.Unlock()
+	return e.catalog.database(db).deploymentNames()
+}
+
+func (e *Emulator) deployment(db string, name string) (map[string]any, error) {
+	e.catalog.mu.Lock()
+	defer e.catalog.mu.Unlock()
+
+	dep, ok := e.catalog.database(db).deployments[name]
+	if !ok {
+		return nil, fmt.Errorf("deployment %s not found", name)
+	}
+	return map[string]any{"name": dep.name, "procedure": dep.sql}, nil
+}
@@ -0,0 +1,246 @@
+package emulator_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+
+	db, err := sql.Open("openmldb", srv.DSN("demo_db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	_, err := db.ExecContext(context.Background(), query, args...)
+	require.NoError(t, err, query)
+}
+
+func queryAll(t *testing.T, db *sql.DB, query string, args ...any) [][]any {
+	t.Helper()
+	rows, err := db.QueryContext(context.Background(), query, args...)
+	require.NoError(t, err, query)
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	require.NoError(t, err)
+	var result [][]any
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		require.NoError(t, rows.Scan(ptrs...))
+		result = append(result, values)
+	}
+	require.NoError(t, rows.Err())
+	return result
+}
+
+func TestDDL(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, "CREATE DATABASE IF NOT EXISTS demo_db")
+	mustExec(t, db, "USE demo_db")
+	mustExec(t, db, "SET @@execute_mode='online'")
+	mustExec(t, db, `CREATE TABLE t1 (
+		c1 string NOT NULL,
+		c2 int DEFAULT 7,
+		ts timestamp,
+		INDEX(KEY=c1, TS=ts, TTL=10d, TTL_TYPE=absolute)
+	) OPTIONS (partitionnum=2, replicanum=1)`)
+	mustExec(t, db, "CREATE TABLE IF NOT EXISTS t1 (c1 string)")
+	mustExec(t, db, "CREATE INDEX idx2 ON t1 (c2) OPTIONS (TS=ts, TTL=10, TTL_TYPE=latest)")
+
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string)")
+	assert.EqualError(t, err, "execute error: table t1 already exists")
+	_, err = db.ExecContext(ctx, "CREATE INDEX idx3 ON t1 (c3)")
+	assert.EqualError(t, err, "execute error: index key c3 not found in table t1")
+	_, err = db.ExecContext(ctx, "CREATE TABLE t2 (c1 string, INDEX(KEY=c1, TS=c1))")
+	assert.EqualError(t, err, "execute error: index ts c1 should be timestamp or bigint")
+
+	assert.Equal(t, [][]any{{"t1"}}, queryAll(t, db, "SHOW TABLES"))
+
+	mustExec(t, db, "DROP INDEX t1.idx2")
+	mustExec(t, db, "DROP TABLE t1")
+	mustExec(t, db, "DROP TABLE IF EXISTS t1")
+	_, err = db.ExecContext(ctx, "DROP TABLE t1")
+	assert.EqualError(t, err, "execute error: table t1 not found in database demo_db")
+	assert.Empty(t, queryAll(t, db, "SHOW TABLES"))
+}
+
+func TestInsertSelect(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, "CREATE TABLE t1 (c1 string NOT NULL, c2 int DEFAULT 7, c3 double, ts timestamp, dt date)")
+	mustExec(t, db, "INSERT INTO t1 VALUES ('aa', 1, 1.5, 1000, '2022-10-10'), ('bb', 2, 2.5, 2000, '2022-10-11')")
+	mustExec(t, db, "INSERT INTO t1 (c1, ts) VALUES (?, ?)", "cc", time.UnixMilli(3000))
+
+	_, err := db.ExecContext(ctx, "INSERT INTO t1 (c2) VALUES (1)")
+	assert.EqualError(t, err, "execute error: column c1 is NOT NULL")
+
+	rows := queryAll(t, db, "SELECT * FROM t1 WHERE c2 > ? AND c1 != 'bb'", int32(0))
+	assert.Equal(t, [][]any{
+		{"aa", int32(1), float64(1.5), time.UnixMilli(1000), time.Date(2022, time.October, 10, 0, 0, 0, 0, time.UTC)},
+		{"cc", int32(7), nil, time.UnixMilli(3000), nil},
+	}, rows)
+
+	rows = queryAll(t, db, "SELECT c1, c2 * 2 + 1 AS v, c3 IS NULL FROM t1 WHERE c1 LIKE '_b' OR c1 IN ('cc') LIMIT 1")
+	assert.Equal(t, [][]any{{"bb", int32(5), false}}, rows)
+
+	rows = queryAll(t, db, "SELECT count(*), sum(c2), max(c3) FROM t1")
+	assert.Equal(t, [][]any{{int64(3), int64(10), float64(2.5)}}, rows)
+
+	_, err = db.QueryContext(ctx, "SELECT c9 FROM t1")
+	assert.EqualError(t, err, "execute error: column c9 not found")
+}
+
+func TestLastJoin(t *testing.T) {
+	db := openDB(t)
+
+	mustExec(t, db, "CREATE TABLE t1 (id int, ts timestamp)")
+	mustExec(t, db, "CREATE TABLE t2 (id int, val string, ts timestamp)")
+	mustExec(t, db, "INSERT INTO t1 VALUES (1, 1000), (2, 2000), (3, 3000)")
+	mustExec(t, db, "INSERT INTO t2 VALUES (1, 'new', 2000), (1, 'old', 1000), (2, 'x', 1000), (2, 'y', 1000)")
+
+	rows := queryAll(t, db, "SELECT t1.id, r.val FROM t1 LAST JOIN t2 AS r ORDER BY r.ts ON t1.id = r.id")
+	assert.Equal(t, [][]any{{int32(1), "new"}, {int32(2), "y"}, {int32(3), nil}}, rows)
+
+	rows = queryAll(t, db, "SELECT t1.id, t2.val FROM t1 LAST JOIN t2 ON t1.id = t2.id AND t2.ts <= t1.ts")
+	assert.Equal(t, [][]any{{int32(1), "old"}, {int32(2), "y"}, {int32(3), nil}}, rows)
+}
+
+func TestWindow(t *testing.T) {
+	db := openDB(t)
+
+	mustExec(t, db, "CREATE TABLE t1 (c1 string, c2 int, ts timestamp, INDEX(KEY=c1, TS=ts))")
+	mustExec(t, db, `INSERT INTO t1 VALUES ('a', 1, 1000), ('a', 2, 2000), ('b', 10, 1500), ('a', 3, 4000), ('a', 4, 4500)`)
+
+	rows := queryAll(t, db, `SELECT c1, c2,
+		sum(c2) OVER w1 AS s,
+		count(c2) OVER w2 AS n,
+		max(c2) OVER (PARTITION BY c1 ORDER BY ts ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) AS m
+		FROM t1
+		WINDOW w1 AS (PARTITION BY c1 ORDER BY ts ROWS_RANGE BETWEEN 2s PRECEDING AND CURRENT ROW),
+		w2 AS (PARTITION BY c1 ORDER BY ts ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW EXCLUDE CURRENT_ROW)`)
+	assert.Equal(t, [][]any{
+		{"a", int32(1), int64(1), int64(0), int32(1)},
+		{"a", int32(2), int64(3), int64(1), int32(2)},
+		{"b", int32(10), int64(10), int64(0), int32(10)},
+		{"a", int32(3), int64(5), int64(2), int32(3)},
+		{"a", int32(4), int64(7), int64(3), int32(4)},
+	}, rows)
+}
+
+func TestDeployment(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, "CREATE TABLE t1 (c1 string, c2 int, ts timestamp, INDEX(KEY=c1, TS=ts))")
+	mustExec(t, db, "INSERT INTO t1 VALUES ('a', 1, 1000), ('a', 2, 2000), ('b', 10, 1500)")
+	mustExec(t, db, `DEPLOY demo OPTIONS(RANGE_BIAS='inf') SELECT c1, sum(c2) OVER w AS s FROM t1
+		WINDOW w AS (PARTITION BY c1 ORDER BY ts ROWS BETWEEN 2 PRECEDING AND CURRENT ROW)`)
+	mustExec(t, db, "DEPLOY IF NOT EXISTS demo SELECT c1 FROM t1")
+	assert.Equal(t, [][]any{{"demo"}}, queryAll(t, db, "SHOW DEPLOYMENTS"))
+
+	result, err := openmldb.CallDeployment(ctx, db, "demo",
+		[]any{"a", int32(5), time.UnixMilli(3000)},
+		[]any{"c", int32(1), time.UnixMilli(3000)},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, &openmldb.DeploymentResult{
+		Columns: []string{"c1", "s"},
+		Rows:    [][]any{{"a", int64(8)}, {"c", int64(1)}},
+	}, result)
+
+	// request rows are not inserted
+	assert.Equal(t, [][]any{{int64(3)}}, queryAll(t, db, "SELECT count(*) FROM t1"))
+
+	_, err = openmldb.CallDeployment(ctx, db, "demo", []any{"a"})
+	assert.EqualError(t, err, "call deployment error: request row 0 has 1 values, expect 3")
+
+	mustExec(t, db, "DROP DEPLOYMENT demo")
+	_, err = openmldb.CallDeployment(ctx, db, "demo", []any{"a", int32(5), time.UnixMilli(3000)})
+	assert.EqualError(t, err, "call deployment error: deployment demo not found")
+}
+
+func TestPutRow(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	mustExec(t, db, "CREATE TABLE t1 (c1 string NOT NULL, c2 int, dt date)")
+	require.NoError(t, openmldb.PutRow(ctx, db, "t1", map[string]any{"c1": "aa", "dt": time.Date(2022, time.October, 10, 0, 0, 0, 0, time.UTC)}))
+	assert.EqualError(t, openmldb.PutRow(ctx, db, "t1", map[string]any{"c2": 1}),
+		"row 0: column 'c1': NULL value for NOT NULL column")
+
+	assert.Equal(t, [][]any{{"aa", nil, time.Date(2022, time.October, 10, 0, 0, 0, 0, time.UTC)}},
+		queryAll(t, db, "SELECT * FROM t1"))
+}
+
+func TestJobs(t *testing.T) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	db, err := sql.Open("openmldb", srv.DSN("demo_db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	offline, err := sql.Open("openmldb", srv.DSN("demo_db")+"?mode=offsync")
+	require.NoError(t, err)
+	t.Cleanup(func() { offline.Close() })
+
+	mustExec(t, db, "CREATE TABLE t1 (c1 string, c2 int)")
+	// connected ahead, ping runs as a job in offsync mode
+	conn, err := offline.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	srv.SetJobDuration(time.Hour)
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = conn.ExecContext(tctx, "SELECT c1 FROM t1 INTO OUTFILE '/tmp/t1.csv'")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// submitted in offasync mode and stopped by driver once cancelled
+	jobs := queryAll(t, db, "SHOW JOBS")
+	require.Len(t, jobs, 2)
+	assert.Equal(t, []any{int32(2), "RunBatchSql", "STOPPED"}, jobs[1][:3])
+	assert.Equal(t, "SELECT c1 FROM t1 INTO OUTFILE '/tmp/t1.csv'", jobs[1][5])
+
+	// job waited up to the request timeout, and keeps running
+	resp, err := http.Post("http://"+srv.Host()+"/dbs/demo_db", "application/json",
+		strings.NewReader(`{"mode": "offsync", "sql": "SELECT c2 FROM t1", "timeout": 20}`))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"code": -1, "msg": "job 3 timeout after 20ms"}`, string(body))
+
+	job := queryAll(t, db, "SHOW JOB 3")
+	assert.Equal(t, "RUNNING", job[0][2])
+	assert.Nil(t, job[0][4])
+	job = queryAll(t, db, "STOP JOB 3")
+	assert.Equal(t, "STOPPED", job[0][2])
+	assert.NotNil(t, job[0][4])
+
+	_, err = db.QueryContext(ctx, "SHOW JOB 4")
+	assert.EqualError(t, err, "execute error: job 4 not found")
+}
@@ -0,0 +1,715 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// scopeTable is a table visible in query, referenced by alias or table name
+type scopeTable struct {
+	ref   string
+	table *table
+}
+
+// scope is the tables of a query, the FROM table followed by LAST JOIN tables
+type scope []scopeTable
+
+// joinedRow holds a row of each table in scope, nil if no row joined
+type joinedRow [][]any
+
+func (s scope) resolve(c *columnRef) (int, int, error) {
+	ti, ci := -1, -1
+	for i, st := range s {
+		if c.table != "" && !strings.EqualFold(c.table, st.ref) {
+			continue
+		}
+		if j := st.table.columnIndex(c.name); j >= 0 {
+			if ti >= 0 {
+				return 0, 0, fmt.Errorf("column %s is ambiguous", c)
+			}
+			ti, ci = i, j
+		}
+	}
+	if ti < 0 {
+		return 0, 0, fmt.Errorf("column %s not found", c)
+	}
+	return ti, ci, nil
+}
+
+// env is the context evaluating expression
+type env struct {
+	scope  scope
+	params []any
+	row    joinedRow
+	// frames are the window frames of current row, by window name
+	frames map[string][]joinedRow
+	// frame is the rows aggregated without window, i.e. all rows for global aggregation
+	frame []joinedRow
+}
+
+func (e *env) with(row joinedRow) *env {
+	c := *e
+	c.row = row
+	return &c
+}
+
+var aggregates = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+	"count_where": true, "sum_where": true, "avg_where": true, "min_where": true, "max_where": true,
+	"distinct_count": true, "lag": true, "first_value": true,
+}
+
+// evalConst evaluates expression without any column
+func evalConst(e expr) (any, error) {
+	return eval(e, &env{})
+}
+
+func eval(e expr, env *env) (any, error) {
+	switch x := e.(type) {
+	case *literal:
+		return x.v, nil
+	case *paramRef:
+		if x.index >= len(env.params) {
+			return nil, fmt.Errorf("parameter %d not given", x.index+1)
+		}
+		return env.params[x.index], nil
+	case *columnRef:
+		ti, ci, err := env.scope.resolve(x)
+		if err != nil {
+			return nil, err
+		}
+		if env.row == nil || env.row[ti] == nil {
+			return nil, nil
+		}
+		return env.row[ti][ci], nil
+	case *unaryExpr:
+		v, err := eval(x.x, env)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		if x.op == "not" {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("NOT on non-bool value %v", v)
+			}
+			return !b, nil
+		}
+		return arith("-", zeroOf(v), v)
+	case *binaryExpr:
+		return evalBinary(x, env)
+	case *isNullExpr:
+		v, err := eval(x.x, env)
+		if err != nil {
+			return nil, err
+		}
+		return (v == nil) != x.not, nil
+	case *inExpr:
+		v, err := eval(x.x, env)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		for _, item := range x.list {
+			iv, err := eval(item, env)
+			if err != nil {
+				return nil, err
+			}
+			if iv == nil {
+				continue
+			}
+			if c, err := compareValues(v, iv); err != nil {
+				return nil, err
+			} else if c == 0 {
+				return !x.not, nil
+			}
+		}
+		return x.not, nil
+	case *betweenExpr:
+		v, err := eval(x.x, env)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		lo, err := eval(x.lo, env)
+		if err != nil || lo == nil {
+			return nil, err
+		}
+		hi, err := eval(x.hi, env)
+		if err != nil || hi == nil {
+			return nil, err
+		}
+		c1, err := compareValues(v, lo)
+		if err != nil {
+			return nil, err
+		}
+		c2, err := compareValues(v, hi)
+		if err != nil {
+			return nil, err
+		}
+		return (c1 >= 0 && c2 <= 0) != x.not, nil
+	case *castExpr:
+		v, err := eval(x.x, env)
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := v.(time.Time); ok && x.typ.isNumeric() {
+			v = t.UnixMilli()
+		}
+		return coerce(v, x.typ)
+	case *caseExpr:
+		for i, w := range x.whens {
+			c, err := eval(w, env)
+			if err != nil {
+				return nil, err
+			}
+			if b, _ := c.(bool); b {
+				return eval(x.thens[i], env)
+			}
+		}
+		if x.els != nil {
+			return eval(x.els, env)
+		}
+		return nil, nil
+	case *funcCall:
+		if aggregates[x.name] {
+			frame := env.frame
+			if x.over != "" {
+				frame = env.frames[x.over]
+			}
+			return aggregate(x, frame, env)
+		}
+		args := make([]any, len(x.args))
+		for i, a := range x.args {
+			v, err := eval(a, env)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return callFunc(x.name, args)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", e)
+}
+
+func evalBinary(x *binaryExpr, env *env) (any, error) {
+	l, err := eval(x.l, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// three-valued logic
+	if x.op == "and" || x.op == "or" {
+		lb, lok := l.(bool)
+		if l != nil && !lok {
+			return nil, fmt.Errorf("%s on non-bool value %v", strings.ToUpper(x.op), l)
+		}
+		if lok && ((x.op == "and" && !lb) || (x.op == "or" && lb)) {
+			return lb, nil
+		}
+		r, err := eval(x.r, env)
+		if err != nil {
+			return nil, err
+		}
+		rb, rok := r.(bool)
+		if r != nil && !rok {
+			return nil, fmt.Errorf("%s on non-bool value %v", strings.ToUpper(x.op), r)
+		}
+		if rok && ((x.op == "and" && !rb) || (x.op == "or" && rb)) {
+			return rb, nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		return rb, nil
+	}
+
+	r, err := eval(x.r, env)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+
+	switch x.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		l, r = alignTimeOperands(l, r)
+		c, err := compareValues(l, r)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "=":
+			return c == 0, nil
+		case "!=":
+			return c != 0, nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case "like":
+		s, ok1 := l.(string)
+		pattern, ok2 := r.(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("LIKE on non-string value")
+		}
+		return likeMatch(s, pattern), nil
+	default:
+		return arith(x.op, l, r)
+	}
+}
+
+// alignTimeOperands converts the string operand compared with time into time
+func alignTimeOperands(l, r any) (any, any) {
+	if lt, ok := l.(time.Time); ok {
+		if s, ok := r.(string); ok {
+			if t, err := coerce(s, typeTimestamp); err == nil {
+				return lt, t
+			}
+		}
+	}
+	if _, ok := r.(time.Time); ok {
+		if _, ok := l.(string); ok {
+			r2, l2 := alignTimeOperands(r, l)
+			return l2, r2
+		}
+	}
+	return l, r
+}
+
+func likeMatch(s, pattern string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()).MatchString(s)
+}
+
+func zeroOf(v any) any {
+	switch v.(type) {
+	case int16:
+		return int16(0)
+	case int32:
+		return int32(0)
+	case float32:
+		return float32(0)
+	case float64:
+		return float64(0)
+	default:
+		return int64(0)
+	}
+}
+
+// arith computes arithmetic operation, integer operands result in the wider integer type,
+// floating operands result in double
+func arith(op string, l, r any) (any, error) {
+	lt, rt := typeOf(l), typeOf(r)
+	if lt == typeTimestamp {
+		lt, l = typeInt64, toInt64(l)
+	}
+	if rt == typeTimestamp {
+		rt, r = typeInt64, toInt64(r)
+	}
+	if !lt.isNumeric() || !rt.isNumeric() {
+		return nil, fmt.Errorf("arithmetic operation '%s' on non-numeric value %v, %v", op, l, r)
+	}
+
+	if lt.isInteger() && rt.isInteger() && op != "/" {
+		a, b := toInt64(l), toInt64(r)
+		var n int64
+		switch op {
+		case "+":
+			n = a + b
+		case "-":
+			n = a - b
+		case "*":
+			n = a * b
+		case "%", "div":
+			if b == 0 {
+				return nil, nil
+			}
+			if op == "%" {
+				n = a % b
+			} else {
+				n = a / b
+			}
+		default:
+			return nil, fmt.Errorf("unsupported operator %s", op)
+		}
+		return coerce(n, max(lt, rt))
+	}
+
+	a, b := toFloat64(l), toFloat64(r)
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, nil
+		}
+		return a / b, nil
+	case "%":
+		if b == 0 {
+			return nil, nil
+		}
+		return math.Mod(a, b), nil
+	case "div":
+		if b == 0 {
+			return nil, nil
+		}
+		return int64(a / b), nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s", op)
+}
+
+func aggregate(f *funcCall, frame []joinedRow, env *env) (any, error) {
+	// values of the first argument, and the condition of *_where functions
+	var values []any
+	for _, row := range frame {
+		rowEnv := env.with(row)
+		if strings.HasSuffix(f.name, "_where") {
+			if len(f.args) != 2 {
+				return nil, fmt.Errorf("%s expects 2 arguments", f.name)
+			}
+			cond, err := eval(f.args[1], rowEnv)
+			if err != nil {
+				return nil, err
+			}
+			if b, _ := cond.(bool); !b {
+				continue
+			}
+		}
+		if f.star {
+			values = append(values, true)
+			continue
+		}
+		if len(f.args) == 0 {
+			return nil, fmt.Errorf("%s expects argument", f.name)
+		}
+		v, err := eval(f.args[0], rowEnv)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	name := strings.TrimSuffix(f.name, "_where")
+	switch name {
+	case "lag", "first_value":
+		// frame is in ascending order, the current row is the last
+		offset := 0
+		if name == "lag" && len(f.args) > 1 {
+			n, err := evalConst(f.args[1])
+			if err != nil {
+				return nil, err
+			}
+			offset = int(toInt64(n))
+		}
+		if offset >= len(values) {
+			return nil, nil
+		}
+		return values[len(values)-1-offset], nil
+	case "count":
+		n := int64(0)
+		for _, v := range values {
+			if v != nil {
+				n++
+			}
+		}
+		return n, nil
+	case "distinct_count":
+		seen := map[any]bool{}
+		for _, v := range values {
+			if v != nil {
+				seen[v] = true
+			}
+		}
+		return int64(len(seen)), nil
+	}
+
+	var (
+		acc   any
+		n     int
+		fsum  float64
+		isInt = true
+	)
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		n++
+		switch name {
+		case "sum", "avg":
+			t := typeOf(v)
+			if !t.isNumeric() {
+				return nil, fmt.Errorf("%s on non-numeric value %v", f.name, v)
+			}
+			if !t.isInteger() {
+				isInt = false
+			}
+			fsum += toFloat64(v)
+			if acc == nil {
+				acc = int64(0)
+			}
+			acc = toInt64(acc) + toInt64(v)
+		case "min", "max":
+			if acc == nil {
+				acc = v
+				continue
+			}
+			c, err := compareValues(v, acc)
+			if err != nil {
+				return nil, err
+			}
+			if (name == "min" && c < 0) || (name == "max" && c > 0) {
+				acc = v
+			}
+		default:
+			return nil, fmt.Errorf("unknown aggregate function %s", f.name)
+		}
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	switch name {
+	case "sum":
+		if isInt {
+			return acc, nil
+		}
+		return fsum, nil
+	case "avg":
+		return fsum / float64(n), nil
+	default:
+		return acc, nil
+	}
+}
+
+func callFunc(name string, args []any) (any, error) {
+	argc := map[string]int{
+		"abs": 1, "round": 1, "floor": 1, "ceil": 1, "upper": 1, "ucase": 1, "lower": 1, "lcase": 1,
+		"length": 1, "char_length": 1, "year": 1, "month": 1, "day": 1, "dayofmonth": 1, "hour": 1,
+		"minute": 1, "second": 1, "ifnull": 2,
+	}
+	if n, ok := argc[name]; ok && len(args) != n {
+		return nil, fmt.Errorf("%s expects %d arguments", name, n)
+	}
+
+	if name == "ifnull" {
+		if args[0] == nil {
+			return args[1], nil
+		}
+		return args[0], nil
+	}
+	if name == "concat" {
+		var b strings.Builder
+		for _, a := range args {
+			if a == nil {
+				return nil, nil
+			}
+			s, _ := coerce(a, typeString)
+			b.WriteString(s.(string))
+		}
+		return b.String(), nil
+	}
+	for _, a := range args {
+		if a == nil {
+			return nil, nil
+		}
+	}
+
+	switch name {
+	case "abs":
+		if typeOf(args[0]).isInteger() {
+			n := toInt64(args[0])
+			if n < 0 {
+				n = -n
+			}
+			return coerce(n, typeOf(args[0]))
+		}
+		return math.Abs(toFloat64(args[0])), nil
+	case "round":
+		return math.Round(toFloat64(args[0])), nil
+	case "floor":
+		return math.Floor(toFloat64(args[0])), nil
+	case "ceil":
+		return math.Ceil(toFloat64(args[0])), nil
+	case "upper", "ucase", "lower", "lcase", "length", "char_length":
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s on non-string value %v", name, args[0])
+		}
+		switch name {
+		case "upper", "ucase":
+			return strings.ToUpper(s), nil
+		case "lower", "lcase":
+			return strings.ToLower(s), nil
+		default:
+			return int32(len([]rune(s))), nil
+		}
+	case "substr", "substring":
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("%s expects 2 or 3 arguments", name)
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s on non-string value %v", name, args[0])
+		}
+		runes := []rune(s)
+		pos := int(toInt64(args[1]))
+		if pos < 0 {
+			pos = len(runes) + pos + 1
+		}
+		if pos < 1 || pos > len(runes) {
+			return "", nil
+		}
+		end := len(runes)
+		if len(args) == 3 {
+			end = min(end, pos-1+int(toInt64(args[2])))
+		}
+		if end < pos-1 {
+			return "", nil
+		}
+		return string(runes[pos-1 : end]), nil
+	case "year", "month", "day", "dayofmonth", "hour", "minute", "second":
+		t, ok := args[0].(time.Time)
+		if !ok {
+			t = time.UnixMilli(toInt64(args[0]))
+		}
+		switch name {
+		case "year":
+			return int32(t.Year()), nil
+		case "month":
+			return int32(t.Month()), nil
+		case "day", "dayofmonth":
+			return int32(t.Day()), nil
+		case "hour":
+			return int32(t.Hour()), nil
+		case "minute":
+			return int32(t.Minute()), nil
+		default:
+			return int32(t.Second()), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown function %s", name)
+}
+
+// typeOfExpr infers the result type of expression
+func typeOfExpr(e expr, s scope, params []any) (dataType, error) {
+	switch x := e.(type) {
+	case *literal:
+		return typeOf(x.v), nil
+	case *paramRef:
+		if x.index < len(params) {
+			return typeOf(params[x.index]), nil
+		}
+		return typeNull, nil
+	case *columnRef:
+		ti, ci, err := s.resolve(x)
+		if err != nil {
+			return typeNull, err
+		}
+		return s[ti].table.columns[ci].typ, nil
+	case *unaryExpr:
+		if x.op == "not" {
+			return typeBool, nil
+		}
+		return typeOfExpr(x.x, s, params)
+	case *binaryExpr:
+		switch x.op {
+		case "and", "or", "=", "!=", "<", "<=", ">", ">=", "like":
+			return typeBool, nil
+		}
+		lt, err := typeOfExpr(x.l, s, params)
+		if err != nil {
+			return typeNull, err
+		}
+		rt, err := typeOfExpr(x.r, s, params)
+		if err != nil {
+			return typeNull, err
+		}
+		return arithType(x.op, lt, rt), nil
+	case *isNullExpr, *inExpr, *betweenExpr:
+		return typeBool, nil
+	case *castExpr:
+		return x.typ, nil
+	case *caseExpr:
+		for _, t := range append(x.thens, x.els) {
+			if t == nil {
+				continue
+			}
+			typ, err := typeOfExpr(t, s, params)
+			if err != nil || typ != typeNull {
+				return typ, err
+			}
+		}
+		return typeNull, nil
+	case *funcCall:
+		return funcType(x, s, params)
+	}
+	return typeNull, fmt.Errorf("unsupported expression %T", e)
+}
+
+func arithType(op string, lt, rt dataType) dataType {
+	if lt == typeTimestamp {
+		lt = typeInt64
+	}
+	if rt == typeTimestamp {
+		rt = typeInt64
+	}
+	switch {
+	case op == "div":
+		return typeInt64
+	case lt.isInteger() && rt.isInteger() && op != "/":
+		return max(lt, rt)
+	default:
+		return typeDouble
+	}
+}
+
+func funcType(f *funcCall, s scope, params []any) (dataType, error) {
+	argType := func(i int) (dataType, error) {
+		if i >= len(f.args) {
+			return typeNull, fmt.Errorf("%s expects %d arguments", f.name, i+1)
+		}
+		return typeOfExpr(f.args[i], s, params)
+	}
+
+	switch f.name {
+	case "count", "count_where", "distinct_count":
+		return typeInt64, nil
+	case "avg", "avg_where", "round", "floor", "ceil":
+		return typeDouble, nil
+	case "sum", "sum_where":
+		t, err := argType(0)
+		if t.isInteger() {
+			return typeInt64, err
+		}
+		return typeDouble, err
+	case "min", "max", "min_where", "max_where", "lag", "first_value", "abs", "ifnull":
+		return argType(0)
+	case "concat", "upper", "ucase", "lower", "lcase", "substr", "substring":
+		return typeString, nil
+	case "length", "char_length", "year", "month", "day", "dayofmonth", "hour", "minute", "second":
+		return typeInt32, nil
+	}
+	return typeNull, fmt.Errorf("unknown function %s", f.name)
+}
@@ -0,0 +1,151 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// job is an offline job, submitted by a query or SELECT INTO in offline modes
+type job struct {
+	id      int32
+	typ     string
+	sql     string
+	start   time.Time
+	end     time.Time // the time job finishes if not stopped
+	stopped time.Time
+}
+
+// state is the state of job at now, as SHOW JOBS
+func (j *job) state(now time.Time) string {
+	switch {
+	case !j.stopped.IsZero():
+		return "STOPPED"
+	case now.Before(j.end):
+		return "RUNNING"
+	default:
+		return "FINISHED"
+	}
+}
+
+// jobs holds offline jobs, guarded by mu
+type jobs struct {
+	mu       sync.Mutex
+	list     []*job
+	duration time.Duration
+}
+
+// SetJobDuration makes offline jobs run for d, 0 by default. A query or SELECT INTO in
+// offline modes is submitted as a job. In offsync mode the request waits for the job to
+// finish, up to the timeout of request, and the job keeps running if the request is cancelled
+// or timed out, until finished or stopped by STOP JOB. In offasync mode the request returns
+// the job info as SHOW JOB once submitted.
+func (e *Emulator) SetJobDuration(d time.Duration) {
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+	e.jobs.duration = d
+}
+
+// newJob adds a job of stmt running for the job duration
+func (e *Emulator) newJob(stmt statement, sql string) *job {
+	typ := "RunBatchAndShow"
+	if s, ok := stmt.(*selectStmt); ok && s.outfile != "" {
+		typ = "RunBatchSql"
+	}
+
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+	now := time.Now()
+	j := &job{id: int32(len(e.jobs.list) + 1), typ: typ, sql: sql, start: now, end: now.Add(e.jobs.duration)}
+	e.jobs.list = append(e.jobs.list, j)
+	return j
+}
+
+// submitJob runs a statement as an offline job, and returns the job without waiting for it.
+func (e *Emulator) submitJob(stmt statement, sql string, run func() (*resultSet, error)) (*resultSet, error) {
+	if _, err := run(); err != nil {
+		return nil, err
+	}
+	return e.showJobs(e.newJob(stmt, sql).id)
+}
+
+// runJob runs a statement as an offline job, and waits for it to finish. timeout is in
+// milliseconds, 0 for no timeout.
+func (e *Emulator) runJob(ctx context.Context, stmt statement, sql string, timeout int64, run func() (*resultSet, error)) (*resultSet, error) {
+	rs, err := run()
+	if err != nil {
+		return nil, err
+	}
+	j := e.newJob(stmt, sql)
+
+	wait := time.Until(j.end)
+	if wait <= 0 {
+		return rs, nil
+	}
+	limit := wait
+	if timeout > 0 && time.Duration(timeout)*time.Millisecond < wait {
+		limit = time.Duration(timeout) * time.Millisecond
+	}
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+	switch j.state(time.Now()) {
+	case "STOPPED":
+		return nil, fmt.Errorf("job %d stopped", j.id)
+	case "RUNNING":
+		return nil, fmt.Errorf("job %d timeout after %dms", j.id, timeout)
+	}
+	return rs, nil
+}
+
+// showJobs returns jobs of ids, or all jobs if ids is empty
+func (e *Emulator) showJobs(ids ...int32) (*resultSet, error) {
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+
+	rs := &resultSet{
+		names: []string{"id", "job_type", "state", "start_time", "end_time", "parameter", "cluster",
+			"application_id", "error"},
+		types: []dataType{typeInt32, typeString, typeString, typeTimestamp, typeTimestamp, typeString, typeString,
+			typeString, typeString},
+	}
+	now := time.Now()
+	for _, j := range e.jobs.list {
+		if len(ids) > 0 && j.id != ids[0] {
+			continue
+		}
+		state := j.state(now)
+		var end any
+		switch state {
+		case "STOPPED":
+			end = j.stopped
+		case "FINISHED":
+			end = j.end
+		}
+		rs.rows = append(rs.rows, []any{j.id, j.typ, state, j.start, end, j.sql, "local", "", nil})
+	}
+	if len(ids) > 0 && len(rs.rows) == 0 {
+		return nil, fmt.Errorf("job %d not found", ids[0])
+	}
+	return rs, nil
+}
+
+// stopJob stops job id if it is running, and returns the job
+func (e *Emulator) stopJob(id int32) (*resultSet, error) {
+	e.jobs.mu.Lock()
+	for _, j := range e.jobs.list {
+		if j.id == id && j.state(time.Now()) == "RUNNING" {
+			j.stopped = time.Now()
+		}
+	}
+	e.jobs.mu.Unlock()
+	return e.showJobs(id)
+}
@@ -0,0 +1,173 @@
+package emulator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent // `ident`
+	tokNumber
+	tokString
+	tokParam // ?
+	tokOp    // operators and punctuation
+)
+
+type token struct {
+	kind tokenKind
+	text string // identifier name, number text, unquoted string, or operator
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return fmt.Sprintf("'%s'", t.text)
+	default:
+		return t.text
+	}
+}
+
+// is reports whether token is the keyword, case-insensitively
+func (t token) is(keyword string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) isOp(op string) bool {
+	return t.kind == tokOp && t.text == op
+}
+
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '-' && strings.HasPrefix(src[i:], "--"), c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at %d", i)
+			}
+			i += end + 4
+		case c == '\'' || c == '"':
+			s, n, err := scanQuoted(src[i:], c)
+			if err != nil {
+				return nil, fmt.Errorf("%w at %d", err, i)
+			}
+			toks = append(toks, token{tokString, s, i})
+			i += n
+		case c == '`':
+			s, n, err := scanQuoted(src[i:], c)
+			if err != nil {
+				return nil, fmt.Errorf("%w at %d", err, i)
+			}
+			toks = append(toks, token{tokQuotedIdent, s, i})
+			i += n
+		case c == '?':
+			toks = append(toks, token{tokParam, "?", i})
+			i++
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			start := i
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					i = j
+					for i < len(src) && isDigit(src[i]) {
+						i++
+					}
+				}
+			}
+			// suffix like 10L for bigint, 10f for float, or time unit like 3d in window frame
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			toks = append(toks, token{tokNumber, src[start:i], start})
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			toks = append(toks, token{tokIdent, src[start:i], start})
+		default:
+			op := string(c)
+			for _, two := range []string{"<=", ">=", "<>", "!=", "==", "||", "&&"} {
+				if strings.HasPrefix(src[i:], two) {
+					op = two
+					break
+				}
+			}
+			if !strings.Contains("=<>!+-*/%(),.;|&", string(c)) {
+				return nil, fmt.Errorf("unexpected character '%c' at %d", c, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+// scanQuoted scans string quoted by q at the beginning of src, returns the unquoted
+// string and the length consumed. Quote is escaped by doubling or backslash.
+func scanQuoted(src string, q byte) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\\' && q != '`' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			default:
+				b.WriteByte(src[i])
+			}
+		case c == q:
+			if i+1 < len(src) && src[i+1] == q {
+				b.WriteByte(q)
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted string")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '@' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
@@ -0,0 +1,1326 @@
+package emulator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type statement interface{}
+
+type createDatabaseStmt struct {
+	name        string
+	ifNotExists bool
+}
+
+type dropDatabaseStmt struct {
+	name     string
+	ifExists bool
+}
+
+type columnDef struct {
+	name    string
+	typ     dataType
+	notNull bool
+	dflt    expr
+}
+
+type indexDef struct {
+	name    string
+	keys    []string
+	ts      string
+	ttl     []string // ttl values as written, e.g. "10m" or "100"
+	ttlType string
+}
+
+type createTableStmt struct {
+	name        string
+	ifNotExists bool
+	columns     []columnDef
+	indexes     []indexDef
+	options     map[string]string
+}
+
+type dropTableStmt struct {
+	name     string
+	ifExists bool
+}
+
+type createIndexStmt struct {
+	table string
+	index indexDef
+}
+
+type dropIndexStmt struct {
+	table string
+	name  string
+}
+
+type insertStmt struct {
+	table   string
+	columns []string
+	rows    [][]expr
+}
+
+type deployStmt struct {
+	name        string
+	ifNotExists bool
+	options     map[string]string
+	query       *selectStmt
+	sql         string // SQL of the query
+}
+
+type dropDeploymentStmt struct {
+	name string
+}
+
+type showStmt struct {
+	what string // tables, deployments, databases or jobs
+}
+
+// jobStmt is SHOW JOB id or STOP JOB id
+type jobStmt struct {
+	id   int32
+	stop bool
+}
+
+// noopStmt is accepted and ignored, e.g. SET and USE
+type noopStmt struct{}
+
+type selectItem struct {
+	expr  expr // nil for *
+	alias string
+	star  string // table name for t.*, empty for *
+}
+
+type tableRef struct {
+	name  string
+	alias string
+}
+
+func (t tableRef) ref() string {
+	if t.alias != "" {
+		return t.alias
+	}
+	return t.name
+}
+
+type lastJoin struct {
+	table   tableRef
+	orderBy *columnRef
+	on      expr
+}
+
+type frameBound struct {
+	unbounded bool
+	current   bool
+	offset    int64 // rows, or milliseconds for ROWS_RANGE
+	open      bool
+}
+
+type windowDef struct {
+	name        string
+	partitionBy []expr
+	orderBy     expr
+	rowsRange   bool // ROWS_RANGE, otherwise ROWS
+	start, end  frameBound
+	excludeCur  bool
+	maxSize     int
+}
+
+type selectStmt struct {
+	items   []selectItem
+	from    tableRef
+	joins   []lastJoin
+	where   expr
+	windows map[string]*windowDef
+	limit   int
+	// outfile is the path of SELECT INTO OUTFILE, the result is not written by emulator
+	outfile string
+}
+
+type expr interface{}
+
+type literal struct {
+	v any
+}
+
+type paramRef struct {
+	index int
+}
+
+type columnRef struct {
+	table string
+	name  string
+}
+
+func (c *columnRef) String() string {
+	if c.table != "" {
+		return c.table + "." + c.name
+	}
+	return c.name
+}
+
+type unaryExpr struct {
+	op string // "-" or "not"
+	x  expr
+}
+
+type binaryExpr struct {
+	op   string
+	l, r expr
+}
+
+type isNullExpr struct {
+	x   expr
+	not bool
+}
+
+type inExpr struct {
+	x    expr
+	list []expr
+	not  bool
+}
+
+type betweenExpr struct {
+	x, lo, hi expr
+	not       bool
+}
+
+type castExpr struct {
+	x   expr
+	typ dataType
+}
+
+type caseExpr struct {
+	whens []expr
+	thens []expr
+	els   expr
+}
+
+type funcCall struct {
+	name   string // lower case
+	args   []expr
+	star   bool   // count(*)
+	over   string // window name
+	window *windowDef
+}
+
+type parser struct {
+	toks   []token
+	pos    int
+	src    string
+	params int
+}
+
+func parse(src string) (statement, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, src: src}
+	stmt, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	p.acceptOp(";")
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return stmt, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) peekN(n int) token {
+	if p.pos+n < len(p.toks) {
+		return p.toks[p.pos+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error at %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) accept(keyword string) bool {
+	if p.peek().is(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(keywords ...string) error {
+	for _, kw := range keywords {
+		if !p.accept(kw) {
+			return p.errorf("expect %s, got %s", strings.ToUpper(kw), p.peek())
+		}
+	}
+	return nil
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.peek().isOp(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.errorf("expect '%s', got %s", op, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	if t.kind != tokIdent && t.kind != tokQuotedIdent {
+		return "", p.errorf("expect identifier, got %s", t)
+	}
+	p.pos++
+	return t.text, nil
+}
+
+// qualifiedIdent parses name or db.name, the db part is ignored
+func (p *parser) qualifiedIdent() (string, error) {
+	name, err := p.ident()
+	if err != nil {
+		return "", err
+	}
+	if p.peek().isOp(".") {
+		p.pos++
+		return p.ident()
+	}
+	return name, nil
+}
+
+func (p *parser) ifNotExists() (bool, error) {
+	if p.accept("if") {
+		return true, p.expect("not", "exists")
+	}
+	return false, nil
+}
+
+func (p *parser) ifExists() (bool, error) {
+	if p.accept("if") {
+		return true, p.expect("exists")
+	}
+	return false, nil
+}
+
+func (p *parser) statement() (statement, error) {
+	switch {
+	case p.accept("create"):
+		switch {
+		case p.accept("database"):
+			ine, err := p.ifNotExists()
+			if err != nil {
+				return nil, err
+			}
+			name, err := p.ident()
+			return &createDatabaseStmt{name, ine}, err
+		case p.accept("table"):
+			return p.createTable()
+		case p.accept("index"):
+			return p.createIndex()
+		}
+	case p.accept("drop"):
+		switch {
+		case p.accept("database"):
+			ie, err := p.ifExists()
+			if err != nil {
+				return nil, err
+			}
+			name, err := p.ident()
+			return &dropDatabaseStmt{name, ie}, err
+		case p.accept("table"):
+			ie, err := p.ifExists()
+			if err != nil {
+				return nil, err
+			}
+			name, err := p.qualifiedIdent()
+			return &dropTableStmt{name, ie}, err
+		case p.accept("index"):
+			table, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("."); err != nil {
+				return nil, err
+			}
+			name, err := p.ident()
+			return &dropIndexStmt{table, name}, err
+		case p.accept("deployment"):
+			name, err := p.qualifiedIdent()
+			return &dropDeploymentStmt{name}, err
+		}
+	case p.accept("insert"):
+		return p.insert()
+	case p.peek().is("select"):
+		return p.selectStmt()
+	case p.accept("deploy"):
+		return p.deploy()
+	case p.accept("show"):
+		for _, what := range []string{"tables", "deployments", "databases", "jobs"} {
+			if p.accept(what) {
+				return &showStmt{what}, nil
+			}
+		}
+		if p.accept("job") {
+			return p.job(false)
+		}
+	case p.accept("stop"):
+		if err := p.expect("job"); err != nil {
+			return nil, err
+		}
+		return p.job(true)
+	case p.peek().is("set"), p.peek().is("use"):
+		p.pos = len(p.toks) - 1
+		return &noopStmt{}, nil
+	}
+	return nil, p.errorf("unsupported statement at %s", p.peek())
+}
+
+func (p *parser) job(stop bool) (statement, error) {
+	t := p.next()
+	id, err := strconv.ParseInt(t.text, 10, 32)
+	if t.kind != tokNumber || err != nil {
+		return nil, p.errorf("invalid job id %s", t)
+	}
+	return &jobStmt{int32(id), stop}, nil
+}
+
+func (p *parser) createTable() (statement, error) {
+	ine, err := p.ifNotExists()
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.qualifiedIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &createTableStmt{name: name, ifNotExists: ine}
+
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.accept("index") {
+			idx, err := p.indexBody()
+			if err != nil {
+				return nil, err
+			}
+			stmt.indexes = append(stmt.indexes, idx)
+		} else {
+			col, err := p.columnDef()
+			if err != nil {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, col)
+		}
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+
+	if p.accept("options") {
+		stmt.options, err = p.options()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) columnDef() (columnDef, error) {
+	name, err := p.ident()
+	if err != nil {
+		return columnDef{}, err
+	}
+	typPos := p.peek().pos
+	typName, err := p.ident()
+	if err != nil {
+		return columnDef{}, err
+	}
+	typ, ok := parseType(typName)
+	if !ok {
+		return columnDef{}, fmt.Errorf("syntax error at %d: unknown type %s", typPos, typName)
+	}
+	col := columnDef{name: name, typ: typ}
+	for {
+		switch {
+		case p.accept("not"):
+			if err := p.expect("null"); err != nil {
+				return col, err
+			}
+			col.notNull = true
+		case p.accept("null"):
+		case p.accept("default"):
+			col.dflt, err = p.primary()
+			if err != nil {
+				return col, err
+			}
+		default:
+			return col, nil
+		}
+	}
+}
+
+// indexBody parses (KEY=..., TS=..., TTL=..., TTL_TYPE=...)
+func (p *parser) indexBody() (indexDef, error) {
+	var idx indexDef
+	if err := p.expectOp("("); err != nil {
+		return idx, err
+	}
+	for {
+		key, err := p.ident()
+		if err != nil {
+			return idx, err
+		}
+		if err := p.expectOp("="); err != nil {
+			return idx, err
+		}
+		values, err := p.optionValues()
+		if err != nil {
+			return idx, err
+		}
+		switch strings.ToLower(key) {
+		case "key":
+			idx.keys = values
+		case "ts":
+			idx.ts = values[0]
+		case "ttl":
+			idx.ttl = values
+		case "ttl_type":
+			idx.ttlType = strings.ToLower(values[0])
+		default:
+			return idx, p.errorf("unknown index option %s", key)
+		}
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	return idx, p.expectOp(")")
+}
+
+// optionValues parses a single value, or a parenthesized value list
+func (p *parser) optionValues() ([]string, error) {
+	if p.acceptOp("(") {
+		var values []string
+		for {
+			t := p.next()
+			if t.kind == tokEOF || t.kind == tokOp {
+				return nil, p.errorf("unexpected %s", t)
+			}
+			values = append(values, t.text)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		return values, p.expectOp(")")
+	}
+	t := p.next()
+	if t.kind == tokEOF || t.kind == tokOp {
+		return nil, p.errorf("unexpected %s", t)
+	}
+	return []string{t.text}, nil
+}
+
+// options parses (key = value, ...)
+func (p *parser) options() (map[string]string, error) {
+	opts := map[string]string{}
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	for {
+		key, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp("="); err != nil {
+			return nil, err
+		}
+		values, err := p.optionValues()
+		if err != nil {
+			return nil, err
+		}
+		opts[strings.ToLower(key)] = strings.Join(values, ",")
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	return opts, p.expectOp(")")
+}
+
+func (p *parser) createIndex() (statement, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("on"); err != nil {
+		return nil, err
+	}
+	table, err := p.qualifiedIdent()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := p.optionValues()
+	if err != nil {
+		return nil, err
+	}
+	idx := indexDef{name: name, keys: keys}
+	if p.accept("options") {
+		opts, err := p.options()
+		if err != nil {
+			return nil, err
+		}
+		idx.ts = opts["ts"]
+		if ttl, ok := opts["ttl"]; ok {
+			idx.ttl = strings.Split(ttl, ",")
+		}
+		idx.ttlType = strings.ToLower(opts["ttl_type"])
+	}
+	return &createIndexStmt{table, idx}, nil
+}
+
+func (p *parser) insert() (statement, error) {
+	if p.accept("or") {
+		if err := p.expect("ignore"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("into"); err != nil {
+		return nil, err
+	}
+	table, err := p.qualifiedIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &insertStmt{table: table}
+
+	if p.acceptOp("(") {
+		for {
+			col, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, col)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expect("values"); err != nil {
+		return nil, err
+	}
+	for {
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		var row []expr
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, e)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		stmt.rows = append(stmt.rows, row)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) deploy() (statement, error) {
+	ine, err := p.ifNotExists()
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &deployStmt{name: name, ifNotExists: ine}
+	if p.accept("options") {
+		stmt.options, err = p.options()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	start := p.peek().pos
+	query, err := p.selectStmt()
+	if err != nil {
+		return nil, err
+	}
+	stmt.query = query
+	stmt.sql = strings.TrimRight(strings.TrimSpace(p.src[start:p.peek().pos]), ";")
+	return stmt, nil
+}
+
+func (p *parser) selectStmt() (*selectStmt, error) {
+	if err := p.expect("select"); err != nil {
+		return nil, err
+	}
+	stmt := &selectStmt{windows: map[string]*windowDef{}, limit: -1}
+
+	for {
+		item, err := p.selectItem()
+		if err != nil {
+			return nil, err
+		}
+		stmt.items = append(stmt.items, item)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+
+	if !p.accept("from") {
+		// select without table, e.g. SELECT 1 sent by ping
+		return stmt, nil
+	}
+	var err error
+	if stmt.from, err = p.tableRef(); err != nil {
+		return nil, err
+	}
+
+	for p.accept("last") {
+		if err := p.expect("join"); err != nil {
+			return nil, err
+		}
+		var j lastJoin
+		if j.table, err = p.tableRef(); err != nil {
+			return nil, err
+		}
+		if p.accept("order") {
+			if err := p.expect("by"); err != nil {
+				return nil, err
+			}
+			col, err := p.columnRef()
+			if err != nil {
+				return nil, err
+			}
+			j.orderBy = col
+		}
+		if err := p.expect("on"); err != nil {
+			return nil, err
+		}
+		if j.on, err = p.expr(); err != nil {
+			return nil, err
+		}
+		stmt.joins = append(stmt.joins, j)
+	}
+
+	if p.accept("where") {
+		if stmt.where, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept("window") {
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("as"); err != nil {
+				return nil, err
+			}
+			w, err := p.windowDef()
+			if err != nil {
+				return nil, err
+			}
+			w.name = name
+			stmt.windows[strings.ToLower(name)] = w
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+
+	if p.accept("limit") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil {
+			return nil, p.errorf("invalid limit %s", t)
+		}
+		stmt.limit = n
+	}
+
+	if p.accept("into") {
+		if err := p.expect("outfile"); err != nil {
+			return nil, err
+		}
+		t := p.next()
+		if t.kind != tokString {
+			return nil, p.errorf("invalid outfile %s", t)
+		}
+		stmt.outfile = t.text
+		if p.accept("options") {
+			if _, err := p.options(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) selectItem() (selectItem, error) {
+	if p.acceptOp("*") {
+		return selectItem{star: ""}, nil
+	}
+	if p.peekN(1).isOp(".") && p.peekN(2).isOp("*") {
+		table, _ := p.ident()
+		p.pos += 2
+		return selectItem{star: table}, nil
+	}
+
+	e, err := p.expr()
+	if err != nil {
+		return selectItem{}, err
+	}
+	item := selectItem{expr: e}
+	if p.accept("as") {
+		item.alias, err = p.ident()
+		if err != nil {
+			return item, err
+		}
+	} else if t := p.peek(); t.kind == tokQuotedIdent || (t.kind == tokIdent && !isReserved(t.text)) {
+		p.pos++
+		item.alias = t.text
+	}
+	return item, nil
+}
+
+var reserved = map[string]bool{
+	"from": true, "where": true, "window": true, "limit": true, "last": true, "join": true, "into": true,
+	"on": true, "order": true, "as": true, "and": true, "or": true, "not": true,
+}
+
+func isReserved(s string) bool {
+	return reserved[strings.ToLower(s)]
+}
+
+func (p *parser) tableRef() (tableRef, error) {
+	name, err := p.qualifiedIdent()
+	if err != nil {
+		return tableRef{}, err
+	}
+	ref := tableRef{name: name}
+	if p.accept("as") {
+		ref.alias, err = p.ident()
+	} else if t := p.peek(); t.kind == tokIdent && !isReserved(t.text) {
+		p.pos++
+		ref.alias = t.text
+	}
+	return ref, err
+}
+
+func (p *parser) columnRef() (*columnRef, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptOp(".") {
+		col, err := p.ident()
+		return &columnRef{table: name, name: col}, err
+	}
+	return &columnRef{name: name}, nil
+}
+
+func (p *parser) windowDef() (*windowDef, error) {
+	w := &windowDef{}
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("partition", "by"); err != nil {
+		return nil, err
+	}
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		w.partitionBy = append(w.partitionBy, e)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+
+	if err := p.expect("order", "by"); err != nil {
+		return nil, err
+	}
+	var err error
+	if w.orderBy, err = p.expr(); err != nil {
+		return nil, err
+	}
+	p.accept("asc")
+
+	switch {
+	case p.accept("rows"):
+	case p.accept("rows_range"):
+		w.rowsRange = true
+	default:
+		return nil, p.errorf("expect ROWS or ROWS_RANGE, got %s", p.peek())
+	}
+	if err := p.expect("between"); err != nil {
+		return nil, err
+	}
+	if w.start, err = p.frameBound(w.rowsRange); err != nil {
+		return nil, err
+	}
+	if err := p.expect("and"); err != nil {
+		return nil, err
+	}
+	if w.end, err = p.frameBound(w.rowsRange); err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.accept("exclude"):
+			if !p.accept("current_row") {
+				return nil, p.errorf("unsupported EXCLUDE %s", p.peek())
+			}
+			w.excludeCur = true
+			continue
+		case p.accept("maxsize"):
+			t := p.next()
+			n, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, p.errorf("invalid MAXSIZE %s", t)
+			}
+			w.maxSize = n
+			continue
+		case p.accept("instance_not_in_window"):
+			continue
+		}
+		break
+	}
+	return w, p.expectOp(")")
+}
+
+func (p *parser) frameBound(rowsRange bool) (frameBound, error) {
+	switch {
+	case p.accept("unbounded"):
+		return frameBound{unbounded: true}, p.expect("preceding")
+	case p.accept("current"):
+		if !p.accept("row") && !p.accept("time") {
+			return frameBound{}, p.errorf("expect CURRENT ROW")
+		}
+		return frameBound{current: true}, nil
+	}
+
+	t := p.next()
+	if t.kind != tokNumber {
+		return frameBound{}, p.errorf("invalid frame bound %s", t)
+	}
+	offset, err := parseFrameOffset(t.text, rowsRange)
+	if err != nil {
+		return frameBound{}, p.errorf("%s", err)
+	}
+	b := frameBound{offset: offset}
+	b.open = p.accept("open")
+	return b, p.expect("preceding")
+}
+
+// parseFrameOffset parses frame offset, with optional time unit for ROWS_RANGE
+func parseFrameOffset(s string, rowsRange bool) (int64, error) {
+	unit := int64(1)
+	if rowsRange {
+		units := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}
+		if d, ok := units[strings.ToLower(s[len(s)-1:])]; ok {
+			unit = d.Milliseconds()
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid frame offset %s", s)
+	}
+	return n * unit, nil
+}
+
+// expression parsing by precedence climbing
+
+func (p *parser) expr() (expr, error) {
+	return p.orExpr()
+}
+
+func (p *parser) orExpr() (expr, error) {
+	l, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") || p.acceptOp("||") {
+		r, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{"or", l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) andExpr() (expr, error) {
+	l, err := p.notExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") || p.acceptOp("&&") {
+		r, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{"and", l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) notExpr() (expr, error) {
+	if p.accept("not") || p.acceptOp("!") {
+		x, err := p.notExpr()
+		return &unaryExpr{"not", x}, err
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	l, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokOp && (t.text == "=" || t.text == "==" || t.text == "!=" || t.text == "<>" ||
+			t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+			p.pos++
+			r, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			op := t.text
+			switch op {
+			case "==":
+				op = "="
+			case "<>":
+				op = "!="
+			}
+			l = &binaryExpr{op, l, r}
+		case t.is("is"):
+			p.pos++
+			not := p.accept("not")
+			if err := p.expect("null"); err != nil {
+				return nil, err
+			}
+			l = &isNullExpr{l, not}
+		case t.is("not") && (p.peekN(1).is("in") || p.peekN(1).is("between") || p.peekN(1).is("like")):
+			p.pos++
+			l, err = p.comparisonSuffix(l, true)
+			if err != nil {
+				return nil, err
+			}
+		case t.is("in") || t.is("between") || t.is("like"):
+			l, err = p.comparisonSuffix(l, false)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return l, nil
+		}
+	}
+}
+
+func (p *parser) comparisonSuffix(l expr, not bool) (expr, error) {
+	switch {
+	case p.accept("in"):
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		var list []expr
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, e)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		return &inExpr{l, list, not}, p.expectOp(")")
+	case p.accept("between"):
+		lo, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("and"); err != nil {
+			return nil, err
+		}
+		hi, err := p.additive()
+		return &betweenExpr{l, lo, hi, not}, err
+	default:
+		p.accept("like")
+		r, err := p.additive()
+		var e expr = &binaryExpr{"like", l, r}
+		if not {
+			e = &unaryExpr{"not", e}
+		}
+		return e, err
+	}
+}
+
+func (p *parser) additive() (expr, error) {
+	l, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.isOp("+") && !t.isOp("-") {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{t.text, l, r}
+	}
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.isOp("*") && !t.isOp("/") && !t.isOp("%") && !t.is("div") && !t.is("mod") {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		op := strings.ToLower(t.text)
+		if op == "mod" {
+			op = "%"
+		}
+		l = &binaryExpr{op, l, r}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.acceptOp("-") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if lit, ok := x.(*literal); ok {
+			switch v := lit.v.(type) {
+			case int32:
+				return &literal{-v}, nil
+			case int64:
+				return &literal{-v}, nil
+			case float64:
+				return &literal{-v}, nil
+			}
+		}
+		return &unaryExpr{"-", x}, nil
+	}
+	p.acceptOp("+")
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.pos++
+		return parseNumber(t.text)
+	case tokString:
+		p.pos++
+		return &literal{t.text}, nil
+	case tokParam:
+		p.pos++
+		p.params++
+		return &paramRef{p.params - 1}, nil
+	case tokOp:
+		if p.acceptOp("(") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectOp(")")
+		}
+		if p.acceptOp("-") {
+			x, err := p.primary()
+			return &unaryExpr{"-", x}, err
+		}
+		return nil, p.errorf("unexpected %s", t)
+	case tokEOF:
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	switch {
+	case t.is("null"):
+		p.pos++
+		return &literal{nil}, nil
+	case t.is("true"):
+		p.pos++
+		return &literal{true}, nil
+	case t.is("false"):
+		p.pos++
+		return &literal{false}, nil
+	case t.is("cast") && p.peekN(1).isOp("("):
+		p.pos += 2
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("as"); err != nil {
+			return nil, err
+		}
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		typ, ok := parseType(name)
+		if !ok {
+			return nil, p.errorf("unknown type %s", name)
+		}
+		return &castExpr{x, typ}, p.expectOp(")")
+	case t.is("case"):
+		p.pos++
+		return p.caseExpr()
+	}
+
+	if t.kind == tokIdent && p.peekN(1).isOp("(") {
+		return p.funcCall()
+	}
+	return p.columnRef()
+}
+
+func parseNumber(s string) (expr, error) {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasSuffix(lower, "l"):
+		n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		return &literal{n}, err
+	case strings.HasSuffix(lower, "f"):
+		f, err := strconv.ParseFloat(s[:len(s)-1], 32)
+		return &literal{float32(f)}, err
+	case strings.ContainsAny(lower, ".e"):
+		f, err := strconv.ParseFloat(s, 64)
+		return &literal{f}, err
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", s)
+	}
+	if n >= -1<<31 && n < 1<<31 {
+		return &literal{int32(n)}, nil
+	}
+	return &literal{n}, nil
+}
+
+func (p *parser) caseExpr() (expr, error) {
+	c := &caseExpr{}
+	for p.accept("when") {
+		w, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		t, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		c.whens = append(c.whens, w)
+		c.thens = append(c.thens, t)
+	}
+	if p.accept("else") {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		c.els = e
+	}
+	return c, p.expect("end")
+}
+
+func (p *parser) funcCall() (expr, error) {
+	name, _ := p.ident()
+	p.pos++ // (
+	f := &funcCall{name: strings.ToLower(name)}
+	if p.acceptOp("*") {
+		f.star = true
+	} else if !p.peek().isOp(")") {
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			f.args = append(f.args, e)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+
+	if p.accept("over") {
+		if p.peek().isOp("(") {
+			w, err := p.windowDef()
+			if err != nil {
+				return nil, err
+			}
+			f.window = w
+		} else {
+			w, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			f.over = strings.ToLower(w)
+		}
+	}
+	return f, nil
+}
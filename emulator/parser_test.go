@@ -0,0 +1,44 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	stmt, err := parse(`SELECT sum(c2) OVER w FROM t1 WINDOW w AS (PARTITION BY c1 ORDER BY ts
+		ROWS_RANGE BETWEEN 3d OPEN PRECEDING AND 10s PRECEDING EXCLUDE CURRENT_ROW MAXSIZE 100);`)
+	require.NoError(t, err)
+
+	w := stmt.(*selectStmt).windows["w"]
+	require.NotNil(t, w)
+	assert.True(t, w.rowsRange)
+	assert.Equal(t, frameBound{offset: 3 * 24 * 3600 * 1000, open: true}, w.start)
+	assert.Equal(t, frameBound{offset: 10 * 1000}, w.end)
+	assert.True(t, w.excludeCur)
+	assert.Equal(t, 100, w.maxSize)
+}
+
+func TestParseSelectInto(t *testing.T) {
+	stmt, err := parse("SELECT c1 FROM t1 LIMIT 10 INTO OUTFILE '/tmp/t1.csv' OPTIONS (mode='overwrite')")
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/t1.csv", stmt.(*selectStmt).outfile)
+	assert.Equal(t, 10, stmt.(*selectStmt).limit)
+}
+
+func TestParseError(t *testing.T) {
+	for sql, msg := range map[string]string{
+		"SELEC 1":                         "syntax error at 0: unsupported statement at SELEC",
+		"SELECT 'a FROM t1":               "unterminated quoted string at 7",
+		"SELECT c1 FROM t1 WHERE":         "syntax error at 23: unexpected end of input",
+		"CREATE TABLE t1 (c1 unknown)":    "syntax error at 20: unknown type unknown",
+		"SELECT 1; SELECT 2":              "syntax error at 10: unexpected SELECT",
+		"INSERT INTO t1 VALUES (1, /* 2)": "unterminated comment at 26",
+		"SELECT c1 FROM t1 INTO OUTFILE":  "syntax error at 30: invalid outfile end of input",
+	} {
+		_, err := parse(sql)
+		assert.EqualError(t, err, msg, sql)
+	}
+}
@@ -0,0 +1,376 @@
+package emulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// resultSet is the result of a query
+type resultSet struct {
+	names []string
+	types []dataType
+	rows  [][]any
+}
+
+// prepareSelect names inline windows and validates window references,
+// it should be done once after select parsed
+func prepareSelect(stmt *selectStmt) error {
+	n := 0
+	var walk func(e expr) error
+	walk = func(e expr) error {
+		switch x := e.(type) {
+		case *funcCall:
+			if x.window != nil {
+				x.over = fmt.Sprintf("#%d", n)
+				x.window.name = x.over
+				n++
+				stmt.windows[x.over] = x.window
+				x.window = nil
+			}
+			if x.over != "" {
+				if _, ok := stmt.windows[x.over]; !ok {
+					return fmt.Errorf("window %s not defined", x.over)
+				}
+				if !aggregates[x.name] {
+					return fmt.Errorf("%s is not an aggregate function", x.name)
+				}
+			}
+			for _, a := range x.args {
+				if err := walk(a); err != nil {
+					return err
+				}
+			}
+		case *unaryExpr:
+			return walk(x.x)
+		case *binaryExpr:
+			if err := walk(x.l); err != nil {
+				return err
+			}
+			return walk(x.r)
+		case *castExpr:
+			return walk(x.x)
+		case *caseExpr:
+			for _, c := range append(append(x.whens, x.thens...), x.els) {
+				if c != nil {
+					if err := walk(c); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	for _, item := range stmt.items {
+		if item.expr != nil {
+			if err := walk(item.expr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasGlobalAggregate reports whether expression has aggregate function without window
+func hasGlobalAggregate(e expr) bool {
+	switch x := e.(type) {
+	case *funcCall:
+		if aggregates[x.name] && x.over == "" {
+			return true
+		}
+		for _, a := range x.args {
+			if hasGlobalAggregate(a) {
+				return true
+			}
+		}
+	case *unaryExpr:
+		return hasGlobalAggregate(x.x)
+	case *binaryExpr:
+		return hasGlobalAggregate(x.l) || hasGlobalAggregate(x.r)
+	case *castExpr:
+		return hasGlobalAggregate(x.x)
+	}
+	return false
+}
+
+// query runs select in batch mode, or in request mode if request row is not nil, that the
+// request row is processed as if it is the latest row of FROM table, and only the result of
+// request row returned
+func (db *database) query(stmt *selectStmt, params []any, request []any) (*resultSet, error) {
+	var err error
+	main := &table{rows: [][]any{{}}}
+	if stmt.from.name != "" {
+		if main, err = db.table(stmt.from.name); err != nil {
+			return nil, err
+		}
+	}
+	sc := scope{{stmt.from.ref(), main}}
+	for _, j := range stmt.joins {
+		t, err := db.table(j.table.name)
+		if err != nil {
+			return nil, err
+		}
+		sc = append(sc, scopeTable{j.table.ref(), t})
+	}
+	base := &env{scope: sc, params: params}
+
+	rows := make([]joinedRow, 0, len(main.rows)+1)
+	for _, r := range main.rows {
+		row := make(joinedRow, len(sc))
+		row[0] = r
+		rows = append(rows, row)
+	}
+	var requestRow joinedRow
+	if request != nil {
+		requestRow = make(joinedRow, len(sc))
+		requestRow[0] = request
+		rows = append(rows, requestRow)
+	}
+
+	for i, j := range stmt.joins {
+		if err := lastJoinRows(rows, i+1, j, base); err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.where != nil {
+		filtered := rows[:0:0]
+		for _, row := range rows {
+			v, err := eval(stmt.where, base.with(row))
+			if err != nil {
+				return nil, err
+			}
+			if b, _ := v.(bool); b {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+
+	frames := map[string][][]joinedRow{}
+	for name, w := range stmt.windows {
+		if frames[name], err = windowFrames(w, rows, base); err != nil {
+			return nil, fmt.Errorf("window %s: %w", strings.TrimPrefix(w.name, "#"), err)
+		}
+	}
+
+	rs := &resultSet{}
+	var items []selectItem
+	for _, item := range stmt.items {
+		if item.expr != nil {
+			items = append(items, item)
+			continue
+		}
+		found := false
+		for _, st := range sc {
+			if item.star != "" && !strings.EqualFold(item.star, st.ref) {
+				continue
+			}
+			found = true
+			for _, col := range st.table.columns {
+				items = append(items, selectItem{expr: &columnRef{table: st.ref, name: col.name}, alias: col.name})
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("table %s not found", item.star)
+		}
+	}
+
+	aggregated := false
+	for i, item := range items {
+		typ, err := typeOfExpr(item.expr, sc, params)
+		if err != nil {
+			return nil, err
+		}
+		rs.types = append(rs.types, typ)
+		rs.names = append(rs.names, itemName(item, i))
+		aggregated = aggregated || hasGlobalAggregate(item.expr)
+	}
+
+	project := func(i int, row joinedRow, frame []joinedRow) error {
+		rowEnv := base.with(row)
+		rowEnv.frame = frame
+		rowEnv.frames = map[string][]joinedRow{}
+		if i >= 0 {
+			for name, f := range frames {
+				rowEnv.frames[name] = f[i]
+			}
+		}
+		values := make([]any, len(items))
+		for j, item := range items {
+			v, err := eval(item.expr, rowEnv)
+			if err != nil {
+				return err
+			}
+			if values[j], err = coerce(v, rs.types[j]); err != nil {
+				return err
+			}
+		}
+		rs.rows = append(rs.rows, values)
+		return nil
+	}
+
+	if aggregated {
+		// aggregate all rows into single row, non-aggregated columns take the last row
+		var last joinedRow
+		if len(rows) > 0 {
+			last = rows[len(rows)-1]
+		}
+		return rs, project(len(rows)-1, last, rows)
+	}
+
+	for i, row := range rows {
+		if request != nil && &row[0] != &requestRow[0] {
+			continue
+		}
+		if stmt.limit >= 0 && len(rs.rows) >= stmt.limit {
+			break
+		}
+		if err := project(i, row, nil); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+func itemName(item selectItem, i int) string {
+	if item.alias != "" {
+		return item.alias
+	}
+	if c, ok := item.expr.(*columnRef); ok {
+		return c.name
+	}
+	return fmt.Sprintf("expr_%d", i)
+}
+
+// lastJoinRows joins each row with the last matched row of table at ti in scope, the
+// last matched row is the one with the greatest order by column, or the last inserted
+func lastJoinRows(rows []joinedRow, ti int, j lastJoin, base *env) error {
+	right := base.scope[ti].table
+	orderCol := -1
+	if j.orderBy != nil {
+		oti, oci, err := base.scope.resolve(j.orderBy)
+		if err != nil {
+			return err
+		}
+		if oti != ti {
+			return fmt.Errorf("order by column %s of last join should be in table %s", j.orderBy, j.table.ref())
+		}
+		orderCol = oci
+	}
+
+	for _, row := range rows {
+		var matched []any
+		for _, candidate := range right.rows {
+			row[ti] = candidate
+			v, err := eval(j.on, base.with(row))
+			if err != nil {
+				return err
+			}
+			if b, _ := v.(bool); !b {
+				continue
+			}
+			if matched == nil || orderCol < 0 {
+				matched = candidate
+				continue
+			}
+			if candidate[orderCol] == nil {
+				continue
+			}
+			if matched[orderCol] == nil {
+				matched = candidate
+				continue
+			}
+			c, err := compareValues(candidate[orderCol], matched[orderCol])
+			if err != nil {
+				return err
+			}
+			if c >= 0 {
+				matched = candidate
+			}
+		}
+		row[ti] = matched
+	}
+	return nil
+}
+
+// windowFrames computes frame of each row in window, frame rows are in ascending order
+func windowFrames(w *windowDef, rows []joinedRow, base *env) ([][]joinedRow, error) {
+	type entry struct {
+		i     int
+		order int64
+	}
+	partitions := map[string][]entry{}
+	var keys []string
+	for i, row := range rows {
+		rowEnv := base.with(row)
+		var key strings.Builder
+		for _, p := range w.partitionBy {
+			v, err := eval(p, rowEnv)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&key, "%T:%v\x00", v, v)
+		}
+		o, err := eval(w.orderBy, rowEnv)
+		if err != nil {
+			return nil, err
+		}
+		k := key.String()
+		if _, ok := partitions[k]; !ok {
+			keys = append(keys, k)
+		}
+		partitions[k] = append(partitions[k], entry{i, toInt64(o)})
+	}
+
+	frames := make([][]joinedRow, len(rows))
+	for _, k := range keys {
+		part := partitions[k]
+		sort.SliceStable(part, func(a, b int) bool { return part[a].order < part[b].order })
+
+		for pos, cur := range part {
+			start, end := 0, pos
+			if w.rowsRange {
+				if !w.start.unbounded {
+					lo := cur.order - w.start.offset
+					for start < pos && (part[start].order < lo || (w.start.open && part[start].order == lo)) {
+						start++
+					}
+				}
+				if !w.end.current {
+					hi := cur.order - w.end.offset
+					for end >= 0 && (part[end].order > hi || (w.end.open && part[end].order == hi)) {
+						end--
+					}
+				}
+			} else {
+				if !w.start.unbounded {
+					start = pos - int(w.start.offset)
+					if w.start.open {
+						start++
+					}
+				}
+				if !w.end.current {
+					end = pos - int(w.end.offset)
+					if w.end.open {
+						end--
+					}
+				}
+			}
+			start = max(start, 0)
+
+			var frame []joinedRow
+			for p := start; p <= end; p++ {
+				if w.excludeCur && p == pos {
+					continue
+				}
+				frame = append(frame, rows[part[p].i])
+			}
+			if w.maxSize > 0 && len(frame) > w.maxSize {
+				frame = frame[len(frame)-w.maxSize:]
+			}
+			frames[cur.i] = frame
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,357 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dataType is the SQL type of column or expression. Values of each type are
+// represented in go as bool, int16, int32, int64, float32, float64, string,
+// and time.Time for both timestamp and date, nil for NULL.
+type dataType int
+
+const (
+	typeNull dataType = iota // type of NULL literal, or unknown
+	typeBool
+	typeInt16
+	typeInt32
+	typeInt64
+	typeFloat
+	typeDouble
+	typeString
+	typeTimestamp
+	typeDate
+)
+
+var typeNames = map[string]dataType{
+	"bool":      typeBool,
+	"smallint":  typeInt16,
+	"int16":     typeInt16,
+	"int":       typeInt32,
+	"integer":   typeInt32,
+	"int32":     typeInt32,
+	"bigint":    typeInt64,
+	"int64":     typeInt64,
+	"float":     typeFloat,
+	"double":    typeDouble,
+	"string":    typeString,
+	"varchar":   typeString,
+	"timestamp": typeTimestamp,
+	"date":      typeDate,
+}
+
+func parseType(name string) (dataType, bool) {
+	t, ok := typeNames[strings.ToLower(name)]
+	return t, ok
+}
+
+// String returns the type name in query response of api server
+func (t dataType) String() string {
+	switch t {
+	case typeBool:
+		return "Bool"
+	case typeInt16:
+		return "Int16"
+	case typeInt32:
+		return "Int32"
+	case typeInt64:
+		return "Int64"
+	case typeFloat:
+		return "Float"
+	case typeDouble:
+		return "Double"
+	case typeString:
+		return "String"
+	case typeTimestamp:
+		return "Timestamp"
+	case typeDate:
+		return "Date"
+	default:
+		return "Null"
+	}
+}
+
+// sqlName returns the type name in SQL
+func (t dataType) sqlName() string {
+	switch t {
+	case typeInt16:
+		return "smallint"
+	case typeInt32:
+		return "int"
+	case typeInt64:
+		return "bigint"
+	default:
+		return strings.ToLower(t.String())
+	}
+}
+
+// protoName returns the type name in table API of api server
+func (t dataType) protoName() string {
+	switch t {
+	case typeBool:
+		return "kBool"
+	case typeInt16:
+		return "kSmallInt"
+	case typeInt32:
+		return "kInt"
+	case typeInt64:
+		return "kBigInt"
+	case typeFloat:
+		return "kFloat"
+	case typeDouble:
+		return "kDouble"
+	case typeString:
+		return "kVarchar"
+	case typeTimestamp:
+		return "kTimestamp"
+	case typeDate:
+		return "kDate"
+	default:
+		return "kUnknown"
+	}
+}
+
+func (t dataType) isInteger() bool {
+	return t == typeInt16 || t == typeInt32 || t == typeInt64
+}
+
+func (t dataType) isNumeric() bool {
+	return t.isInteger() || t == typeFloat || t == typeDouble
+}
+
+// coerce converts v into a value of type t
+func coerce(v any, t dataType) (any, error) {
+	if v == nil || t == typeNull {
+		return v, nil
+	}
+
+	switch t {
+	case typeBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case typeInt16, typeInt32, typeInt64:
+		var i int64
+		switch vv := v.(type) {
+		case int16, int32, int64:
+			i = toInt64(vv)
+		case float32, float64:
+			i = int64(toFloat64(vv))
+		case string:
+			n, err := strconv.ParseInt(vv, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("can not convert '%s' to %s", vv, t.sqlName())
+			}
+			i = n
+		default:
+			return nil, fmt.Errorf("can not convert %v to %s", v, t.sqlName())
+		}
+		switch t {
+		case typeInt16:
+			if i < math.MinInt16 || i > math.MaxInt16 {
+				return nil, fmt.Errorf("value %d out of range of smallint", i)
+			}
+			return int16(i), nil
+		case typeInt32:
+			if i < math.MinInt32 || i > math.MaxInt32 {
+				return nil, fmt.Errorf("value %d out of range of int", i)
+			}
+			return int32(i), nil
+		default:
+			return i, nil
+		}
+	case typeFloat, typeDouble:
+		var f float64
+		switch vv := v.(type) {
+		case int16, int32, int64, float32, float64:
+			f = toFloat64(vv)
+		case string:
+			n, err := strconv.ParseFloat(vv, 64)
+			if err != nil {
+				return nil, fmt.Errorf("can not convert '%s' to %s", vv, t.sqlName())
+			}
+			f = n
+		default:
+			return nil, fmt.Errorf("can not convert %v to %s", v, t.sqlName())
+		}
+		if t == typeFloat {
+			return float32(f), nil
+		}
+		return f, nil
+	case typeString:
+		switch vv := v.(type) {
+		case string:
+			return vv, nil
+		case time.Time:
+			return vv.Format(time.DateTime), nil
+		default:
+			return fmt.Sprint(vv), nil
+		}
+	case typeTimestamp:
+		switch vv := v.(type) {
+		case time.Time:
+			return vv, nil
+		case int16, int32, int64:
+			return time.UnixMilli(toInt64(vv)), nil
+		case string:
+			for _, layout := range []string{time.DateTime, time.DateOnly, time.RFC3339} {
+				if ts, err := time.ParseInLocation(layout, vv, time.Local); err == nil {
+					return ts, nil
+				}
+			}
+			return nil, fmt.Errorf("can not convert '%s' to timestamp", vv)
+		}
+	case typeDate:
+		switch vv := v.(type) {
+		case time.Time:
+			y, m, d := vv.Date()
+			return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+		case string:
+			d, err := time.Parse(time.DateOnly, vv)
+			if err != nil {
+				return nil, fmt.Errorf("can not convert '%s' to date", vv)
+			}
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("can not convert %v to %s", v, t.sqlName())
+}
+
+// typeOf returns the type of a go value
+func typeOf(v any) dataType {
+	switch v.(type) {
+	case bool:
+		return typeBool
+	case int16:
+		return typeInt16
+	case int32:
+		return typeInt32
+	case int64:
+		return typeInt64
+	case float32:
+		return typeFloat
+	case float64:
+		return typeDouble
+	case string:
+		return typeString
+	case time.Time:
+		return typeTimestamp
+	default:
+		return typeNull
+	}
+}
+
+func toInt64(v any) int64 {
+	switch vv := v.(type) {
+	case int16:
+		return int64(vv)
+	case int32:
+		return int64(vv)
+	case int64:
+		return vv
+	case float32:
+		return int64(vv)
+	case float64:
+		return int64(vv)
+	case time.Time:
+		return vv.UnixMilli()
+	case bool:
+		if vv {
+			return 1
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+func toFloat64(v any) float64 {
+	switch vv := v.(type) {
+	case float32:
+		return float64(vv)
+	case float64:
+		return vv
+	default:
+		return float64(toInt64(v))
+	}
+}
+
+// encodeValue converts value of type t into JSON value in api server response
+func encodeValue(v any, t dataType) any {
+	ts, ok := v.(time.Time)
+	if !ok {
+		return v
+	}
+	if t == typeDate {
+		return ts.Format(time.DateOnly)
+	}
+	return ts.UnixMilli()
+}
+
+// decodeValue converts JSON value in api server request into value of type t
+func decodeValue(v any, t dataType) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if t == typeTimestamp {
+		if f, ok := v.(float64); ok {
+			return time.UnixMilli(int64(f)), nil
+		}
+	}
+	if f, ok := v.(float64); ok && t.isInteger() && f != math.Trunc(f) {
+		return nil, fmt.Errorf("value %v is not an integer", f)
+	}
+	return coerce(v, t)
+}
+
+// compareValues compares two non-null values, returns -1, 0 or 1
+func compareValues(a, b any) (int, error) {
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, fmt.Errorf("can not compare string with %v", b)
+		}
+		return strings.Compare(av, bv), nil
+	case bool:
+		bv, ok := b.(bool)
+		if !ok {
+			return 0, fmt.Errorf("can not compare bool with %v", b)
+		}
+		return cmpInt(toInt64(av), toInt64(bv)), nil
+	}
+
+	_, aFloat := a.(float32)
+	_, aDouble := a.(float64)
+	_, bFloat := b.(float32)
+	_, bDouble := b.(float64)
+	if aFloat || aDouble || bFloat || bDouble {
+		af, bf := toFloat64(a), toFloat64(b)
+		switch {
+		case af < bf:
+			return -1, nil
+		case af > bf:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	}
+	if _, ok := b.(string); ok {
+		return 0, fmt.Errorf("can not compare %v with string", a)
+	}
+	return cmpInt(toInt64(a), toInt64(b)), nil
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -1,14 +1,84 @@
 package openmldb
 
 import (
+	"database/sql/driver"
+	"fmt"
 	"time"
 )
 
-func parseDateStr(src string) (time.Time, error) {
+// timeCodec decodes timestamp and date values in responses, by Config.Loc and Config.RawTime,
+// and sends dates by Config.Loc. The zero value decodes timestamps in time.Local and dates at
+// midnight UTC, and sends dates in the location of the value.
+type timeCodec struct {
+	loc *time.Location
+	raw bool
+}
+
+// timestamp decodes timestamp value returned as int64 millisecond unix epoch time
+func (tc timeCodec) timestamp(ms int64) any {
+	switch {
+	case tc.raw:
+		return ms
+	case tc.loc != nil:
+		return time.UnixMilli(ms).In(tc.loc)
+	default:
+		return time.UnixMilli(ms)
+	}
+}
+
+// date decodes date value returned as string formatted 'yyyy-mm-dd'
+func (tc timeCodec) date(src string) (any, error) {
+	loc := time.UTC
+	if tc.loc != nil {
+		loc = tc.loc
+	}
+	t, err := parseDateStr(src, loc)
+	if err != nil {
+		return nil, err
+	}
+	if tc.raw {
+		return src, nil
+	}
+	return t, nil
+}
+
+// dateIn returns t in the location its date is sent in
+func (tc timeCodec) dateIn(t time.Time) time.Time {
+	if tc.loc != nil {
+		return t.In(tc.loc)
+	}
+	return t
+}
+
+// dates converts valid NullDate parameters into the location their dates are sent in, the
+// others are kept
+func (tc timeCodec) dates(parameters []driver.Value) []driver.Value {
+	if tc.loc == nil {
+		return parameters
+	}
+	var converted []driver.Value
+	for i, p := range parameters {
+		d, ok := p.(NullDate)
+		if !ok || !d.Valid {
+			continue
+		}
+		if converted == nil {
+			converted = append([]driver.Value(nil), parameters...)
+		}
+		d.V = tc.dateIn(d.V)
+		converted[i] = d
+	}
+	if converted == nil {
+		return parameters
+	}
+	return converted
+}
+
+func parseDateStr(src string, loc *time.Location) (time.Time, error) {
 	// api server returns date type as string formatted 'yyyy-mm-dd'
-	dval, err := time.Parse(time.DateOnly, src)
+	dval, err := time.ParseInLocation(time.DateOnly, src, loc)
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, fmt.Errorf("invalid date %q", src)
 	}
 
 	return dval, nil
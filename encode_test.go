@@ -0,0 +1,148 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+func TestTimeCodec(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+
+	assert.Equal(t, time.UnixMilli(1000), timeCodec{}.timestamp(1000))
+	date, err := timeCodec{}.date("2024-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), date)
+
+	tc := timeCodec{loc: shanghai}
+	assert.Equal(t, time.UnixMilli(1000).In(shanghai), tc.timestamp(1000))
+	date, err = tc.date("2024-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, shanghai), date)
+
+	tc = timeCodec{raw: true}
+	assert.Equal(t, int64(1000), tc.timestamp(1000))
+	date, err = tc.date("2024-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02", date)
+	_, err = tc.date("2024/01/02")
+	assert.EqualError(t, err, `invalid date "2024/01/02"`)
+
+	// dates are sent in loc, if any
+	d := NullDate{sql.Null[time.Time]{V: time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC), Valid: true}}
+	params := []driver.Value{"a", d, NullDate{}}
+	assert.Equal(t, params, timeCodec{}.dates(params))
+	sent := timeCodec{loc: shanghai}.dates(params)
+	assert.Equal(t, "2024-01-03", sent[1].(NullDate).V.Format(time.DateOnly))
+	assert.Equal(t, []driver.Value{"a", NullDate{}}, []driver.Value{sent[0], sent[2]})
+	assert.Equal(t, d, params[1])
+}
+
+func TestNullDateScan(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+
+	var d NullDate
+	require.NoError(t, d.Scan(time.Date(2024, 1, 2, 23, 30, 0, 0, shanghai)))
+	assert.Equal(t, NullDate{sql.Null[time.Time]{V: time.Date(2024, 1, 2, 0, 0, 0, 0, shanghai), Valid: true}}, d)
+	require.NoError(t, d.Scan("2024-01-03"))
+	assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), d.V)
+	require.NoError(t, d.Scan(nil))
+	assert.False(t, d.Valid)
+	assert.EqualError(t, d.Scan("x"), `invalid date "x"`)
+	assert.EqualError(t, d.Scan(int64(1)), "unsupported type int64 for date")
+}
+
+func TestTimeLocation(t *testing.T) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	open := func(params string) *sql.DB {
+		db, err := sql.Open("openmldb", srv.DSN("test_db")+params)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	db := open("?loc=Asia%2FShanghai")
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, ts timestamp, dt date, INDEX(KEY=c1, TS=ts))")
+	require.NoError(t, err)
+	// 2024-01-02 23:30 in Shanghai is 2024-01-02 15:30 UTC
+	ts := time.Date(2024, 1, 2, 15, 30, 0, 0, time.UTC)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES (?, ?, ?)", "a", ts,
+		NullDate{sql.Null[time.Time]{V: time.Date(2024, 1, 2, 0, 0, 0, 0, shanghai), Valid: true}})
+	require.NoError(t, err)
+
+	rows, err := db.QueryContext(ctx, "SELECT ts, dt FROM t1")
+	require.NoError(t, err)
+	types, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	assert.Equal(t, "TIMESTAMP", types[0].DatabaseTypeName())
+	assert.Equal(t, "DATE", types[1].DatabaseTypeName())
+	var at, dt time.Time
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&at, &dt))
+	require.NoError(t, rows.Close())
+	assert.Equal(t, time.Date(2024, 1, 2, 23, 30, 0, 0, shanghai), at)
+	assert.True(t, at.Equal(ts))
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, shanghai), dt)
+
+	// timestamp scanned as date is its date in the location decoded
+	var d NullDate
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT ts FROM t1").Scan(&d))
+	assert.Equal(t, dt, d.V)
+
+	var (
+		ms  int64
+		day string
+	)
+	raw := open("?parseTime=false")
+	rows, err = raw.QueryContext(ctx, "SELECT ts, dt FROM t1")
+	require.NoError(t, err)
+	types, err = rows.ColumnTypes()
+	require.NoError(t, err)
+	assert.Equal(t, "int64", types[0].ScanType().String())
+	assert.Equal(t, "string", types[1].ScanType().String())
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&ms, &day))
+	require.NoError(t, rows.Close())
+	assert.Equal(t, ts.UnixMilli(), ms)
+	assert.Equal(t, "2024-01-02", day)
+	require.NoError(t, raw.QueryRowContext(ctx, "SELECT dt FROM t1").Scan(&d))
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), d.V)
+
+	// dates are sent in the location, 2024-01-02 20:00 UTC is 2024-01-03 in Shanghai
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES (?, ?, ?)", "b", ts,
+		NullDate{sql.Null[time.Time]{V: time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC), Valid: true}})
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT dt FROM t1 WHERE c1 = 'b'").Scan(&dt))
+	assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, shanghai), dt)
+}
+
+func TestDeploymentDateValue(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+
+	d := NullDate{sql.Null[time.Time]{V: time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC), Valid: true}}
+	v, err := deploymentValue(d, timeCodec{})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02", v)
+	// 2024-01-02 20:00 UTC is 2024-01-03 in Shanghai
+	v, err = deploymentValue(d, timeCodec{loc: shanghai})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-03", v)
+	v, err = deploymentValue(NullDate{}, timeCodec{loc: shanghai})
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
@@ -0,0 +1,156 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// Op is the kind of request to api server.
+type Op int
+
+const (
+	// OpQuery is a SQL statement by Exec or Query.
+	OpQuery Op = iota
+	// OpDeployment is a deployment call by CallDeployment.
+	OpDeployment
+	// OpPut is a row insert by PutRow or PutRows.
+	OpPut
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpQuery:
+		return "query"
+	case OpDeployment:
+		return "deployment"
+	case OpPut:
+		return "put"
+	default:
+		return "unknown"
+	}
+}
+
+// Request is a request to api server seen by hooks. Hooks may modify it in Before.
+type Request struct {
+	Op Op
+	// Endpoint is the host:port of api server.
+	Endpoint string
+	// DB is the database name.
+	DB string
+	// Mode is the execution mode of OpQuery.
+	Mode queryMode
+	// SQL and Args are the statement and its parameters of OpQuery.
+	SQL  string
+	Args []driver.Value
+	// Deployment is the deployment name of OpDeployment.
+	Deployment string
+	// Table is the table name of OpPut.
+	Table string
+	// Rows are request rows of OpDeployment, or rows of OpPut, with values in JSON representation.
+	Rows [][]any
+}
+
+// Result is the result of a request seen by hooks.
+type Result struct {
+	// Columns are names of result columns, known for OpDeployment only.
+	Columns []string
+	// Types are types of result columns, e.g. "int32" or "string".
+	Types []string
+	// Rows are result rows, only set when Before short-circuits OpQuery or OpDeployment.
+	// Values are as returned by the driver, e.g. int32, string or time.Time.
+	Rows [][]driver.Value
+	// RowCount is the number of rows returned, or put for OpPut. For OpQuery, it counts the
+	// rows read before rows closed.
+	RowCount int
+	// Duration is the time spent on the request, until rows closed for OpQuery.
+	Duration time.Duration
+}
+
+// Hook runs before and after each request to api server, registered by Config.Hooks, for
+// logging, metrics, auditing, rewriting SQL or guarding requests. Pings of connections are
+// not seen by hooks.
+type Hook interface {
+	// Before is called before request sent, it may modify req, and returns the context
+	// passed to the following hooks and After.
+	//
+	// Returning a non-nil Result or error short-circuits the request: it is not sent, and
+	// Before of the following hooks are skipped. The Result is returned to the caller instead,
+	// e.g. rows of Types and Rows for OpQuery.
+	Before(ctx context.Context, req *Request) (context.Context, *Result, error)
+	// After is called when request done, with its result or error. For OpQuery returning
+	// rows, it is called when the rows closed.
+	After(ctx context.Context, req *Request, res *Result, err error)
+}
+
+// HookFuncs adapts functions to Hook, nil functions are skipped.
+type HookFuncs struct {
+	BeforeFunc func(ctx context.Context, req *Request) (context.Context, *Result, error)
+	AfterFunc  func(ctx context.Context, req *Request, res *Result, err error)
+}
+
+// Before implements Hook.
+func (h HookFuncs) Before(ctx context.Context, req *Request) (context.Context, *Result, error) {
+	if h.BeforeFunc == nil {
+		return ctx, nil, nil
+	}
+	return h.BeforeFunc(ctx, req)
+}
+
+// After implements Hook.
+func (h HookFuncs) After(ctx context.Context, req *Request, res *Result, err error) {
+	if h.AfterFunc != nil {
+		h.AfterFunc(ctx, req, res, err)
+	}
+}
+
+// before runs Before of hooks in order, until one short-circuits. The returned after
+// runs After of hooks ran in reverse order, with duration since before called.
+func (c *conn) before(ctx context.Context, req *Request) (context.Context, *Result, func(*Result, error), error) {
+	start := time.Now()
+	ctxs := make([]context.Context, 0, len(c.hooks))
+
+	var (
+		res *Result
+		err error
+	)
+	for _, h := range c.hooks {
+		var hctx context.Context
+		hctx, res, err = h.Before(ctx, req)
+		if hctx != nil {
+			ctx = hctx
+		}
+		ctxs = append(ctxs, ctx)
+		if res != nil || err != nil {
+			break
+		}
+	}
+
+	after := func(res *Result, err error) {
+		if res == nil {
+			res = &Result{}
+		}
+		res.Duration = time.Since(start)
+		for i := len(ctxs) - 1; i >= 0; i-- {
+			c.hooks[i].After(ctxs[i], req, res, err)
+		}
+	}
+	return ctx, res, after, err
+}
+
+// intercept runs do with hooks around, do is skipped if short-circuited
+func (c *conn) intercept(ctx context.Context, req *Request, do func(context.Context, *Request) (*Result, error)) (*Result, error) {
+	if len(c.hooks) == 0 {
+		return do(ctx, req)
+	}
+
+	ctx, res, after, err := c.before(ctx, req)
+	if res == nil && err == nil {
+		res, err = do(ctx, req)
+	}
+	if res != nil && res.RowCount == 0 {
+		res.RowCount = len(res.Rows)
+	}
+	after(res, err)
+	return res, err
+}
@@ -0,0 +1,145 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+func openHookedDB(t *testing.T, hooks ...Hook) *sql.DB {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+
+	connector, err := NewConnector(&Config{Host: srv.Host(), DB: "test_db", Hooks: hooks})
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type ctxKey struct{}
+
+// recordHook records calls with its name, and checks context passed from Before to After
+type recordHook struct {
+	name   string
+	events *[]string
+}
+
+func (h recordHook) Before(ctx context.Context, req *Request) (context.Context, *Result, error) {
+	*h.events = append(*h.events, fmt.Sprintf("%s before %s %s%s%s", h.name, req.Op, req.SQL, req.Deployment, req.Table))
+	return context.WithValue(ctx, ctxKey{}, h.name), nil, nil
+}
+
+func (h recordHook) After(ctx context.Context, req *Request, res *Result, err error) {
+	if ctx.Value(ctxKey{}) != h.name {
+		panic("context not passed to After")
+	}
+	if res.Duration <= 0 {
+		panic("duration not set")
+	}
+	*h.events = append(*h.events, fmt.Sprintf("%s after %s rows=%d err=%v", h.name, req.Op, res.RowCount, err))
+}
+
+func TestHooks(t *testing.T) {
+	var events []string
+	db := openHookedDB(t, recordHook{"h1", &events}, recordHook{"h2", &events})
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES ('aa', 1), ('bb', 2)")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, "SELECT c1 FROM t1")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	_, err = db.QueryContext(ctx, "SELECT c9 FROM t1")
+	require.Error(t, err)
+
+	_, err = db.ExecContext(ctx, "DEPLOY demo SELECT c1 FROM t1")
+	require.NoError(t, err)
+	events = nil
+	_, err = CallDeployment(ctx, db, "demo", []any{"cc", 3})
+	require.NoError(t, err)
+	require.NoError(t, PutRow(ctx, db, "t1", map[string]any{"c1": "dd"}))
+
+	assert.Equal(t, []string{
+		"h1 before deployment demo",
+		"h2 before deployment demo",
+		"h2 after deployment rows=1 err=<nil>",
+		"h1 after deployment rows=1 err=<nil>",
+		"h1 before put t1",
+		"h2 before put t1",
+		"h2 after put rows=1 err=<nil>",
+		"h1 after put rows=1 err=<nil>",
+	}, events)
+}
+
+func TestHookQueryResult(t *testing.T) {
+	var results []string
+	db := openHookedDB(t, HookFuncs{AfterFunc: func(ctx context.Context, req *Request, res *Result, err error) {
+		results = append(results, fmt.Sprintf("%s %s %v %d %v", req.SQL, req.Mode, res.Types, res.RowCount, err))
+	}})
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES ('aa', 1), ('bb', 2)")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, "SELECT c1, c2 FROM t1 WHERE c2 > ?", 0)
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	_, err = db.QueryContext(ctx, "SELECT c9 FROM t1")
+	require.Error(t, err)
+
+	assert.Equal(t, []string{
+		"CREATE TABLE t1 (c1 string, c2 int) online [] 0 <nil>",
+		"INSERT INTO t1 VALUES ('aa', 1), ('bb', 2) online [] 0 <nil>",
+		"SELECT c1, c2 FROM t1 WHERE c2 > ? online [String Int32] 2 <nil>",
+		"SELECT c9 FROM t1 online [] 0 execute error: column c9 not found",
+	}, results)
+}
+
+func TestHookRewriteAndShortCircuit(t *testing.T) {
+	errReadOnly := errors.New("read only")
+	db := openHookedDB(t, HookFuncs{BeforeFunc: func(ctx context.Context, req *Request) (context.Context, *Result, error) {
+		switch {
+		case strings.HasPrefix(req.SQL, "DROP"):
+			return ctx, nil, errReadOnly
+		case req.SQL == "SELECT version()":
+			return ctx, &Result{Types: []string{"string"}, Rows: [][]driver.Value{{"0.9.0"}}}, nil
+		case req.Op == OpPut:
+			req.Rows[0][1] = int64(100)
+		}
+		// rewrite to table in another name
+		req.SQL = strings.ReplaceAll(req.SQL, "logical_table", "t1")
+		return ctx, nil, nil
+	}})
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, "CREATE TABLE logical_table (c1 string, c2 int)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DROP TABLE t1")
+	assert.ErrorIs(t, err, errReadOnly)
+	require.NoError(t, PutRow(ctx, db, "t1", map[string]any{"c1": "aa", "c2": 1}))
+
+	var version string
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT version()").Scan(&version))
+	assert.Equal(t, "0.9.0", version)
+
+	var c2 int32
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT c2 FROM logical_table WHERE c1 = ?", "aa").Scan(&c2))
+	assert.Equal(t, int32(100), c2)
+}
@@ -0,0 +1,282 @@
+package sqlparse
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kind is the kind of statement.
+type Kind int
+
+const (
+	// KindOther is a statement of none of the other kinds, e.g. STOP JOB.
+	KindOther Kind = iota
+	// KindDDL is a statement defines schema, e.g. CREATE TABLE or DROP INDEX.
+	KindDDL
+	// KindDML is a statement modifies data, e.g. INSERT or DELETE.
+	KindDML
+	// KindDQL is a query, e.g. SELECT.
+	KindDQL
+	// KindDeploy is DEPLOY or DROP DEPLOYMENT.
+	KindDeploy
+	// KindSet is SET of variables.
+	KindSet
+	// KindUse is USE of database.
+	KindUse
+	// KindShow is SHOW, DESC or DESCRIBE.
+	KindShow
+	// KindLoadData is LOAD DATA INFILE.
+	KindLoadData
+	// KindSelectInto is SELECT INTO OUTFILE.
+	KindSelectInto
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindDDL:
+		return "DDL"
+	case KindDML:
+		return "DML"
+	case KindDQL:
+		return "DQL"
+	case KindDeploy:
+		return "DEPLOY"
+	case KindSet:
+		return "SET"
+	case KindUse:
+		return "USE"
+	case KindShow:
+		return "SHOW"
+	case KindLoadData:
+		return "LOAD DATA"
+	case KindSelectInto:
+		return "SELECT INTO"
+	default:
+		return "OTHER"
+	}
+}
+
+// ErrMultiStatements is returned by Classify for SQL of more than one statement.
+var ErrMultiStatements = errors.New("multiple statements")
+
+// Statement is the classification of a statement.
+type Statement struct {
+	Kind Kind
+	// Verb is the leading keywords in upper case, e.g. SELECT, CREATE TABLE or LOAD DATA.
+	Verb string
+	// Tables are the tables referenced, in the order of first appearance, as db.table if
+	// qualified in SQL.
+	Tables []string
+	// Placeholders is the number of '?' placeholders.
+	Placeholders int
+	// Tokens are the tokens of statement, ending with EOF, trailing ';' excluded.
+	Tokens []Token
+}
+
+// Classify classifies a single statement, with optional trailing ';'.
+func Classify(sql string) (*Statement, error) {
+	toks, err := Lex(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	// trailing semicolons are allowed, others split statements
+	end := len(toks) - 1
+	for end > 0 && toks[end-1].Text == ";" {
+		end--
+	}
+	for _, t := range toks[:end] {
+		if t.Kind == Punct && t.Text == ";" {
+			return nil, ErrMultiStatements
+		}
+	}
+	toks = append(toks[:end:end], toks[len(toks)-1])
+
+	s := &Statement{Tokens: toks}
+	s.classify()
+	for _, t := range toks {
+		if t.Kind == Placeholder {
+			s.Placeholders++
+		}
+	}
+	s.Tables = tables(toks, s.Verb)
+	return s, nil
+}
+
+func (s *Statement) classify() {
+	toks := s.Tokens
+	// leading parentheses of query, e.g. (SELECT ...)
+	i := 0
+	for toks[i].Kind == Punct && toks[i].Text == "(" {
+		i++
+	}
+	first := toks[i]
+	if first.Kind != Ident {
+		return
+	}
+	verb := strings.ToUpper(first.Text)
+	second := strings.ToUpper(toks[i+1].Text)
+	if toks[i+1].Kind != Ident {
+		second = ""
+	}
+
+	switch verb {
+	case "CREATE", "DROP", "ALTER", "TRUNCATE":
+		s.Kind = KindDDL
+		if second == "AGGREGATE" && toks[i+2].Is("FUNCTION") {
+			second = "FUNCTION"
+		}
+		if second != "" {
+			verb += " " + second
+		}
+		if verb == "DROP DEPLOYMENT" {
+			s.Kind = KindDeploy
+		}
+	case "INSERT", "DELETE", "UPDATE", "REPLACE":
+		s.Kind = KindDML
+	case "SELECT", "WITH", "EXPLAIN":
+		s.Kind = KindDQL
+		if verb != "EXPLAIN" && selectInto(toks[i:]) {
+			s.Kind, verb = KindSelectInto, "SELECT INTO"
+		}
+	case "DEPLOY":
+		s.Kind = KindDeploy
+	case "SET":
+		s.Kind = KindSet
+	case "USE":
+		s.Kind = KindUse
+	case "SHOW":
+		s.Kind = KindShow
+		if second != "" {
+			verb += " " + second
+		}
+	case "DESC", "DESCRIBE":
+		s.Kind = KindShow
+	case "LOAD":
+		if second == "DATA" {
+			s.Kind, verb = KindLoadData, "LOAD DATA"
+		}
+	default:
+		if second != "" && verb == "STOP" {
+			verb += " " + second
+		}
+	}
+	s.Verb = verb
+}
+
+// selectInto reports whether query has INTO OUTFILE out of parentheses
+func selectInto(toks []Token) bool {
+	depth := 0
+	for i, t := range toks {
+		switch {
+		case t.Kind == Punct && t.Text == "(":
+			depth++
+		case t.Kind == Punct && t.Text == ")":
+			depth--
+		case depth <= 0 && t.Is("INTO") && toks[i+1].Is("OUTFILE"):
+			return true
+		}
+	}
+	return false
+}
+
+// tables returns the tables referenced by statement
+func tables(toks []Token, verb string) []string {
+	var names []string
+	add := func(i int) {
+		if name, ok := tableName(toks, i); ok {
+			names = appendUnique(names, name)
+		}
+	}
+
+	for i, t := range toks {
+		switch {
+		case t.Is("FROM") || t.Is("JOIN"):
+			add(i + 1)
+		case t.Is("INTO"):
+			if !toks[i+1].Is("OUTFILE") {
+				if toks[i+1].Is("TABLE") {
+					i++
+				}
+				add(i + 1)
+			}
+		case t.Is("UNION"):
+			// window union of tables, not union of queries
+			next := toks[i+1]
+			switch {
+			case next.Is("ALL") || next.Is("DISTINCT") || next.Is("SELECT"):
+			case next.Kind == Punct && next.Text == "(":
+				if toks[i+2].Is("SELECT") {
+					continue
+				}
+				for j := i + 2; j < len(toks) && toks[j].Text != ")"; j++ {
+					if toks[j-1].Text == "(" || toks[j-1].Text == "," {
+						add(j)
+					}
+				}
+			default:
+				add(i + 1)
+			}
+		case t.Is("TABLE") && i > 0 && toks[i-1].Kind == Ident && verb != "SHOW TABLE":
+			j := i + 1
+			if toks[j].Is("IF") {
+				j++
+				if toks[j].Is("NOT") {
+					j++
+				}
+				j++ // EXISTS
+			}
+			add(j)
+		case t.Is("ON") && verb == "CREATE INDEX":
+			add(i + 1)
+		case i == 2 && verb == "DROP INDEX":
+			// DROP INDEX [db.]table.index, the last part is index
+			if parts := dotted(toks, i); len(parts) > 1 {
+				names = appendUnique(names, strings.Join(parts[:len(parts)-1], "."))
+			}
+		case i == 1 && (verb == "DESC" || verb == "DESCRIBE"):
+			add(i)
+		}
+	}
+	return names
+}
+
+// tableName returns the name of table at i, ok is false if it is not a name, e.g. subquery
+func tableName(toks []Token, i int) (string, bool) {
+	if i >= len(toks) {
+		return "", false
+	}
+	t := toks[i]
+	if t.Kind != Ident && t.Kind != QuotedIdent {
+		return "", false
+	}
+	if t.Kind == Ident && (t.Is("SELECT") || t.Is("TABLE") || t.Is("OUTFILE")) {
+		return "", false
+	}
+	name := t.Value
+	if i+2 < len(toks) && toks[i+1].Text == "." && (toks[i+2].Kind == Ident || toks[i+2].Kind == QuotedIdent) {
+		name += "." + toks[i+2].Value
+	}
+	return name, true
+}
+
+// dotted returns the parts of dotted name a.b.c starting at i
+func dotted(toks []Token, i int) []string {
+	var parts []string
+	for ; i < len(toks) && (toks[i].Kind == Ident || toks[i].Kind == QuotedIdent); i += 2 {
+		parts = append(parts, toks[i].Value)
+		if i+1 >= len(toks) || toks[i+1].Text != "." {
+			break
+		}
+	}
+	return parts
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
@@ -0,0 +1,89 @@
+package sqlparse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	for _, c := range []struct {
+		sql    string
+		kind   Kind
+		verb   string
+		tables []string
+	}{
+		{"CREATE TABLE IF NOT EXISTS t1 (c1 string, INDEX(KEY=c1))", KindDDL, "CREATE TABLE", []string{"t1"}},
+		{"drop table if exists db1.`t 1`;", KindDDL, "DROP TABLE", []string{"db1.t 1"}},
+		{"CREATE INDEX idx ON t1 (c1) OPTIONS (TS=ts)", KindDDL, "CREATE INDEX", []string{"t1"}},
+		{"DROP INDEX db1.t1.idx", KindDDL, "DROP INDEX", []string{"db1.t1"}},
+		{"CREATE DATABASE db1", KindDDL, "CREATE DATABASE", nil},
+		{"TRUNCATE TABLE t1", KindDDL, "TRUNCATE TABLE", []string{"t1"}},
+		{"INSERT INTO t1 VALUES (?, 'FROM t2')", KindDML, "INSERT", []string{"t1"}},
+		{"DELETE FROM t1 WHERE c1 = ?", KindDML, "DELETE", []string{"t1"}},
+		{"SELECT c1 FROM t1 LAST JOIN t2 ORDER BY t2.ts ON t1.c1 = t2.c1", KindDQL, "SELECT", []string{"t1", "t2"}},
+		{"(SELECT c1 FROM (SELECT * FROM t1) AS s)", KindDQL, "SELECT", []string{"t1"}},
+		{"SELECT 1", KindDQL, "SELECT", nil},
+		{"SELECT sum(c2) OVER w FROM t1 WINDOW w AS (UNION (t2, db.t3) PARTITION BY c1 ORDER BY ts ROWS BETWEEN 2 PRECEDING AND CURRENT ROW)",
+			KindDQL, "SELECT", []string{"t1", "t2", "db.t3"}},
+		{"SELECT c1 FROM t1 UNION ALL SELECT c1 FROM t2", KindDQL, "SELECT", []string{"t1", "t2"}},
+		{"SELECT c1 FROM t1 INTO OUTFILE '/tmp/out' OPTIONS (mode='overwrite')", KindSelectInto, "SELECT INTO", []string{"t1"}},
+		{"DEPLOY d1 SELECT c1 FROM t1", KindDeploy, "DEPLOY", []string{"t1"}},
+		{"DROP DEPLOYMENT d1", KindDeploy, "DROP DEPLOYMENT", nil},
+		{"SET @@execute_mode = 'online'", KindSet, "SET", nil},
+		{"USE db1", KindUse, "USE", nil},
+		{"SHOW TABLES", KindShow, "SHOW TABLES", nil},
+		{"SHOW CREATE TABLE t1", KindShow, "SHOW CREATE", []string{"t1"}},
+		{"SHOW TABLE STATUS", KindShow, "SHOW TABLE", nil},
+		{"DESC t1", KindShow, "DESC", []string{"t1"}},
+		{"LOAD DATA INFILE 'file:///tmp/in.csv' INTO TABLE t1 OPTIONS (header=true)", KindLoadData, "LOAD DATA", []string{"t1"}},
+		{"STOP JOB 1", KindOther, "STOP JOB", nil},
+		{"-- only comment", KindOther, "", nil},
+	} {
+		s, err := Classify(c.sql)
+		require.NoError(t, err, c.sql)
+		assert.Equal(t, c.kind, s.Kind, c.sql)
+		assert.Equal(t, c.verb, s.Verb, c.sql)
+		assert.Equal(t, c.tables, s.Tables, c.sql)
+	}
+}
+
+func TestClassifyPlaceholders(t *testing.T) {
+	s, err := Classify("SELECT * FROM t1 WHERE c1 = ? AND c2 = '?' AND c3 = ? -- ?\n;;")
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Placeholders)
+	assert.Equal(t, EOF, s.Tokens[len(s.Tokens)-1].Kind)
+	assert.Equal(t, "?", s.Tokens[len(s.Tokens)-2].Text)
+}
+
+func TestClassifyError(t *testing.T) {
+	_, err := Classify("SELECT 1; SELECT 2")
+	assert.ErrorIs(t, err, ErrMultiStatements)
+
+	_, err = Classify("SELECT 'a")
+	var lexErr *Error
+	require.ErrorAs(t, err, &lexErr)
+	assert.Equal(t, 7, lexErr.Pos)
+}
+
+func TestSplit(t *testing.T) {
+	script := `-- create tables
+CREATE TABLE t1 (c1 string);;
+INSERT INTO t1 VALUES ('a;b'); /* ; */
+SELECT "x;" FROM t1 -- ;
+`
+	spans, err := Split(script)
+	require.NoError(t, err)
+	require.Len(t, spans, 3)
+	assert.Equal(t, "CREATE TABLE t1 (c1 string)", spans[0].Text)
+	assert.Equal(t, "INSERT INTO t1 VALUES ('a;b')", spans[1].Text)
+	assert.Equal(t, `SELECT "x;" FROM t1`, spans[2].Text)
+	for _, s := range spans {
+		assert.Equal(t, s.Text, script[s.Pos:s.Pos+len(s.Text)])
+	}
+
+	spans, err = Split(" ; -- nothing")
+	require.NoError(t, err)
+	assert.Empty(t, spans)
+}
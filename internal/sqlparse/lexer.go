@@ -0,0 +1,278 @@
+// Package sqlparse is a lexer and statement classifier of OpenMLDB SQL, for the driver to
+// understand SQL it sends without a full parser.
+package sqlparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind is the kind of token.
+type TokenKind int
+
+const (
+	// EOF is the end of input, the last token of Lex.
+	EOF TokenKind = iota
+	// Ident is an identifier or keyword, e.g. SELECT or t1.
+	Ident
+	// QuotedIdent is an identifier quoted by backticks, e.g. `t 1`.
+	QuotedIdent
+	// String is a string literal quoted by single or double quotes.
+	String
+	// Number is a number literal, with optional suffix, e.g. 1, 1.5e3, 10L or 3d.
+	Number
+	// Placeholder is a positional parameter '?'.
+	Placeholder
+	// Variable is a system variable prefixed by @@, e.g. @@execute_mode.
+	Variable
+	// Punct is an operator or punctuation, e.g. '(', ';' or '<='.
+	Punct
+	// NamedPlaceholder is a named parameter prefixed by : or @, e.g. :id or @id, its Value is
+	// the name.
+	NamedPlaceholder
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case EOF:
+		return "EOF"
+	case Ident:
+		return "Ident"
+	case QuotedIdent:
+		return "QuotedIdent"
+	case String:
+		return "String"
+	case Number:
+		return "Number"
+	case Placeholder:
+		return "Placeholder"
+	case Variable:
+		return "Variable"
+	case Punct:
+		return "Punct"
+	case NamedPlaceholder:
+		return "NamedPlaceholder"
+	default:
+		return "Unknown"
+	}
+}
+
+// Token is a token in SQL. Whitespaces and comments are not tokens.
+type Token struct {
+	Kind TokenKind
+	// Text is the source text of token, sql[Pos:End].
+	Text string
+	// Value is the unquoted value of String and QuotedIdent, the name of NamedPlaceholder,
+	// and Text of the others.
+	Value string
+	// Pos and End are the byte offsets of token in SQL.
+	Pos, End int
+}
+
+// Is reports whether token is identifier keyword, case-insensitive.
+func (t Token) Is(keyword string) bool {
+	return t.Kind == Ident && strings.EqualFold(t.Text, keyword)
+}
+
+// Error is a lexical error.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at %d", e.Msg, e.Pos)
+}
+
+// multi-char operators, longest first
+var operators = []string{"<=>", "<=", ">=", "<>", "!=", "==", "||", "&&", "<<", ">>", "::"}
+
+// Lex splits sql into tokens, the last one is EOF. On error, tokens before the error
+// are returned with it.
+func Lex(sql string) ([]Token, error) {
+	var toks []Token
+	i := 0
+	for {
+		var err error
+		if i, err = skip(sql, i); err != nil {
+			return append(toks, eof(sql)), err
+		}
+		if i >= len(sql) {
+			return append(toks, eof(sql)), nil
+		}
+
+		start := i
+		c := sql[i]
+		tok := Token{Pos: start}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			value, end, ok := scanQuoted(sql, i)
+			if !ok {
+				return append(toks, eof(sql)), &Error{Pos: start, Msg: "unterminated quoted string"}
+			}
+			tok.Kind, tok.Value, i = String, value, end
+			if c == '`' {
+				tok.Kind = QuotedIdent
+			}
+		case c == '?':
+			tok.Kind, i = Placeholder, i+1
+		case c == '@':
+			j := i + 1
+			system := j < len(sql) && sql[j] == '@'
+			if system {
+				j++
+			}
+			k := j
+			for k < len(sql) && (isIdentChar(sql[k]) || (system && sql[k] == '.')) {
+				k++
+			}
+			switch {
+			case k == j:
+				tok.Kind, i = Punct, i+1
+			case system:
+				tok.Kind, i = Variable, k
+			default:
+				tok.Kind, tok.Value, i = NamedPlaceholder, sql[j:k], k
+			}
+		case c == ':' && i+1 < len(sql) && isIdentChar(sql[i+1]):
+			k := i + 1
+			for k < len(sql) && isIdentChar(sql[k]) {
+				k++
+			}
+			tok.Kind, tok.Value, i = NamedPlaceholder, sql[i+1:k], k
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			tok.Kind, i = Number, scanNumber(sql, i)
+		case isIdentChar(c):
+			for i < len(sql) && isIdentChar(sql[i]) {
+				i++
+			}
+			tok.Kind = Ident
+		default:
+			tok.Kind, i = Punct, i+1
+			for _, op := range operators {
+				if strings.HasPrefix(sql[start:], op) {
+					i = start + len(op)
+					break
+				}
+			}
+		}
+		tok.End = i
+		tok.Text = sql[start:i]
+		if tok.Kind != String && tok.Kind != QuotedIdent && tok.Kind != NamedPlaceholder {
+			tok.Value = tok.Text
+		}
+		toks = append(toks, tok)
+	}
+}
+
+// skip skips whitespaces and comments from i
+func skip(sql string, i int) (int, error) {
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '#' || strings.HasPrefix(sql[i:], "--"):
+			j := strings.IndexByte(sql[i:], '\n')
+			if j < 0 {
+				return len(sql), nil
+			}
+			i += j + 1
+		case strings.HasPrefix(sql[i:], "/*"):
+			j := strings.Index(sql[i+2:], "*/")
+			if j < 0 {
+				return i, &Error{Pos: i, Msg: "unterminated comment"}
+			}
+			i += j + 4
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+func eof(sql string) Token {
+	return Token{Kind: EOF, Pos: len(sql), End: len(sql)}
+}
+
+// scanQuoted scans the quoted string starting at i, returns the unquoted value and the
+// offset after closing quote. Quotes are escaped by doubling, or by backslash in strings.
+func scanQuoted(sql string, i int) (string, int, bool) {
+	quote := sql[i]
+	var b strings.Builder
+	for j := i + 1; j < len(sql); j++ {
+		c := sql[j]
+		switch {
+		case c == '\\' && quote != '`' && j+1 < len(sql):
+			j++
+			b.WriteByte(unescape(sql[j]))
+		case c == quote:
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				b.WriteByte(quote)
+				continue
+			}
+			return b.String(), j + 1, true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", len(sql), false
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	default:
+		return c
+	}
+}
+
+// scanNumber scans number with optional fraction, exponent and suffix, e.g. 1.5e-3 or 10L
+func scanNumber(sql string, i int) int {
+	for i < len(sql) && isDigit(sql[i]) {
+		i++
+	}
+	if i < len(sql) && sql[i] == '.' {
+		i++
+		for i < len(sql) && isDigit(sql[i]) {
+			i++
+		}
+	}
+	if i+1 < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		j := i + 1
+		if sql[j] == '+' || sql[j] == '-' {
+			j++
+		}
+		if j < len(sql) && isDigit(sql[j]) {
+			i = j
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+		}
+	}
+	// suffix of type or time unit, e.g. 10L, 1.5f or 3d
+	for i < len(sql) && isIdentChar(sql[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
@@ -0,0 +1,85 @@
+package sqlparse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLex(t *testing.T) {
+	sql := "SELECT `c 1`, 'it''s', \"a\\\"b\" -- comment\n" +
+		"FROM t1 /* block */ WHERE c2 >= ? AND c3 = 1.5e3 # tail\n" +
+		"AND @@execute_mode != 10L;"
+	toks, err := Lex(sql)
+	require.NoError(t, err)
+
+	type tok struct {
+		Kind  TokenKind
+		Value string
+	}
+	var got []tok
+	for _, tk := range toks {
+		got = append(got, tok{tk.Kind, tk.Value})
+		assert.Equal(t, sql[tk.Pos:tk.End], tk.Text)
+	}
+	assert.Equal(t, []tok{
+		{Ident, "SELECT"}, {QuotedIdent, "c 1"}, {Punct, ","}, {String, "it's"}, {Punct, ","},
+		{String, `a"b`}, {Ident, "FROM"}, {Ident, "t1"}, {Ident, "WHERE"}, {Ident, "c2"},
+		{Punct, ">="}, {Placeholder, "?"}, {Ident, "AND"}, {Ident, "c3"}, {Punct, "="},
+		{Number, "1.5e3"}, {Ident, "AND"}, {Variable, "@@execute_mode"}, {Punct, "!="},
+		{Number, "10L"}, {Punct, ";"}, {EOF, ""},
+	}, got)
+}
+
+func TestLexNamedPlaceholder(t *testing.T) {
+	toks, err := Lex("SELECT :id, @name, @@session.sync_job, a::b, ':no', @ FROM t")
+	require.NoError(t, err)
+	var got []string
+	for _, tk := range toks {
+		got = append(got, tk.Kind.String()+" "+tk.Value)
+	}
+	assert.Equal(t, []string{
+		"Ident SELECT", "NamedPlaceholder id", "Punct ,", "NamedPlaceholder name", "Punct ,",
+		"Variable @@session.sync_job", "Punct ,", "Ident a", "Punct ::", "Ident b", "Punct ,", "String :no", "Punct ,",
+		"Punct @", "Ident FROM", "Ident t", "EOF ",
+	}, got)
+}
+
+func TestLexQuotes(t *testing.T) {
+	for sql, value := range map[string]string{
+		`'a\'b'`:     "a'b",
+		`'a\nb'`:     "a\nb",
+		`"it's"`:     "it's",
+		`"a""b"`:     `a"b`,
+		"`a``b`":     "a`b",
+		"`a\\b`":     `a\b`,
+		`'-- no'`:    "-- no",
+		`'/* no */'`: "/* no */",
+		`'?'`:        "?",
+	} {
+		toks, err := Lex(sql)
+		require.NoError(t, err, sql)
+		require.Len(t, toks, 2, sql)
+		assert.Equal(t, value, toks[0].Value, sql)
+	}
+}
+
+func TestLexError(t *testing.T) {
+	for _, c := range []struct {
+		sql  string
+		msg  string
+		toks int
+	}{
+		{"SELECT 'a", "unterminated quoted string at 7", 1},
+		{"SELECT `a", "unterminated quoted string at 7", 1},
+		{`SELECT "a\"`, "unterminated quoted string at 7", 1},
+		{"SELECT 1 /* a", "unterminated comment at 9", 2},
+	} {
+		toks, err := Lex(c.sql)
+		assert.EqualError(t, err, c.msg, c.sql)
+		// tokens before error, and EOF
+		require.Len(t, toks, c.toks+1, c.sql)
+		assert.Equal(t, EOF, toks[c.toks].Kind)
+	}
+}
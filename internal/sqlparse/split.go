@@ -0,0 +1,35 @@
+package sqlparse
+
+// Span is a statement in a script.
+type Span struct {
+	// Text is the source text of statement, from its first token to the last, without ';'.
+	Text string
+	// Pos is the byte offset of statement in script.
+	Pos int
+}
+
+// Split splits script into statements by ';' out of quotes and comments, empty
+// statements are skipped.
+func Split(script string) ([]Span, error) {
+	toks, err := Lex(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var spans []Span
+	start := -1
+	for i, t := range toks {
+		if t.Kind == EOF || (t.Kind == Punct && t.Text == ";") {
+			if start >= 0 {
+				pos := toks[start].Pos
+				spans = append(spans, Span{Text: script[pos:toks[i-1].End], Pos: pos})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	return spans, nil
+}
@@ -0,0 +1,97 @@
+//go:build go1.23
+
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+)
+
+// Rows executes the query and returns an iterator over result rows scanned into T.
+//
+// Columns are mapped to fields of T the same way as ScanAll. Any error, from query
+// or scan, is yielded once and ends the iteration. The underlying rows are closed
+// when iteration ends, including when the loop breaks early:
+//
+//	for demo, err := range openmldb.Rows[Demo](ctx, db, "SELECT * FROM demo") {
+//		if err != nil {
+//			return err
+//		}
+//		// use demo
+//	}
+//
+// Rows are decoded from response on demand, so iterating a large result set
+// takes bounded memory.
+func Rows[T any](ctx context.Context, db *sql.DB, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+
+		s, err := newRowScanner[T](rows)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		for rows.Next() {
+			var v T
+			if err := s.scan(rows, &v); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// RowValues is like Rows, but yields each row as a slice of column values.
+//
+// Value types follow the SQL types in result: bool, int16, int32, int64, float32,
+// float64, string and time.Time, map[K]V for MAP<K, V> and []T for ARRAY<T> columns of
+// these, e.g. map[string]int32 and []int64, or nil for NULL. The yielded slice is not reused.
+func RowValues(ctx context.Context, db *sql.DB, query string, args ...any) iter.Seq2[[]any, error] {
+	return func(yield func([]any, error) bool) {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer rows.Close()
+
+		cols, err := rows.Columns()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for rows.Next() {
+			values := make([]any, len(cols))
+			dest := make([]any, len(cols))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(values, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
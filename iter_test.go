@@ -0,0 +1,75 @@
+//go:build go1.23
+
+package openmldb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRowsIter(t *testing.T) {
+	db := newTestDB(t, respondWith(`{
+		"code": 0,
+		"msg": "ok",
+		"data": {
+			"schema": ["Int32", "String"],
+			"data": [[1, "bb"], [2, "cc"], [3, "dd"]]
+		}
+	}`))
+
+	type demo struct {
+		C1 int32
+		C2 string
+	}
+
+	var result []demo
+	for v, err := range Rows[demo](context.Background(), db, "SELECT c1, c2 FROM demo") {
+		assert.NoError(t, err)
+		result = append(result, v)
+		if len(result) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []demo{{1, "bb"}, {2, "cc"}}, result)
+	// rows closed on early break, so the single connection is reusable
+	assert.Equal(t, 0, db.Stats().InUse)
+
+	var values [][]any
+	for v, err := range RowValues(context.Background(), db, "SELECT c1, c2 FROM demo") {
+		assert.NoError(t, err)
+		values = append(values, v)
+	}
+	assert.Equal(t, [][]any{{int32(1), "bb"}, {int32(2), "cc"}, {int32(3), "dd"}}, values)
+
+}
+
+func TestRowsIterSingleColumn(t *testing.T) {
+	db := newTestDB(t, respondWith(`{
+		"code": 0,
+		"msg": "ok",
+		"data": {"schema": ["Int32"], "data": [[1], [2], [3]]}
+	}`))
+
+	var ids []int32
+	for v, err := range Rows[int32](context.Background(), db, "SELECT c1 FROM demo") {
+		assert.NoError(t, err)
+		ids = append(ids, v)
+	}
+	assert.Equal(t, []int32{1, 2, 3}, ids)
+}
+
+func TestRowsIterError(t *testing.T) {
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code": -1, "msg": "table not found"}`))
+	})
+
+	n := 0
+	for _, err := range Rows[int32](context.Background(), db, "SELECT c1 FROM demo") {
+		n++
+		assert.EqualError(t, err, "execute error: table not found")
+	}
+	assert.Equal(t, 1, n)
+}
@@ -0,0 +1,174 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+// stopJobTimeout bounds the requests stopping the job of a cancelled statement
+const stopJobTimeout = 10 * time.Second
+
+// jobPollInterval is the interval of polling the state of a job run by runJob
+var jobPollInterval = time.Second
+
+// finishedJobStates are the states of jobs not running, as SHOW JOBS
+var finishedJobStates = map[string]bool{
+	"FINISHED": true,
+	"FAILED":   true,
+	"KILLED":   true,
+	"LOST":     true,
+	"STOPPED":  true,
+}
+
+// submitsJob reports whether sql submits a job run by runJob in mode, i.e. LOAD DATA and
+// SELECT INTO in offsync mode. Queries in offsync mode are not, as their rows are returned by
+// the sync job only, whose id is not known before it finished.
+func submitsJob(mode queryMode, sql string) bool {
+	if mode != ModeOffsync {
+		return false
+	}
+	stmt, err := sqlparse.Classify(sql)
+	if err != nil {
+		return false
+	}
+	return stmt.Kind == sqlparse.KindLoadData || stmt.Kind == sqlparse.KindSelectInto
+}
+
+// requestTimeout returns the milliseconds until deadline of ctx, rounded up, 0 if no deadline.
+func requestTimeout(ctx context.Context) (int64, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, nil
+	}
+	d := time.Until(deadline)
+	if d <= 0 {
+		return 0, context.DeadlineExceeded
+	}
+	return int64(math.Ceil(float64(d) / float64(time.Millisecond))), nil
+}
+
+// runJob runs a statement submitting a job in offsync mode. It is submitted in offasync mode
+// instead, so that the job id is known, and waited by polling SHOW JOB. The job is stopped by
+// STOP JOB of the id if ctx is done before it finished, so cancelled jobs are not left running
+// in cluster. Returns the job info of the finished job, as SHOW JOB.
+func (c *conn) runJob(ctx context.Context, db string, sql string, parameters ...driver.Value) (driver.Rows, error) {
+	rows, err := c.send(ctx, db, ModeOffasync, sql, parameters...)
+	if err != nil {
+		return nil, err
+	}
+	info, err := readJob(rows)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := asInt64(info.Data[0][0])
+
+	timer := time.NewTimer(jobPollInterval)
+	defer timer.Stop()
+	for {
+		if state, _ := info.Data[0][2].(string); finishedJobStates[strings.ToUpper(state)] {
+			if !strings.EqualFold(state, "FINISHED") {
+				err := fmt.Errorf("job %d %s", id, strings.ToLower(state))
+				if msg, _ := info.Data[0][len(info.Data[0])-1].(string); msg != "" {
+					err = fmt.Errorf("%w: %s", err, msg)
+				}
+				return nil, err
+			}
+			return info, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, c.stopJob(ctx, db, id)
+		case <-timer.C:
+			timer.Reset(jobPollInterval)
+		}
+		if rows, err = c.send(ctx, db, ModeOnline, fmt.Sprintf("SHOW JOB %d", id)); err == nil {
+			info, err = readJob(rows)
+		}
+		if err != nil {
+			if jobCancelled(ctx) {
+				return nil, c.stopJob(ctx, db, id)
+			}
+			return nil, err
+		}
+	}
+}
+
+// jobCancelled reports whether ctx is done, or its deadline passed, since requests fail by
+// the deadline before ctx done
+func jobCancelled(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// stopJob stops job id of the statement cancelled by ctx, and returns the error of ctx,
+// with the error stopping the job if any
+func (c *conn) stopJob(ctx context.Context, db string, id int64) error {
+	err := ctx.Err()
+	if err == nil {
+		err = context.DeadlineExceeded
+	}
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopJobTimeout)
+	defer cancel()
+
+	rows, stopErr := c.send(stopCtx, db, ModeOnline, fmt.Sprintf("STOP JOB %d", id))
+	if rows != nil {
+		rows.Close()
+	}
+	if stopErr != nil {
+		return fmt.Errorf("%w, stop job %d: %v", err, id, stopErr)
+	}
+	return err
+}
+
+// readJob reads the job info returned by submitting a job, SHOW JOB or STOP JOB, columns of
+// which are id, job_type, state, start_time, end_time, parameter, cluster, application_id
+// and error
+func readJob(rows driver.Rows) (*respDataRows, error) {
+	if rows == nil {
+		return nil, errors.New("invalid response: no job info")
+	}
+	defer rows.Close()
+
+	r := rows.(*respDataRows)
+	info := &respDataRows{respData: respData{Schema: r.Schema}, times: r.times}
+	for {
+		dest := make([]driver.Value, len(r.Schema))
+		if err := r.Next(dest); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		info.Data = append(info.Data, dest)
+	}
+	if len(info.Data) != 1 || len(r.Schema) < 3 {
+		return nil, fmt.Errorf("invalid response: %d rows of %d columns of job info", len(info.Data), len(r.Schema))
+	}
+	if _, ok := asInt64(info.Data[0][0]); !ok {
+		return nil, fmt.Errorf("invalid response: job id %v", info.Data[0][0])
+	}
+	return info, nil
+}
+
+func asInt64(v driver.Value) (int64, bool) {
+	switch n := v.(type) {
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
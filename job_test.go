@@ -0,0 +1,219 @@
+package openmldb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+// queryTransport records query requests sent
+type queryTransport struct {
+	mu   sync.Mutex
+	reqs []queryReq
+}
+
+func (t *queryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "POST" && req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		var q queryReq
+		if json.Unmarshal(b, &q) == nil {
+			t.mu.Lock()
+			t.reqs = append(t.reqs, q)
+			t.mu.Unlock()
+		}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestJobTimeout(t *testing.T) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+	interval := jobPollInterval
+	jobPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { jobPollInterval = interval })
+
+	open := func(mode queryMode) (*sql.DB, *queryTransport) {
+		tr := &queryTransport{}
+		connector, err := NewConnector(&Config{Host: srv.Host(), DB: "test_db", Mode: mode,
+			HTTPClient: &http.Client{Transport: tr}})
+		require.NoError(t, err)
+		db := sql.OpenDB(connector)
+		t.Cleanup(func() { db.Close() })
+		return db, tr
+	}
+	jobs := func(db *sql.DB) (states []string) {
+		rows, err := db.QueryContext(ctx, "SHOW JOBS")
+		require.NoError(t, err)
+		defer rows.Close()
+		var (
+			id                int32
+			typ, state, param string
+			start, end        sql.Null[time.Time]
+			cluster, app, e   sql.NullString
+		)
+		for rows.Next() {
+			require.NoError(t, rows.Scan(&id, &typ, &state, &start, &end, &param, &cluster, &app, &e))
+			if param != "SELECT 1" {
+				// jobs of ping ignored
+				states = append(states, state)
+			}
+		}
+		require.NoError(t, rows.Err())
+		return states
+	}
+	sqls := func(tr *queryTransport) (s []string) {
+		for _, req := range tr.reqs {
+			s = append(s, req.SQL)
+		}
+		return s
+	}
+
+	online, tr := open(ModeOnline)
+	_, err := online.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int, INDEX(KEY=c1))")
+	require.NoError(t, err)
+
+	t.Run("online", func(t *testing.T) {
+		tctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		rows, err := online.QueryContext(tctx, "SELECT c1 FROM t1")
+		require.NoError(t, err)
+		rows.Close()
+		last := tr.reqs[len(tr.reqs)-1]
+		assert.Equal(t, "SELECT c1 FROM t1", last.SQL)
+		assert.InDelta(t, time.Minute.Milliseconds(), last.Timeout, 1000)
+		assert.Empty(t, jobs(online))
+	})
+
+	offline, tr := open(ModeOffsync)
+	require.NoError(t, offline.PingContext(ctx))
+	t.Run("query", func(t *testing.T) {
+		tr.reqs = nil
+		rows, err := offline.QueryContext(ctx, "SELECT c1 FROM t1")
+		require.NoError(t, err)
+		rows.Close()
+		require.Len(t, tr.reqs, 1)
+		assert.Zero(t, tr.reqs[0].Timeout)
+
+		// sent with the request timeout only
+		tctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		tr.reqs = nil
+		rows, err = offline.QueryContext(tctx, "SELECT c1 FROM t1")
+		require.NoError(t, err)
+		rows.Close()
+		require.Len(t, tr.reqs, 1)
+		assert.Equal(t, "offsync", tr.reqs[0].Mode)
+		assert.InDelta(t, time.Minute.Milliseconds(), tr.reqs[0].Timeout, 1000)
+		assert.Equal(t, []string{"FINISHED", "FINISHED"}, jobs(online))
+	})
+
+	const export = "SELECT c1 FROM t1 INTO OUTFILE '/tmp/t1.csv'"
+	t.Run("finished", func(t *testing.T) {
+		tctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		tr.reqs = nil
+		rows, err := offline.QueryContext(tctx, export)
+		require.NoError(t, err)
+		defer rows.Close()
+		cols, err := rows.Columns()
+		require.NoError(t, err)
+		dest := make([]any, len(cols))
+		for i := range dest {
+			dest[i] = new(any)
+		}
+		require.True(t, rows.Next())
+		require.NoError(t, rows.Scan(dest...))
+		assert.Equal(t, "RunBatchSql", *dest[1].(*any))
+		assert.Equal(t, "FINISHED", *dest[2].(*any))
+		rows.Close()
+		require.Len(t, tr.reqs, 1)
+		assert.Equal(t, "offasync", tr.reqs[0].Mode)
+		assert.Equal(t, []string{"FINISHED", "FINISHED", "FINISHED"}, jobs(online))
+	})
+
+	srv.SetJobDuration(time.Hour)
+	t.Run("query deadline", func(t *testing.T) {
+		tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		tr.reqs = nil
+		_, err := offline.QueryContext(tctx, "SELECT c2 FROM t1")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// job id unknown, left to the request timeout of api server
+		assert.Equal(t, []string{"SELECT c2 FROM t1"}, sqls(tr))
+		assert.Equal(t, []string{"FINISHED", "FINISHED", "FINISHED", "RUNNING"}, jobs(online))
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		tr.reqs = nil
+		_, err := offline.ExecContext(tctx, export)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		reqs := sqls(tr)
+		require.Greater(t, len(reqs), 2)
+		assert.Equal(t, export, reqs[0])
+		id := regexp.MustCompile(`^SHOW JOB (\d+)$`).FindStringSubmatch(reqs[1])
+		require.NotNil(t, id, reqs[1])
+		assert.Equal(t, "STOP JOB "+id[1], reqs[len(reqs)-1])
+		assert.Equal(t, []string{"FINISHED", "FINISHED", "FINISHED", "RUNNING", "STOPPED"}, jobs(online))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		// job of the same SQL by another client kept running
+		async, _ := open(ModeOffasync)
+		_, err := async.ExecContext(ctx, export)
+		require.NoError(t, err)
+
+		tctx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(50*time.Millisecond, cancel)
+		tr.reqs = nil
+		_, err = offline.ExecContext(tctx, export)
+		assert.ErrorIs(t, err, context.Canceled)
+		reqs := sqls(tr)
+		assert.Equal(t, strings.Replace(reqs[1], "SHOW", "STOP", 1), reqs[len(reqs)-1])
+		assert.Equal(t, []string{"FINISHED", "FINISHED", "FINISHED", "RUNNING", "STOPPED", "RUNNING", "STOPPED"},
+			jobs(online))
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		tctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		tr.reqs = nil
+		time.AfterFunc(50*time.Millisecond, func() {
+			tr.mu.Lock()
+			stop := strings.Replace(tr.reqs[1].SQL, "SHOW", "STOP", 1)
+			tr.mu.Unlock()
+			_, err := online.ExecContext(ctx, stop)
+			assert.NoError(t, err)
+		})
+		_, err := offline.ExecContext(tctx, export)
+		assert.Regexp(t, `^job \d+ stopped$`, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		tctx, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+		defer cancel()
+		tr.reqs = nil
+		_, err := offline.QueryContext(tctx, export)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, tr.reqs)
+	})
+}
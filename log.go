@@ -0,0 +1,146 @@
+package openmldb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+// logHook logs requests by Config.Logger, installed before all hooks of Config.Hooks
+type logHook struct {
+	logger      *slog.Logger
+	slow        time.Duration
+	sampleEvery int
+	values      bool
+
+	n atomic.Uint64
+}
+
+func newLogHook(cfg *Config) *logHook {
+	return &logHook{
+		logger:      cfg.Logger,
+		slow:        cfg.SlowThreshold,
+		sampleEvery: cfg.LogSampleEvery,
+		values:      cfg.LogValues,
+	}
+}
+
+// Before implements Hook.
+func (h *logHook) Before(ctx context.Context, req *Request) (context.Context, *Result, error) {
+	return ctx, nil, nil
+}
+
+// After implements Hook.
+func (h *logHook) After(ctx context.Context, req *Request, res *Result, err error) {
+	slow := h.slow > 0 && res.Duration >= h.slow
+	debug := h.logger.Enabled(ctx, slog.LevelDebug) && h.sample()
+	if !slow && !debug {
+		return
+	}
+
+	attrs := h.attrs(req, res, err)
+	if slow {
+		attrs = append(attrs, slog.Duration("threshold", h.slow))
+		h.logger.LogAttrs(ctx, slog.LevelWarn, "openmldb slow request", attrs...)
+	} else {
+		h.logger.LogAttrs(ctx, slog.LevelDebug, "openmldb request", attrs...)
+	}
+}
+
+// sample reports whether the request is sampled for debug log, 1 of every sampleEvery requests
+func (h *logHook) sample() bool {
+	if h.sampleEvery <= 1 {
+		return true
+	}
+	return (h.n.Add(1)-1)%uint64(h.sampleEvery) == 0
+}
+
+func (h *logHook) attrs(req *Request, res *Result, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("op", req.Op.String()),
+		slog.String("endpoint", req.Endpoint),
+		slog.String("db", req.DB),
+	}
+	switch req.Op {
+	case OpQuery:
+		sql := req.SQL
+		if !h.values {
+			sql = redactSQL(sql)
+		}
+		attrs = append(attrs, slog.String("mode", req.Mode.String()), slog.String("sql", sql))
+		if len(req.Args) > 0 {
+			if h.values {
+				attrs = append(attrs, slog.Any("args", req.Args))
+			} else {
+				attrs = append(attrs, slog.Int("args", len(req.Args)))
+			}
+		}
+	case OpDeployment:
+		attrs = append(attrs, slog.String("deployment", req.Deployment))
+	case OpPut:
+		attrs = append(attrs, slog.String("table", req.Table))
+	}
+	if req.Op != OpQuery && h.values {
+		attrs = append(attrs, slog.Any("input", req.Rows))
+	}
+
+	attrs = append(attrs, slog.Int("rows", res.RowCount), slog.Duration("duration", res.Duration))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", h.redactError(err)))
+	}
+	return attrs
+}
+
+// redactError returns the message of err, with string literals redacted like SQL unless
+// values logged, as server errors may quote values of the statement
+func (h *logHook) redactError(err error) string {
+	if h.values {
+		return err.Error()
+	}
+	return redactSQL(err.Error())
+}
+
+// redactSQL replaces string literals in sql with '?', quoted identifiers and comments are kept
+func redactSQL(sql string) string {
+	toks, err := sqlparse.Lex(sql)
+
+	var b strings.Builder
+	b.Grow(len(sql))
+	last := 0
+	for _, tok := range toks {
+		if tok.Kind == sqlparse.String {
+			b.WriteString(sql[last:tok.Pos])
+			b.WriteByte('?')
+			last = tok.End
+		}
+	}
+	var lexErr *sqlparse.Error
+	if errors.As(err, &lexErr) && sql[lexErr.Pos] != '/' && sql[lexErr.Pos] != '`' {
+		// unterminated string literal to the end
+		b.WriteString(sql[last:lexErr.Pos])
+		b.WriteByte('?')
+		return b.String()
+	}
+	b.WriteString(sql[last:])
+	return b.String()
+}
+
+// logRetry logs a retry of writer at warning level, with string literals in error redacted
+// as rows are not logged
+func logRetry(logger *slog.Logger, table string, attempt int, rows int, backoff time.Duration, err error) {
+	if logger == nil {
+		return
+	}
+	logger.LogAttrs(context.Background(), slog.LevelWarn, "openmldb writer retry",
+		slog.String("table", table),
+		slog.Int("attempt", attempt),
+		slog.Int("rows", rows),
+		slog.Duration("backoff", backoff),
+		slog.String("error", redactSQL(fmt.Sprint(err))))
+}
@@ -0,0 +1,149 @@
+package openmldb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+func openLoggedDB(t *testing.T, cfg Config) (*sql.DB, *bytes.Buffer) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+
+	var buf bytes.Buffer
+	cfg.Host, cfg.DB = srv.Host(), "test_db"
+	cfg.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	connector, err := NewConnector(&cfg)
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	return db, &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	dec := json.NewDecoder(buf)
+	for {
+		var line map[string]any
+		if err := dec.Decode(&line); err == io.EOF {
+			return lines
+		} else {
+			require.NoError(t, err)
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	db, buf := openLoggedDB(t, Config{})
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES ('secret', ?)", 1)
+	require.NoError(t, err)
+	_, err = db.QueryContext(ctx, "SELECT c9 FROM t1")
+	require.Error(t, err)
+	require.NoError(t, PutRow(ctx, db, "t1", map[string]any{"c1": "secret"}))
+
+	lines := logLines(t, buf)
+	require.Len(t, lines, 4)
+	assert.Equal(t, map[string]any{
+		"level": "DEBUG", "msg": "openmldb request", "op": "query", "endpoint": lines[1]["endpoint"], "db": "test_db",
+		"mode": "online", "sql": "INSERT INTO t1 VALUES (?, ?)", "args": float64(1), "rows": float64(0),
+	}, lines[1])
+	assert.Equal(t, "execute error: column c9 not found", lines[2]["error"])
+	assert.Equal(t, "t1", lines[3]["table"])
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestLogValues(t *testing.T) {
+	db, buf := openLoggedDB(t, Config{LogValues: true})
+	_, err := db.Exec("CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t1 VALUES ('secret', ?)", 1)
+	require.NoError(t, err)
+
+	lines := logLines(t, buf)
+	assert.Equal(t, "INSERT INTO t1 VALUES ('secret', ?)", lines[1]["sql"])
+	assert.Equal(t, []any{float64(1)}, lines[1]["args"])
+}
+
+func TestLogRedactsErrors(t *testing.T) {
+	failHook := HookFuncs{BeforeFunc: func(ctx context.Context, req *Request) (context.Context, *Result, error) {
+		return ctx, nil, errors.New("execute error: invalid value 'secret' of c1")
+	}}
+	for values, expect := range map[bool]string{
+		false: "execute error: invalid value ? of c1",
+		true:  "execute error: invalid value 'secret' of c1",
+	} {
+		db, buf := openLoggedDB(t, Config{LogValues: values, Hooks: []Hook{failHook}})
+		_, err := db.Exec("SELECT 1")
+		require.Error(t, err)
+
+		lines := logLines(t, buf)
+		require.Len(t, lines, 1)
+		assert.Equal(t, expect, lines[0]["error"])
+	}
+}
+
+func TestLogSlowAndSampling(t *testing.T) {
+	slowHook := HookFuncs{BeforeFunc: func(ctx context.Context, req *Request) (context.Context, *Result, error) {
+		if req.SQL == "SELECT c1 FROM t1 WHERE c2 > 0" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return ctx, nil, nil
+	}}
+	db, buf := openLoggedDB(t, Config{SlowThreshold: 10 * time.Millisecond, LogSampleEvery: 3, Hooks: []Hook{slowHook}})
+	_, err := db.Exec("CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = db.Exec("INSERT INTO t1 VALUES ('aa', 1)")
+		require.NoError(t, err)
+	}
+	rows, err := db.Query("SELECT c1 FROM t1 WHERE c2 > 0")
+	require.NoError(t, err)
+	rows.Close()
+
+	lines := logLines(t, buf)
+	var msgs []string
+	for _, line := range lines {
+		msgs = append(msgs, line["msg"].(string)+" "+line["sql"].(string))
+	}
+	assert.Equal(t, []string{
+		"openmldb request CREATE TABLE t1 (c1 string, c2 int)",
+		"openmldb request INSERT INTO t1 VALUES (?, 1)",
+		"openmldb slow request SELECT c1 FROM t1 WHERE c2 > 0",
+	}, msgs)
+	assert.Equal(t, "WARN", lines[2]["level"])
+	assert.Equal(t, float64(10*time.Millisecond), lines[2]["threshold"])
+}
+
+func TestRedactSQL(t *testing.T) {
+	for sql, expect := range map[string]string{
+		"SELECT * FROM t1 WHERE c1 = 'a''b' AND c2 = \"x\\\"y\"": "SELECT * FROM t1 WHERE c1 = ? AND c2 = ?",
+		"SELECT `c'1` FROM `t1` WHERE c2 = 10":                   "SELECT `c'1` FROM `t1` WHERE c2 = 10",
+		"SELECT 'unterminated":                                   "SELECT ?",
+	} {
+		assert.Equal(t, expect, redactSQL(sql))
+	}
+}
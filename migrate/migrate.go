@@ -0,0 +1,343 @@
+// Package migrate runs versioned schema migrations of an OpenMLDB database, with the applied
+// versions tracked in a table of the database.
+//
+// Migrations are SQL scripts in files named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// statements are executed by openmldb.ExecScript, so `SET @@execute_mode` may switch to offline
+// mode, e.g. to LOAD DATA. OpenMLDB has no transactional DDL, a migration failed half way is
+// marked dirty, and must be fixed by hand and then Force.
+//
+// OpenMLDB specifics:
+//   - DEPLOY of a deployment already exists re-deploys it, by DROP DEPLOYMENT before DEPLOY, so
+//     a migration changing tables may deploy the same name with the new SQL.
+//   - CREATE INDEX on a table with data loads the index from offline data of the table, it is
+//     refused unless WithOfflineIndex, since the offline data must be loaded beforehand.
+//
+// Versions are recorded by appending events to the table, as OpenMLDB has no UPDATE, and the
+// table is read by full scan in online mode.
+//
+// The lock against concurrent migrations is appended to a table the same way, with no
+// conditional write: an owner holds it by the earliest unexpired acquire, and checks again after
+// WithLockSettle for acquires not visible yet. Clocks of migrators are assumed to differ by less
+// than the settle delay, otherwise two migrators may both hold the lock, so it is advisory.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+)
+
+var (
+	// ErrDirty is returned when a migration failed half way before, fix it and Force the version.
+	ErrDirty = errors.New("migrate: dirty database")
+	// ErrNoDown is returned by Down for a migration without down script.
+	ErrNoDown = errors.New("migrate: no down migration")
+	// ErrIndexNeedsOffline is returned for CREATE INDEX on a table with data without WithOfflineIndex.
+	ErrIndexNeedsOffline = errors.New("migrate: index on table with data requires offline data")
+)
+
+// Migration is a versioned change of schema.
+type Migration struct {
+	Version uint64
+	Name    string
+	// Up and Down are the SQL scripts to apply and revert the migration, Down is optional.
+	Up, Down string
+}
+
+var fileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads migrations from files named <version>_<name>.up.sql and <version>_<name>.down.sql
+// in the root of fsys, sorted by version. Other files are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, e := range entries {
+		m := fileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: invalid version of %s: %w", e.Name(), err)
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: version %d has different names %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d has no up migration", mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Option configures Migrator.
+type Option func(*options)
+
+type options struct {
+	table        string
+	dryRun       io.Writer
+	owner        string
+	lockTTL      time.Duration
+	lockSettle   time.Duration
+	offlineIndex bool
+}
+
+// WithTable sets the table tracking versions, default to schema_migrations. The lock table is
+// the table name suffixed with _lock.
+func WithTable(name string) Option {
+	return func(o *options) { o.table = name }
+}
+
+// WithDryRun writes the statements to w instead of executing them, and leaves versions unchanged.
+func WithDryRun(w io.Writer) Option {
+	return func(o *options) { o.dryRun = w }
+}
+
+// WithOwner sets the owner of lock, default to hostname and pid.
+func WithOwner(owner string) Option {
+	return func(o *options) { o.owner = owner }
+}
+
+// WithLockTTL sets how long the lock is held if not released, e.g. process crashed, default to 1 hour.
+func WithLockTTL(d time.Duration) Option {
+	return func(o *options) { o.lockTTL = d }
+}
+
+// WithLockSettle sets how long to wait after acquiring the lock before checking the holder
+// again, for acquires of other owners not visible yet, default to 1 second.
+func WithLockSettle(d time.Duration) Option {
+	return func(o *options) { o.lockSettle = d }
+}
+
+// WithOfflineIndex allows CREATE INDEX on tables with data, which loads the index from the
+// offline data of table.
+func WithOfflineIndex() Option {
+	return func(o *options) { o.offlineIndex = true }
+}
+
+// Migrator applies and reverts migrations of the database of db.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	opts       options
+}
+
+// New creates Migrator of migrations, versions must be unique.
+func New(db *sql.DB, migrations []Migration, opts ...Option) (*Migrator, error) {
+	hostname, _ := os.Hostname()
+	o := options{
+		table:      "schema_migrations",
+		owner:      fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		lockTTL:    time.Hour,
+		lockSettle: time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("migrate: duplicate version %d", sorted[i].Version)
+		}
+	}
+	return &Migrator{db: db, migrations: sorted, opts: o}, nil
+}
+
+// Status is the state of a migration in database.
+type Status struct {
+	Migration
+	Applied bool
+	// Dirty is set if the migration failed half way.
+	Dirty bool
+	// At is the time it is applied, reverted or failed, zero if never.
+	At time.Time
+}
+
+// Status reports the state of all migrations, and of versions recorded in database but
+// unknown to Migrator, with name only.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	states, err := m.states(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	known := map[uint64]bool{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		st := Status{Migration: mig}
+		if e, ok := states[mig.Version]; ok {
+			st.Applied, st.Dirty, st.At = e.event == eventUp, e.event == eventDirty, e.at
+		}
+		statuses = append(statuses, st)
+	}
+	for version, e := range states {
+		if !known[version] && e.event != eventDown {
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: version, Name: e.name},
+				Applied:   e.event == eventUp, Dirty: e.event == eventDirty, At: e.at,
+			})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Up applies all pending migrations in order of version.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.UpTo(ctx, ^uint64(0))
+}
+
+// UpTo applies pending migrations of versions up to version, in order.
+func (m *Migrator) UpTo(ctx context.Context, version uint64) error {
+	return m.locked(ctx, false, func(states map[uint64]state, cat *catalog) error {
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if states[mig.Version].event == eventUp {
+				continue
+			}
+			if err := m.run(ctx, states, cat, mig, mig.Up, eventUp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations, in reverse order of version.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, false, func(states map[uint64]state, cat *catalog) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if states[mig.Version].event != eventUp {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("%w: version %d", ErrNoDown, mig.Version)
+			}
+			if err := m.run(ctx, states, cat, mig, mig.Down, eventDown); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Force marks migrations of versions up to version applied and the others reverted, without
+// executing them, clears dirty state after a failed migration fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version uint64) error {
+	return m.locked(ctx, true, func(states map[uint64]state, _ *catalog) error {
+		for _, mig := range m.migrations {
+			event := eventUp
+			if mig.Version > version {
+				event = eventDown
+			}
+			if e, ok := states[mig.Version]; e.event == event || (!ok && event == eventDown) {
+				continue
+			}
+			if err := m.record(ctx, states, mig, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// locked runs fn with lock held, and the current states and catalog loaded. Migrations are
+// refused if dirty, unless force.
+func (m *Migrator) locked(ctx context.Context, force bool, fn func(map[uint64]state, *catalog) error) error {
+	if m.opts.dryRun == nil {
+		if err := m.init(ctx); err != nil {
+			return err
+		}
+		if err := m.lock(ctx); err != nil {
+			return err
+		}
+		defer m.unlock(context.WithoutCancel(ctx))
+	}
+
+	states, err := m.states(ctx)
+	if err != nil {
+		return err
+	}
+	if !force {
+		for version, e := range states {
+			if e.event == eventDirty {
+				return fmt.Errorf("%w: version %d %s", ErrDirty, version, e.name)
+			}
+		}
+	}
+	cat, err := m.catalog(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(states, cat)
+}
+
+// run executes script of migration, and records the event
+func (m *Migrator) run(ctx context.Context, states map[uint64]state, cat *catalog, mig Migration, script string,
+	event string) error {
+	stmts, warnings, err := m.prepare(ctx, cat, script)
+	if err != nil {
+		return fmt.Errorf("migrate: version %d %s: %w", mig.Version, event, err)
+	}
+
+	if w := m.opts.dryRun; w != nil {
+		fmt.Fprintf(w, "-- %d_%s %s\n", mig.Version, mig.Name, event)
+		for _, warning := range warnings {
+			fmt.Fprintf(w, "-- WARNING: %s\n", warning)
+		}
+		for _, stmt := range stmts {
+			fmt.Fprintf(w, "%s;\n", stmt)
+		}
+		return nil
+	}
+
+	if _, err := openmldb.ExecScript(ctx, m.db, strings.NewReader(strings.Join(stmts, ";\n"))); err != nil {
+		if recErr := m.record(context.WithoutCancel(ctx), states, mig, eventDirty); recErr != nil {
+			err = errors.Join(err, recErr)
+		}
+		return fmt.Errorf("migrate: version %d %s: %w", mig.Version, event, err)
+	}
+	return m.record(ctx, states, mig, event)
+}
@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+var testFS = fstest.MapFS{
+	"1_users.up.sql":   {Data: []byte("CREATE TABLE users (id string, age int, ts timestamp, INDEX(KEY=id, TS=ts));")},
+	"1_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"2_deploy.up.sql": {Data: []byte(`
+		INSERT INTO users VALUES ('u1', 20, 1000);
+		DEPLOY d1 SELECT id, age FROM users;`)},
+	"2_deploy.down.sql": {Data: []byte("DROP DEPLOYMENT d1; DELETE FROM users WHERE id = 'u1';")},
+	"3_redeploy.up.sql": {Data: []byte("DEPLOY d1 SELECT id FROM users;")},
+	"README.md":         {Data: []byte("ignored")},
+}
+
+func openDB(t *testing.T) *sql.DB {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	db, err := sql.Open("openmldb", srv.DSN("test_db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFS)
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+	assert.Equal(t, uint64(1), migrations[0].Version)
+	assert.Equal(t, "users", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE users;", migrations[0].Down)
+	assert.Empty(t, migrations[2].Down)
+
+	_, err = Load(fstest.MapFS{"1_a.down.sql": {Data: []byte("SELECT 1")}})
+	assert.EqualError(t, err, "migrate: version 1 has no up migration")
+	_, err = Load(fstest.MapFS{"1_a.up.sql": {}, "1_b.down.sql": {}})
+	assert.EqualError(t, err, "migrate: version 1 has different names a and b")
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	migrations, err := Load(testFS)
+	require.NoError(t, err)
+	m, err := New(db, migrations, WithLockSettle(time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, m.UpTo(ctx, 2))
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.True(t, statuses[0].Applied)
+	assert.True(t, statuses[1].Applied)
+	assert.False(t, statuses[2].Applied)
+	assert.WithinDuration(t, time.Now(), statuses[1].At, time.Minute)
+
+	// d1 re-deployed
+	require.NoError(t, m.Up(ctx))
+	res, err := openmldb.CallDeployment(ctx, db, "d1", []any{"u2", 30, time.UnixMilli(2000)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id"}, res.Columns)
+
+	// 3 has no down
+	assert.ErrorIs(t, m.Down(ctx, 1), ErrNoDown)
+
+	// lock released
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM schema_migrations_lock WHERE event = 'release'").Scan(&n))
+	assert.Equal(t, 3, n)
+}
+
+func TestDirtyAndForce(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	m, err := New(db, []Migration{
+		{Version: 1, Name: "ok", Up: "CREATE TABLE t1 (c1 string)"},
+		{Version: 2, Name: "bad", Up: "CREATE TABLE t2 (c1 string); INSERT INTO t3 VALUES ('a')"},
+		{Version: 3, Name: "next", Up: "CREATE TABLE t4 (c1 string)"},
+	}, WithLockSettle(time.Millisecond))
+	require.NoError(t, err)
+
+	err = m.Up(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "migrate: version 2 up:")
+	assert.ErrorIs(t, m.Up(ctx), ErrDirty)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, statuses[1].Dirty)
+
+	// fixed by hand
+	require.NoError(t, m.Force(ctx, 2))
+	require.NoError(t, m.Up(ctx))
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	for _, st := range statuses {
+		assert.True(t, st.Applied, st.Version)
+	}
+}
+
+func TestRecordOrder(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	m, err := New(db, nil, WithLockSettle(time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, m.init(ctx))
+
+	// events recorded in the same millisecond, and after a later one by clock skew
+	states := map[uint64]state{}
+	mig := Migration{Version: 1, Name: "v1"}
+	for _, event := range []string{eventUp, eventDown, eventDirty, eventUp} {
+		require.NoError(t, m.record(ctx, states, mig, event))
+	}
+	skewed := states[1].at.Add(time.Hour)
+	states[1] = state{name: "v1", event: eventDown, at: skewed}
+	require.NoError(t, m.record(ctx, states, mig, eventUp))
+	assert.Equal(t, skewed.Add(time.Millisecond), states[1].at)
+
+	read, err := m.states(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, eventUp, read[1].event)
+	assert.True(t, read[1].at.Equal(states[1].at), read[1].at)
+}
+
+func TestLock(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	m1, err := New(db, nil, WithOwner("a"), WithLockSettle(time.Millisecond))
+	require.NoError(t, err)
+	m2, err := New(db, nil, WithOwner("b"), WithLockSettle(time.Millisecond))
+	require.NoError(t, err)
+
+	require.NoError(t, m1.init(ctx))
+	require.NoError(t, m1.lock(ctx))
+	assert.ErrorIs(t, m2.lock(ctx), ErrLocked)
+	require.NoError(t, m1.unlock(ctx))
+	require.NoError(t, m2.lock(ctx))
+	assert.ErrorIs(t, m1.lock(ctx), ErrLocked)
+}
+
+func TestLockSameMillisecond(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	m1, err := New(db, nil, WithOwner("a"), WithLockSettle(0))
+	require.NoError(t, err)
+	m2, err := New(db, nil, WithOwner("b"), WithLockSettle(0))
+	require.NoError(t, err)
+	require.NoError(t, m1.init(ctx))
+
+	// acquire and release in the same millisecond by clock, released by the later event
+	last := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	lockClock.mu.Lock()
+	prev := lockClock.last
+	lockClock.last = last
+	lockClock.mu.Unlock()
+	t.Cleanup(func() {
+		lockClock.mu.Lock()
+		lockClock.last = prev
+		lockClock.mu.Unlock()
+	})
+	require.NoError(t, m1.lock(ctx))
+	require.NoError(t, m1.unlock(ctx))
+	assert.Equal(t, last.Add(3*time.Millisecond), lockClock.next())
+	require.NoError(t, m2.lock(ctx))
+	require.NoError(t, m2.unlock(ctx))
+}
+
+func TestLockSettle(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	m1, err := New(db, nil, WithOwner("a"), WithLockSettle(time.Second))
+	require.NoError(t, err)
+	require.NoError(t, m1.init(ctx))
+
+	locked := make(chan error, 1)
+	go func() { locked <- m1.lock(ctx) }()
+	// acquire of b stamped earlier, not visible when a acquired
+	require.Eventually(t, func() bool {
+		var n int64
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM schema_migrations_lock").Scan(&n))
+		return n == 1
+	}, 5*time.Second, time.Millisecond)
+	_, err = db.ExecContext(ctx, "INSERT INTO schema_migrations_lock VALUES (?, ?, ?, ?)",
+		"b", eventAcquire, time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, <-locked, ErrLocked)
+}
+
+func TestDryRunAndOfflineIndex(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	migrations := []Migration{
+		{Version: 1, Name: "t1", Up: "CREATE TABLE t1 (c1 string, c2 int, ts timestamp, INDEX(KEY=c1, TS=ts)); INSERT INTO t1 VALUES ('a', 1, 1000)"},
+		{Version: 2, Name: "index", Up: "CREATE INDEX idx2 ON t1 (c2) OPTIONS (TS=ts)"},
+	}
+	m, err := New(db, migrations, WithLockSettle(time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, m.UpTo(ctx, 1))
+
+	var out bytes.Buffer
+	dry, err := New(db, migrations, WithDryRun(&out))
+	require.NoError(t, err)
+	require.NoError(t, dry.Up(ctx))
+	assert.Equal(t, "-- 2_index up\n"+
+		"-- WARNING: migrate: index on table with data requires offline data: table t1\n"+
+		"CREATE INDEX idx2 ON t1 (c2) OPTIONS (TS=ts);\n", out.String())
+
+	assert.ErrorIs(t, m.Up(ctx), ErrIndexNeedsOffline)
+
+	m, err = New(db, migrations, WithOfflineIndex(), WithLockSettle(time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx))
+}
@@ -0,0 +1,373 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+// events of migrations in version table, the last event of a version is its state
+const (
+	eventUp    = "up"
+	eventDown  = "down"
+	eventDirty = "dirty"
+)
+
+// events of lock table
+const (
+	eventAcquire = "acquire"
+	eventRelease = "release"
+)
+
+// ErrLocked is returned if migrations of the database are run by another owner.
+var ErrLocked = errors.New("migrate: locked")
+
+// state is the last event of a version
+type state struct {
+	name  string
+	event string
+	at    time.Time
+}
+
+func (m *Migrator) lockTable() string {
+	return m.opts.table + "_lock"
+}
+
+// init creates the version table and lock table if not exist
+func (m *Migrator) init(ctx context.Context) error {
+	for _, ddl := range []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version bigint, name string, event string, at timestamp, "+
+			"INDEX(KEY=version, TS=at))", openmldb.QuoteIdentifier(m.opts.table)),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (owner string, event string, at timestamp, expires timestamp, "+
+			"INDEX(KEY=owner, TS=at))", openmldb.QuoteIdentifier(m.lockTable())),
+	} {
+		if _, err := m.db.ExecContext(ctx, ddl); err != nil {
+			return fmt.Errorf("migrate: create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// states returns the last event of each version, empty if version table not exists
+func (m *Migrator) states(ctx context.Context) (map[uint64]state, error) {
+	states := map[uint64]state{}
+	tables, err := m.show(ctx, "TABLES")
+	if err != nil {
+		return nil, err
+	}
+	if !contains(tables, m.opts.table) {
+		return states, nil
+	}
+
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT version, name, event, at FROM %s",
+		openmldb.QuoteIdentifier(m.opts.table)))
+	if err != nil {
+		return nil, fmt.Errorf("migrate: read versions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			version int64
+			e       state
+		)
+		if err := rows.Scan(&version, &e.name, &e.event, &e.at); err != nil {
+			return nil, fmt.Errorf("migrate: read versions: %w", err)
+		}
+		// at of events of a version is strictly increasing, see record
+		if last, ok := states[uint64(version)]; !ok || e.at.After(last.at) {
+			states[uint64(version)] = e
+		}
+	}
+	return states, rows.Err()
+}
+
+// record appends event of migration to version table, and updates states. Events of a
+// version are ordered by at only, as rows of a key are scanned in descending order of at and
+// ties are in no particular order, so at is kept strictly increasing in milliseconds.
+func (m *Migrator) record(ctx context.Context, states map[uint64]state, mig Migration, event string) error {
+	if w := m.opts.dryRun; w != nil {
+		fmt.Fprintf(w, "-- %d_%s marked %s\n", mig.Version, mig.Name, event)
+		return nil
+	}
+	at := time.Now().Truncate(time.Millisecond)
+	if last, ok := states[mig.Version]; ok && !at.After(last.at) {
+		at = last.at.Truncate(time.Millisecond).Add(time.Millisecond)
+	}
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?, ?)",
+		openmldb.QuoteIdentifier(m.opts.table)), int64(mig.Version), mig.Name, event, at)
+	if err != nil {
+		return fmt.Errorf("migrate: record version %d: %w", mig.Version, err)
+	}
+	states[mig.Version] = state{name: mig.Name, event: event, at: at}
+	return nil
+}
+
+// lock acquires the lock of database. As OpenMLDB has no conditional write, each owner
+// appends an acquire event, then the holder is the owner of the earliest unexpired acquire
+// not yet released, the others back off by release. An owner tied with others backs off too.
+// The holder checks again after the settle delay, for acquires not visible at first.
+func (m *Migrator) lock(ctx context.Context) error {
+	at, err := m.lockEvent(ctx, eventAcquire, m.opts.lockTTL)
+	if err != nil {
+		return err
+	}
+
+	holder, expires, err := m.lockHolder(ctx, at)
+	if err == nil && holder == m.opts.owner && m.opts.lockSettle > 0 {
+		timer := time.NewTimer(m.opts.lockSettle)
+		select {
+		case <-timer.C:
+			holder, expires, err = m.lockHolder(ctx, time.Now())
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+		}
+	}
+	if err == nil && holder == m.opts.owner {
+		return nil
+	}
+	if _, relErr := m.lockEvent(context.WithoutCancel(ctx), eventRelease, 0); relErr != nil {
+		return errors.Join(err, relErr)
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w by %s until %s", ErrLocked, holder, expires.Format(time.RFC3339))
+}
+
+func (m *Migrator) unlock(ctx context.Context) error {
+	_, err := m.lockEvent(ctx, eventRelease, 0)
+	return err
+}
+
+// lockEvent appends event of owner expiring after ttl, returns its time. Events of the process
+// are strictly increasing in milliseconds, so the last one of owner is told apart, see lockHolder.
+func (m *Migrator) lockEvent(ctx context.Context, event string, ttl time.Duration) (time.Time, error) {
+	at := lockClock.next()
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?, ?)",
+		openmldb.QuoteIdentifier(m.lockTable())), m.opts.owner, event, at, at.Add(ttl))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("migrate: lock: %w", err)
+	}
+	return at, nil
+}
+
+// lockClock is the time of the last lock event in the process, shared by Migrators of any owner,
+// so events of the process are ordered as made
+var lockClock = &eventClock{}
+
+type eventClock struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// next returns the current time in milliseconds, after the last one returned
+func (c *eventClock) next() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	at := time.Now().Truncate(time.Millisecond)
+	if !at.After(c.last) {
+		at = c.last.Add(time.Millisecond)
+	}
+	c.last = at
+	return at
+}
+
+// lockHolder returns the owner holding lock at now, as seen by the owner of Migrator
+func (m *Migrator) lockHolder(ctx context.Context, now time.Time) (string, time.Time, error) {
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT owner, event, at, expires FROM %s",
+		openmldb.QuoteIdentifier(m.lockTable())))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("migrate: lock: %w", err)
+	}
+	defer rows.Close()
+
+	type lockEvent struct {
+		owner, event string
+		at, expires  time.Time
+	}
+	last := map[string]lockEvent{}
+	for rows.Next() {
+		var e lockEvent
+		if err := rows.Scan(&e.owner, &e.event, &e.at, &e.expires); err != nil {
+			return "", time.Time{}, fmt.Errorf("migrate: lock: %w", err)
+		}
+		// events of an owner are strictly increasing, see lockEvent
+		if l, ok := last[e.owner]; !ok || e.at.After(l.at) {
+			last[e.owner] = e
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", time.Time{}, fmt.Errorf("migrate: lock: %w", err)
+	}
+
+	var acquired []lockEvent
+	for _, e := range last {
+		if e.event == eventAcquire && e.expires.After(now) {
+			acquired = append(acquired, e)
+		}
+	}
+	if len(acquired) == 0 {
+		return "", time.Time{}, fmt.Errorf("migrate: lock: acquire of %s not found", m.opts.owner)
+	}
+	sort.Slice(acquired, func(i, j int) bool {
+		if !acquired[i].at.Equal(acquired[j].at) {
+			return acquired[i].at.Before(acquired[j].at)
+		}
+		return acquired[i].owner < acquired[j].owner
+	})
+	// ties of the same millisecond are held by nobody new, the owner backs off
+	holder := acquired[0]
+	if holder.owner == m.opts.owner && len(acquired) > 1 && acquired[1].at.Equal(holder.at) {
+		holder = acquired[1]
+	}
+	return holder.owner, holder.expires, nil
+}
+
+// catalog is the tables and deployments of database, tracked as statements prepared
+type catalog struct {
+	// tables maps name to whether table exists before migrations run, so it may have data
+	tables      map[string]bool
+	deployments map[string]bool
+}
+
+func (m *Migrator) catalog(ctx context.Context) (*catalog, error) {
+	cat := &catalog{tables: map[string]bool{}, deployments: map[string]bool{}}
+	tables, err := m.show(ctx, "TABLES")
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		cat.tables[t] = true
+	}
+	deployments, err := m.show(ctx, "DEPLOYMENTS")
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments {
+		cat.deployments[d] = true
+	}
+	return cat, nil
+}
+
+// show returns names listed by SHOW, in the last column
+func (m *Migrator) show(ctx context.Context, what string) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, "SHOW "+what)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: show %s: %w", strings.ToLower(what), err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var names []string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		names = append(names, fmt.Sprint(values[len(values)-1]))
+	}
+	return names, rows.Err()
+}
+
+// hasData reports whether table has any row online
+func (m *Migrator) hasData(ctx context.Context, table string) (bool, error) {
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT 1", openmldb.QuoteIdentifier(table)))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// prepare returns the statements of script to execute, with DROP DEPLOYMENT before
+// re-deployed deployments, and the warnings of changes
+func (m *Migrator) prepare(ctx context.Context, cat *catalog, script string) ([]string, []string, error) {
+	spans, err := sqlparse.Split(script)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stmts, warnings []string
+	for _, span := range spans {
+		stmt, err := sqlparse.Classify(span.Text)
+		if err != nil {
+			return nil, nil, err
+		}
+		toks := stmt.Tokens
+
+		switch stmt.Verb {
+		case "DEPLOY":
+			if toks[1].Is("IF") {
+				break
+			}
+			name := toks[1].Value
+			if cat.deployments[name] {
+				warnings = append(warnings, fmt.Sprintf("deployment %s is re-deployed", name))
+				stmts = append(stmts, "DROP DEPLOYMENT "+openmldb.QuoteIdentifier(name))
+			}
+			cat.deployments[name] = true
+		case "DROP DEPLOYMENT":
+			delete(cat.deployments, toks[2].Value)
+		case "CREATE TABLE":
+			for _, t := range stmt.Tables {
+				if _, ok := cat.tables[t]; !ok {
+					cat.tables[t] = false
+				}
+			}
+		case "DROP TABLE":
+			for _, t := range stmt.Tables {
+				warnings = append(warnings, fmt.Sprintf("table %s is dropped", t))
+				delete(cat.tables, t)
+			}
+		case "DROP INDEX":
+			warnings = append(warnings, fmt.Sprintf("index of table %s is dropped", strings.Join(stmt.Tables, ",")))
+		case "CREATE INDEX":
+			for _, t := range stmt.Tables {
+				if !cat.tables[t] {
+					continue
+				}
+				has, err := m.hasData(ctx, t)
+				if err != nil {
+					return nil, nil, err
+				}
+				if !has {
+					continue
+				}
+				if !m.opts.offlineIndex {
+					if m.opts.dryRun == nil {
+						return nil, nil, fmt.Errorf("%w: table %s", ErrIndexNeedsOffline, t)
+					}
+					warnings = append(warnings, fmt.Sprintf("%s: table %s", ErrIndexNeedsOffline, t))
+				} else {
+					warnings = append(warnings, fmt.Sprintf("index of table %s is loaded from offline data", t))
+				}
+			}
+		}
+		stmts = append(stmts, span.Text)
+	}
+	return stmts, warnings, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package openmldb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+// bindNamed rewrites named placeholders :name and @name of query to positional '?', and returns
+// the values in the order of placeholders, taken from arguments by name, e.g. sql.Named("id", 1).
+// A name may be used by several placeholders. Queries of positional placeholders take the
+// values of arguments in order.
+func bindNamed(query string, args []driver.NamedValue) (string, []driver.Value, error) {
+	named := false
+	for _, arg := range args {
+		if arg.Name != "" {
+			named = true
+			break
+		}
+	}
+	if !named && !strings.ContainsAny(query, ":@") {
+		return query, positional(args), nil
+	}
+
+	toks, err := sqlparse.Lex(query)
+	if err != nil {
+		if named {
+			return "", nil, err
+		}
+		// invalid SQL is reported by api server
+		return query, positional(args), nil
+	}
+	var placeholders, names []sqlparse.Token
+	for _, t := range toks {
+		switch t.Kind {
+		case sqlparse.Placeholder:
+			placeholders = append(placeholders, t)
+		case sqlparse.NamedPlaceholder:
+			names = append(names, t)
+		}
+	}
+	switch {
+	case len(names) == 0 && !named:
+		return query, positional(args), nil
+	case len(names) == 0:
+		return "", nil, fmt.Errorf("named arguments but no named placeholders in query")
+	case len(placeholders) > 0:
+		return "", nil, fmt.Errorf("mixed positional and named placeholders in query")
+	}
+
+	values := map[string]driver.Value{}
+	used := map[string]bool{}
+	for _, arg := range args {
+		if arg.Name == "" {
+			return "", nil, fmt.Errorf("positional argument %d for named placeholders, use sql.Named", arg.Ordinal)
+		}
+		values[arg.Name] = arg.Value
+	}
+
+	var (
+		b      strings.Builder
+		last   int
+		params []driver.Value
+	)
+	for _, t := range names {
+		v, ok := values[t.Value]
+		if !ok {
+			return "", nil, fmt.Errorf("missing argument for placeholder %s", t.Text)
+		}
+		used[t.Value] = true
+		params = append(params, v)
+		b.WriteString(query[last:t.Pos])
+		b.WriteByte('?')
+		last = t.End
+	}
+	b.WriteString(query[last:])
+
+	for _, arg := range args {
+		if !used[arg.Name] {
+			return "", nil, fmt.Errorf("named argument %s not used by placeholders", arg.Name)
+		}
+	}
+	return b.String(), params, nil
+}
+
+func positional(args []driver.NamedValue) []driver.Value {
+	parameters := make([]driver.Value, len(args))
+	for i, arg := range args {
+		parameters[i] = arg.Value
+	}
+	return parameters
+}
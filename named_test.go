@@ -0,0 +1,67 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindNamed(t *testing.T) {
+	named := func(name string, v any) driver.NamedValue { return driver.NamedValue{Name: name, Value: v} }
+	for _, c := range []struct {
+		query  string
+		args   []driver.NamedValue
+		sql    string
+		values []driver.Value
+		err    string
+	}{
+		{"SELECT ?, ?", []driver.NamedValue{{Ordinal: 1, Value: 1}, {Ordinal: 2, Value: "a"}},
+			"SELECT ?, ?", []driver.Value{1, "a"}, ""},
+		{"SELECT c1 FROM t WHERE c1 = :id AND c2 > @min OR c3 = :id", []driver.NamedValue{named("min", 1), named("id", "a")},
+			"SELECT c1 FROM t WHERE c1 = ? AND c2 > ? OR c3 = ?", []driver.Value{"a", 1, "a"}, ""},
+		{"SELECT ':id', `@id`, @@execute_mode -- :id", nil,
+			"SELECT ':id', `@id`, @@execute_mode -- :id", []driver.Value{}, ""},
+		{"SELECT :id; SELECT :id", []driver.NamedValue{named("id", 1)}, "SELECT ?; SELECT ?", []driver.Value{1, 1}, ""},
+		{"SELECT 'a", []driver.NamedValue{{Ordinal: 1, Value: 1}}, "SELECT 'a", []driver.Value{1}, ""},
+		{"SELECT :id", nil, "", nil, "missing argument for placeholder :id"},
+		{"SELECT :id", []driver.NamedValue{named("ID", 1)}, "", nil, "missing argument for placeholder :id"},
+		{"SELECT :id", []driver.NamedValue{named("id", 1), named("x", 2)}, "", nil, "named argument x not used by placeholders"},
+		{"SELECT :id", []driver.NamedValue{{Ordinal: 1, Value: 1}}, "", nil, "positional argument 1 for named placeholders, use sql.Named"},
+		{"SELECT ?", []driver.NamedValue{named("id", 1)}, "", nil, "named arguments but no named placeholders in query"},
+		{"SELECT ?, :id", []driver.NamedValue{named("id", 1)}, "", nil, "mixed positional and named placeholders in query"},
+		{"SELECT 'a", []driver.NamedValue{named("id", 1)}, "", nil, "unterminated quoted string at 7"},
+	} {
+		sql, values, err := bindNamed(c.query, c.args)
+		if c.err != "" {
+			assert.EqualError(t, err, c.err, c.query)
+			continue
+		}
+		require.NoError(t, err, c.query)
+		assert.Equal(t, c.sql, sql, c.query)
+		assert.Equal(t, c.values, values, c.query)
+	}
+}
+
+func TestNamedParameters(t *testing.T) {
+	db := openHookedDB(t)
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int, INDEX(KEY=c1))")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES (:c1, :c2)", sql.Named("c2", int32(1)), sql.Named("c1", "a"))
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES ('b', 2)")
+	require.NoError(t, err)
+
+	var n int32
+	err = db.QueryRowContext(ctx, "SELECT c2 FROM t1 WHERE c1 = @key AND c2 >= @min AND c2 <= @min + 1",
+		sql.Named("key", "a"), sql.Named("min", int32(1))).Scan(&n)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), n)
+
+	_, err = db.QueryContext(ctx, "SELECT c2 FROM t1 WHERE c1 = :key")
+	assert.EqualError(t, err, "missing argument for placeholder :key")
+}
@@ -0,0 +1,323 @@
+package openmldbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type faultKind int
+
+const (
+	faultLatency faultKind = iota
+	faultReset
+	faultTimeout
+	faultStatus
+	faultMalformedJSON
+	faultTruncatedBody
+	faultErrorCode
+)
+
+// Fault is a failure injected by ChaosTransport.
+type Fault struct {
+	kind     faultKind
+	duration time.Duration
+	status   int
+	code     int
+	msg      string
+}
+
+// Latency delays the request by d, then sends it as usual.
+func Latency(d time.Duration) Fault {
+	return Fault{kind: faultLatency, duration: d}
+}
+
+// ConnectionReset fails the request with connection reset by peer, without sending it.
+func ConnectionReset() Fault {
+	return Fault{kind: faultReset}
+}
+
+// Timeout hangs the request for d, or until the request cancelled, then fails it with
+// a net.Error reports Timeout. The request is not sent.
+func Timeout(d time.Duration) Fault {
+	return Fault{kind: faultTimeout, duration: d}
+}
+
+// HTTPStatus responds with HTTP status code, e.g. http.StatusServiceUnavailable, and a
+// plain text body. The request is not sent.
+func HTTPStatus(status int) Fault {
+	return Fault{kind: faultStatus, status: status}
+}
+
+// MalformedJSON responds with a body not valid JSON. The request is not sent.
+func MalformedJSON() Fault {
+	return Fault{kind: faultMalformedJSON}
+}
+
+// TruncatedBody sends the request, but cuts the response body in half, and reading past
+// it fails with io.ErrUnexpectedEOF.
+func TruncatedBody() Fault {
+	return Fault{kind: faultTruncatedBody}
+}
+
+// ErrorCode responds with a non-zero code and msg in the API response. The request is not sent.
+func ErrorCode(code int, msg string) Fault {
+	return Fault{kind: faultErrorCode, code: code, msg: msg}
+}
+
+// ChaosRule decides when a Fault is injected. Methods return the ChaosRule itself so calls
+// can be chained.
+type ChaosRule struct {
+	fault       Fault
+	probability float64
+	sql         *regexp.Regexp
+	deployment  string
+	match       func(*http.Request) bool
+	times       int
+	injected    int
+}
+
+// WithProbability injects the fault to matched requests with probability p in [0, 1],
+// default to 1.
+func (r *ChaosRule) WithProbability(p float64) *ChaosRule {
+	r.probability = p
+	return r
+}
+
+// WhenSQL injects the fault only to queries with SQL matches the regular expression
+// pattern, whitespaces in SQL normalized.
+func (r *ChaosRule) WhenSQL(pattern string) *ChaosRule {
+	r.sql = regexp.MustCompile(pattern)
+	return r
+}
+
+// WhenDeployment injects the fault only to calls of deployment name.
+func (r *ChaosRule) WhenDeployment(name string) *ChaosRule {
+	r.deployment = name
+	return r
+}
+
+// When injects the fault only to requests match reports true. Request body can be read
+// by match, it is restored before sent.
+func (r *ChaosRule) When(match func(*http.Request) bool) *ChaosRule {
+	r.match = match
+	return r
+}
+
+// Times injects the fault at most n times, unlimited by default.
+func (r *ChaosRule) Times(n int) *ChaosRule {
+	r.times = n
+	return r
+}
+
+// ChaosTransport is a http.RoundTripper injects faults into requests to api server, for
+// testing error and retry paths of code using the driver:
+//
+//	chaos := openmldbtest.NewChaosTransport(nil)
+//	chaos.Inject(openmldbtest.ConnectionReset()).WhenSQL(`^INSERT`).WithProbability(0.1)
+//	chaos.Inject(openmldbtest.Latency(100 * time.Millisecond))
+//
+//	cfg, err := openmldb.ParseDSN(dsn)
+//	cfg.HTTPClient = chaos.Client()
+//
+// Each request is checked against rules in the order injected, the first applicable one
+// injects its fault. Requests without fault are sent by the base transport.
+type ChaosTransport struct {
+	base http.RoundTripper
+
+	mu    sync.Mutex
+	rules []*ChaosRule
+	rand  *rand.Rand
+}
+
+// NewChaosTransport creates ChaosTransport sends requests by base, http.DefaultTransport if nil.
+func NewChaosTransport(base http.RoundTripper) *ChaosTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &ChaosTransport{base: base, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Seed seeds the random source of probabilities, for reproducible tests.
+func (c *ChaosTransport) Seed(seed int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rand = rand.New(rand.NewSource(seed))
+}
+
+// Inject adds a rule injecting fault, to all requests unless restricted by methods of the rule.
+func (c *ChaosTransport) Inject(fault Fault) *ChaosRule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := &ChaosRule{fault: fault, probability: 1}
+	c.rules = append(c.rules, r)
+	return r
+}
+
+// Injected returns the number of faults injected by rule.
+func (c *ChaosTransport) Injected(r *ChaosRule) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return r.injected
+}
+
+// Client returns a http.Client with the chaos transport.
+func (c *ChaosTransport) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault, ok, err := c.pick(req)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return c.base.RoundTrip(req)
+	}
+
+	switch fault.kind {
+	case faultLatency:
+		if !delay(req, fault.duration) {
+			return nil, req.Context().Err()
+		}
+		return c.base.RoundTrip(req)
+	case faultReset:
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	case faultTimeout:
+		if !delay(req, fault.duration) {
+			return nil, req.Context().Err()
+		}
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}
+	case faultStatus:
+		return fakeResponse(req, fault.status, "text/plain", fmt.Sprintf("%d %s", fault.status, http.StatusText(fault.status))), nil
+	case faultMalformedJSON:
+		return fakeResponse(req, http.StatusOK, "application/json", `{"code": 0, "msg": "ok", "data": {"schema": [`), nil
+	case faultErrorCode:
+		body, _ := json.Marshal(map[string]any{"code": fault.code, "msg": fault.msg})
+		return fakeResponse(req, http.StatusOK, "application/json", string(body)), nil
+	case faultTruncatedBody:
+		resp, err := c.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body[:len(body)/2]), errReader{io.ErrUnexpectedEOF}))
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	}
+	return c.base.RoundTrip(req)
+}
+
+// pick returns the fault to inject into request, ok is false if none
+func (c *ChaosTransport) pick(req *http.Request) (fault Fault, ok bool, err error) {
+	body, err := readBody(req)
+	if err != nil {
+		return Fault{}, false, err
+	}
+	var q struct {
+		SQL string `json:"sql"`
+	}
+	json.Unmarshal(body, &q)
+	sql := normalizeSQL(q.SQL)
+
+	// path is /dbs/{db}/deployments/{name} for deployment calls
+	var deployment string
+	if p := strings.Split(strings.Trim(req.URL.Path, "/"), "/"); len(p) == 4 && p[2] == "deployments" {
+		deployment = p[3]
+	}
+
+	// rules are copied and match called without lock, so match may use the transport
+	c.mu.Lock()
+	rules := append([]*ChaosRule(nil), c.rules...)
+	c.mu.Unlock()
+
+	for _, r := range rules {
+		if c.exhausted(r) {
+			continue
+		}
+		if r.sql != nil && (q.SQL == "" || !r.sql.MatchString(sql)) {
+			continue
+		}
+		if r.deployment != "" && r.deployment != deployment {
+			continue
+		}
+		if r.match != nil {
+			matched := r.match(req)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			if !matched {
+				continue
+			}
+		}
+		if c.take(r) {
+			return r.fault, true, nil
+		}
+	}
+	return Fault{}, false, nil
+}
+
+// exhausted reports whether rule has injected its fault the times limited
+func (c *ChaosTransport) exhausted(r *ChaosRule) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return r.times > 0 && r.injected >= r.times
+}
+
+// take counts an injection of rule applicable to request, unless exhausted meanwhile or
+// skipped by probability
+func (c *ChaosTransport) take(r *ChaosRule) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.times > 0 && r.injected >= r.times {
+		return false
+	}
+	if r.probability < 1 && c.rand.Float64() >= r.probability {
+		return false
+	}
+	r.injected++
+	return true
+}
+
+func fakeResponse(req *http.Request, status int, contentType string, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
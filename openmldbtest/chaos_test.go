@@ -0,0 +1,166 @@
+package openmldbtest_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+	"github.com/4paradigm/openmldb-go-sdk/openmldbtest"
+)
+
+func openChaosDB(t *testing.T) (*sql.DB, *openmldbtest.ChaosTransport) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+
+	chaos := openmldbtest.NewChaosTransport(nil)
+	chaos.Seed(1)
+	connector, err := openmldb.NewConnector(&openmldb.Config{Host: srv.Host(), DB: "test_db", HTTPClient: chaos.Client()})
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t1 VALUES ('aa', 1), ('bb', 2)")
+	require.NoError(t, err)
+	return db, chaos
+}
+
+func TestChaosFaults(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name  string
+		fault openmldbtest.Fault
+		check func(t *testing.T, err error)
+	}{
+		{"reset", openmldbtest.ConnectionReset(), func(t *testing.T, err error) {
+			assert.ErrorIs(t, err, syscall.ECONNRESET)
+		}},
+		{"timeout", openmldbtest.Timeout(10 * time.Millisecond), func(t *testing.T, err error) {
+			var netErr net.Error
+			require.ErrorAs(t, err, &netErr)
+			assert.True(t, netErr.Timeout())
+		}},
+		{"status", openmldbtest.HTTPStatus(http.StatusServiceUnavailable), func(t *testing.T, err error) {
+			assert.Error(t, err)
+		}},
+		{"malformed", openmldbtest.MalformedJSON(), func(t *testing.T, err error) {
+			assert.Error(t, err)
+		}},
+		{"truncated", openmldbtest.TruncatedBody(), func(t *testing.T, err error) {
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		}},
+		{"code", openmldbtest.ErrorCode(-1, "tablet down"), func(t *testing.T, err error) {
+			assert.EqualError(t, err, "execute error: tablet down")
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db, chaos := openChaosDB(t)
+			rule := chaos.Inject(tc.fault).WhenSQL(`^SELECT \* FROM t1`).Times(1)
+
+			rows, err := db.QueryContext(ctx, "SELECT * FROM   t1")
+			if err == nil {
+				for rows.Next() {
+				}
+				err = rows.Err()
+				rows.Close()
+			}
+			tc.check(t, err)
+			assert.Equal(t, 1, chaos.Injected(rule))
+
+			// no more faults after times exhausted
+			var n int64
+			require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM t1").Scan(&n))
+			assert.Equal(t, int64(2), n)
+			rows, err = db.QueryContext(ctx, "SELECT * FROM t1")
+			require.NoError(t, err)
+			rows.Close()
+		})
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	db, chaos := openChaosDB(t)
+	chaos.Inject(openmldbtest.Latency(50 * time.Millisecond)).WhenSQL("count")
+
+	start := time.Now()
+	var n int64
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM t1").Scan(&n))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := db.QueryRowContext(ctx, "SELECT count(*) FROM t1").Scan(&n)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestChaosProbability(t *testing.T) {
+	db, chaos := openChaosDB(t)
+	rule := chaos.Inject(openmldbtest.ErrorCode(-1, "injected")).WhenSQL("^SELECT c1").WithProbability(0.5)
+
+	failed := 0
+	for i := 0; i < 100; i++ {
+		rows, err := db.Query("SELECT c1 FROM t1")
+		if err != nil {
+			failed++
+			continue
+		}
+		rows.Close()
+	}
+	assert.Equal(t, failed, chaos.Injected(rule))
+	assert.InDelta(t, 50, failed, 20)
+}
+
+func TestChaosWriterRetry(t *testing.T) {
+	db, chaos := openChaosDB(t)
+	chaos.Inject(openmldbtest.ConnectionReset()).WhenSQL("^INSERT").Times(2)
+
+	w := openmldb.NewWriter(db, "t1", []string{"c1", "c2"}, openmldb.WithRetry(3, time.Millisecond, 10*time.Millisecond))
+	require.NoError(t, w.Write(context.Background(), []any{"cc", int32(3)}))
+	require.NoError(t, w.Close())
+	assert.Equal(t, int64(1), w.Stats().Written)
+
+	var n int64
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM t1").Scan(&n))
+	assert.Equal(t, int64(3), n)
+}
+
+func TestChaosWhenReentrant(t *testing.T) {
+	db, chaos := openChaosDB(t)
+	var rule *openmldbtest.ChaosRule
+	rule = chaos.Inject(openmldbtest.ErrorCode(-1, "injected")).When(func(req *http.Request) bool {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		// the transport is usable from match
+		return chaos.Injected(rule) == 0 && bytes.Contains(body, []byte("SELECT"))
+	})
+
+	var c1 string
+	assert.ErrorContains(t, db.QueryRow("SELECT c1 FROM t1").Scan(&c1), "injected")
+	assert.NoError(t, db.QueryRow("SELECT c1 FROM t1").Scan(&c1))
+	assert.Equal(t, 1, chaos.Injected(rule))
+}
+
+func TestChaosDeployment(t *testing.T) {
+	db, chaos := openChaosDB(t)
+	_, err := db.Exec("DEPLOY demo SELECT c1 FROM t1")
+	require.NoError(t, err)
+	chaos.Inject(openmldbtest.HTTPStatus(http.StatusBadGateway)).WhenDeployment("demo")
+
+	_, err = openmldb.CallDeployment(context.Background(), db, "demo", []any{"aa", int32(1)})
+	assert.Error(t, err)
+	var c1 string
+	assert.NoError(t, db.QueryRow("SELECT c1 FROM t1").Scan(&c1), "only deployment calls affected")
+}
@@ -0,0 +1,291 @@
+package openmldbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type expectKind int
+
+const (
+	kindQuery expectKind = iota
+	kindDeployment
+)
+
+// Argument matches a parameter value in request, for values can not be compared by equality.
+type Argument interface {
+	// Match reports whether the value matches. v is the parameter value decoded from JSON,
+	// i.e. nil, bool, float64 or string. timestamp is in unix epoch milliseconds, and date
+	// is a string formatted 'yyyy-mm-dd'.
+	Match(v any) bool
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(any) bool { return true }
+
+// AnyArg matches any parameter value.
+func AnyArg() Argument {
+	return anyArg{}
+}
+
+// Expectation is an expected request to the fake server, and the response to it.
+// Methods return the Expectation itself so calls can be chained.
+type Expectation struct {
+	kind       expectKind
+	sql        string
+	re         *regexp.Regexp
+	deployment string
+	mode       string
+	args       []any
+	hasArgs    bool
+
+	schema  []string
+	columns []string
+	rows    [][]any
+	code    int
+	msg     string
+	status  int
+	closing bool
+	latency time.Duration
+
+	times    int
+	anyTimes bool
+	calls    int
+}
+
+// WithArgs expects the request with exactly the parameters, or input rows
+// for deployment request flattened.
+//
+// An argument is either an Argument, or a go value compared with the parameter
+// by its JSON representation in request.
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args = args
+	e.hasArgs = true
+	return e
+}
+
+// WithMode expects the query request in mode, e.g. "online" or "offsync".
+func (e *Expectation) WithMode(mode string) *Expectation {
+	e.mode = mode
+	return e
+}
+
+// Times expects the request n times, default to once.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// AnyTimes expects the request any times, including none.
+func (e *Expectation) AnyTimes() *Expectation {
+	e.times = 0
+	e.anyTimes = true
+	return e
+}
+
+// WillReturnRows responds with result rows, the schema is SQL type of each column,
+// e.g. "int32", "string", "timestamp" or "date". time.Time values are sent as the
+// column type requires.
+func (e *Expectation) WillReturnRows(schema []string, rows ...[]any) *Expectation {
+	e.schema = schema
+	e.rows = rows
+	return e
+}
+
+// WillReturnColumns sets the column names in the response of deployment request,
+// default to c1, c2 ...
+func (e *Expectation) WillReturnColumns(names ...string) *Expectation {
+	e.columns = names
+	return e
+}
+
+// WillReturnError responds with a non-zero code and message, as api server does
+// when SQL execution failed.
+func (e *Expectation) WillReturnError(code int, msg string) *Expectation {
+	e.code = code
+	e.msg = msg
+	return e
+}
+
+// WillReturnStatus responds with HTTP status and no JSON body.
+func (e *Expectation) WillReturnStatus(status int) *Expectation {
+	e.status = status
+	return e
+}
+
+// WillCloseConnection closes the connection without response.
+func (e *Expectation) WillCloseConnection() *Expectation {
+	e.closing = true
+	return e
+}
+
+// WillDelay delays the response by d, or until the request cancelled.
+func (e *Expectation) WillDelay(d time.Duration) *Expectation {
+	e.latency = d
+	return e
+}
+
+func (e *Expectation) String() string {
+	var target string
+	switch {
+	case e.kind == kindDeployment:
+		target = "deployment " + e.deployment
+	case e.re != nil:
+		target = fmt.Sprintf("query matching '%s'", e.re)
+	default:
+		target = fmt.Sprintf("query '%s'", e.sql)
+	}
+	if e.hasArgs {
+		target += fmt.Sprintf(" with args %v", e.args)
+	}
+	return target
+}
+
+func (e *Expectation) matches(req *request) bool {
+	if e.kind != req.kind {
+		return false
+	}
+
+	switch e.kind {
+	case kindDeployment:
+		if e.deployment != req.deployment {
+			return false
+		}
+	default:
+		if e.re != nil && !e.re.MatchString(req.sql) {
+			return false
+		}
+		if e.re == nil && e.sql != req.sql {
+			return false
+		}
+		if e.mode != "" && e.mode != req.mode {
+			return false
+		}
+	}
+
+	if !e.hasArgs {
+		return true
+	}
+
+	actual := req.args
+	if e.kind == kindDeployment {
+		actual = nil
+		for _, row := range req.input {
+			actual = append(actual, row...)
+		}
+	}
+	if len(e.args) != len(actual) {
+		return false
+	}
+	for i, arg := range e.args {
+		var v any
+		if err := json.Unmarshal(actual[i], &v); err != nil {
+			return false
+		}
+		if !matchArg(arg, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchArg(arg any, v any) bool {
+	if m, ok := arg.(Argument); ok {
+		return m.Match(v)
+	}
+
+	raw, err := json.Marshal(encodeValue("", arg))
+	if err != nil {
+		return false
+	}
+	var expect any
+	if err := json.Unmarshal(raw, &expect); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(expect, v)
+}
+
+// encodeValue converts v into the JSON representation of api server
+func encodeValue(typ string, v any) any {
+	switch vv := v.(type) {
+	case time.Time:
+		if strings.EqualFold(typ, "date") {
+			return vv.Format(time.DateOnly)
+		}
+		return vv.UnixMilli()
+	default:
+		return v
+	}
+}
+
+func (e *Expectation) respond(w http.ResponseWriter, r *http.Request) {
+	if e.latency > 0 && !delay(r, e.latency) {
+		return
+	}
+
+	if e.closing {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	if e.status != 0 {
+		http.Error(w, http.StatusText(e.status), e.status)
+		return
+	}
+
+	if e.code != 0 {
+		writeJSON(w, map[string]any{"code": e.code, "msg": e.msg})
+		return
+	}
+
+	if e.schema == nil {
+		writeJSON(w, map[string]any{"code": 0, "msg": "ok"})
+		return
+	}
+
+	rows := make([][]any, len(e.rows))
+	for i, row := range e.rows {
+		rows[i] = make([]any, len(row))
+		for j, v := range row {
+			typ := ""
+			if j < len(e.schema) {
+				typ = e.schema[j]
+			}
+			rows[i][j] = encodeValue(typ, v)
+		}
+	}
+
+	if e.kind == kindDeployment {
+		schema := make([]map[string]string, len(e.schema))
+		for i, typ := range e.schema {
+			name := fmt.Sprintf("c%d", i+1)
+			if i < len(e.columns) {
+				name = e.columns[i]
+			}
+			schema[i] = map[string]string{"name": name, "type": typ}
+		}
+		writeJSON(w, map[string]any{
+			"code": 0,
+			"msg":  "ok",
+			"data": map[string]any{"data": rows, "schema": schema, "common_cols_data": []any{}},
+		})
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"code": 0,
+		"msg":  "ok",
+		"data": map[string]any{"schema": e.schema, "data": rows},
+	})
+}
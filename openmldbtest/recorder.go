@@ -0,0 +1,425 @@
+package openmldbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// RecorderMode is the mode of Recorder.
+type RecorderMode int
+
+const (
+	// ModeReplay answers requests by interactions in cassette, without real api server.
+	ModeReplay RecorderMode = iota
+	// ModeRecord sends requests to api server, and records interactions into cassette.
+	ModeRecord
+	// ModeReplayOrRecord replays if cassette file exists, otherwise records.
+	ModeReplayOrRecord
+)
+
+// ErrUnrecorded is returned by Recorder in ModeReplay for request not found in cassette.
+var ErrUnrecorded = errors.New("openmldbtest: request not recorded in cassette")
+
+// Cassette is the recorded interactions with api server, saved as JSON file.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a request to api server and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded request, host of api server is not recorded.
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"` // path and query of URL
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded response, body kept as is.
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Matcher reports whether request matches recorded request in replay.
+type Matcher func(req *RecordedRequest, recorded *RecordedRequest) bool
+
+// Redactor modifies interaction to hide sensitive data before it saved in record mode.
+// In replay mode requests are redacted the same way before matching, so that redacted
+// requests still match.
+type Redactor func(*Interaction)
+
+// RecorderOption configures Recorder.
+type RecorderOption func(*Recorder)
+
+// WithTransport sets the transport requests sent by in record mode, http.DefaultTransport by default.
+func WithTransport(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.transport = rt
+	}
+}
+
+// WithMatcher sets how requests match recorded requests in replay mode, DefaultMatcher by default.
+func WithMatcher(m Matcher) RecorderOption {
+	return func(r *Recorder) {
+		r.matcher = m
+	}
+}
+
+// WithRedactor adds redactors, applied after the default one which redacts credential headers.
+func WithRedactor(redactors ...Redactor) RecorderOption {
+	return func(r *Recorder) {
+		r.redactors = append(r.redactors, redactors...)
+	}
+}
+
+// Recorder is a http.RoundTripper records interactions with api server into cassette file, and
+// replays them later, so tests run deterministically without cluster:
+//
+//	rec, err := openmldbtest.NewRecorder("testdata/demo.json", openmldbtest.ModeReplayOrRecord)
+//	// handle error
+//	defer rec.Stop()
+//
+//	cfg, err := openmldb.ParseDSN("openmldb://127.0.0.1:9080/test_db")
+//	cfg.HTTPClient = rec.Client()
+//	connector, err := openmldb.NewConnector(cfg)
+//	db := sql.OpenDB(connector)
+//
+// In replay mode, a request is answered by the first matched interaction not replayed yet, or
+// the last matched one if all are replayed, e.g. ping of each new connection. Request matches
+// no interaction fails with ErrUnrecorded, which is also reported by Stop.
+type Recorder struct {
+	path      string
+	mode      RecorderMode
+	transport http.RoundTripper
+	matcher   Matcher
+	redactors []Redactor
+
+	mu       sync.Mutex
+	cassette Cassette
+	replayed []bool
+	errs     []error
+}
+
+// NewRecorder creates Recorder with cassette file at path. In ModeReplay the cassette file must exist.
+func NewRecorder(path string, mode RecorderMode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		matcher:   DefaultMatcher,
+		redactors: []Redactor{RedactHeaders("Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie")},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil && r.mode != ModeRecord:
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("openmldbtest: invalid cassette %s: %w", path, err)
+		}
+		r.mode = ModeReplay
+		r.replayed = make([]bool, len(r.cassette.Interactions))
+	case errors.Is(err, os.ErrNotExist) && r.mode == ModeReplayOrRecord:
+		r.mode = ModeRecord
+	case err != nil && r.mode != ModeRecord:
+		return nil, fmt.Errorf("openmldbtest: read cassette: %w", err)
+	}
+	return r, nil
+}
+
+// Mode returns the mode in effect, ModeReplayOrRecord resolved to ModeReplay or ModeRecord.
+func (r *Recorder) Mode() RecorderMode {
+	return r.mode
+}
+
+// Client returns a http.Client with the recorder as transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Stop saves the cassette in record mode. In replay mode it returns the errors of
+// requests not recorded.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mode == ModeReplay {
+		return errors.Join(r.errs...)
+	}
+
+	data, err := json.MarshalIndent(&r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	in := &Interaction{Request: RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Header: req.Header.Clone(),
+		Body:   rawJSON(reqBody),
+	}}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, in)
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in.Response = RecordedResponse{Status: resp.StatusCode, Header: resp.Header.Clone(), Body: string(respBody)}
+	r.redact(in)
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, in *Interaction) (*http.Response, error) {
+	r.redact(in)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := -1
+	for i, recorded := range r.cassette.Interactions {
+		if !r.matcher(&in.Request, &recorded.Request) {
+			continue
+		}
+		found = i
+		if !r.replayed[i] {
+			break
+		}
+	}
+	if found < 0 {
+		err := fmt.Errorf("%w: %s %s %s", ErrUnrecorded, in.Request.Method, in.Request.Path, in.Request.Body)
+		r.errs = append(r.errs, err)
+		return nil, err
+	}
+	r.replayed[found] = true
+
+	recorded := r.cassette.Interactions[found].Response
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (r *Recorder) redact(in *Interaction) {
+	for _, redact := range r.redactors {
+		redact(in)
+	}
+}
+
+// readBody reads request body and restores it for sending
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// rawJSON keeps body as JSON in cassette for readability, or as JSON string if it is not JSON
+func rawJSON(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, body); err == nil {
+			return buf.Bytes()
+		}
+	}
+	s, _ := json.Marshal(string(body))
+	return s
+}
+
+// DefaultMatcher matches method, path and body of request, with whitespaces in SQL normalized.
+func DefaultMatcher(req *RecordedRequest, recorded *RecordedRequest) bool {
+	return req.Method == recorded.Method && req.Path == recorded.Path &&
+		reflect.DeepEqual(canonicalBody(req.Body, false), canonicalBody(recorded.Body, false))
+}
+
+// MatchIgnoringArgs is like DefaultMatcher, but ignores parameters of queries and request rows
+// of deployments.
+func MatchIgnoringArgs(req *RecordedRequest, recorded *RecordedRequest) bool {
+	return req.Method == recorded.Method && req.Path == recorded.Path &&
+		reflect.DeepEqual(canonicalBody(req.Body, true), canonicalBody(recorded.Body, true))
+}
+
+func canonicalBody(body json.RawMessage, ignoreArgs bool) any {
+	if len(body) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return string(body)
+	}
+	if m, ok := v.(map[string]any); ok {
+		if sql, ok := m["sql"].(string); ok {
+			m["sql"] = normalizeSQL(sql)
+		}
+		if ignoreArgs {
+			delete(m, "input")
+		}
+	}
+	return v
+}
+
+// RedactHeaders replaces values of request and response headers by name with "REDACTED".
+func RedactHeaders(names ...string) Redactor {
+	return func(in *Interaction) {
+		for _, name := range names {
+			for _, h := range []http.Header{in.Request.Header, in.Response.Header} {
+				if h.Get(name) != "" {
+					h.Set(name, "REDACTED")
+				}
+			}
+		}
+	}
+}
+
+// RedactArgs replaces parameters of queries, values of deployment request rows and of put rows
+// with "REDACTED", so redacted requests can only be told apart by SQL and the number of values.
+// Values of response rows are replaced by placeholders of the same JSON type, so replayed rows
+// still scan: strings by "REDACTED", dates by "1970-01-01", numbers by 0 and booleans by false.
+func RedactArgs() Redactor {
+	return func(in *Interaction) {
+		if body, ok := redactJSON(in.Request.Body, redactRequest); ok {
+			in.Request.Body = body
+		}
+		if body, ok := redactJSON([]byte(in.Response.Body), redactResponse); ok {
+			in.Response.Body = string(body)
+		}
+	}
+}
+
+// redactJSON decodes a JSON object, redacts it and encodes it again, reporting false if
+// body is not a JSON object or nothing redacted
+func redactJSON(body []byte, redact func(map[string]any) bool) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil || m == nil || !redact(m) {
+		return nil, false
+	}
+	data, err := json.Marshal(m)
+	return data, err == nil
+}
+
+func redactRequest(body map[string]any) bool {
+	switch input := body["input"].(type) {
+	case map[string]any: // query parameters
+		redactValues(input["data"])
+		return true
+	case []any: // deployment request rows
+		for _, row := range input {
+			redactValues(row)
+		}
+		return true
+	}
+	if rows, ok := body["value"].([]any); ok { // put rows
+		for _, row := range rows {
+			redactValues(row)
+		}
+		return true
+	}
+	return false
+}
+
+func redactResponse(body map[string]any) bool {
+	data, ok := body["data"].(map[string]any)
+	if !ok {
+		return false
+	}
+	rows, ok := data["data"].([]any)
+	if !ok {
+		return false
+	}
+	for _, row := range rows {
+		placeholdValues(row)
+	}
+	placeholdValues(data["common_cols_data"])
+	return true
+}
+
+func redactValues(v any) {
+	if values, ok := v.([]any); ok {
+		for i := range values {
+			values[i] = "REDACTED"
+		}
+	}
+}
+
+// placeholdValues replaces values by placeholders of the same JSON type, nulls kept
+func placeholdValues(v any) {
+	values, ok := v.([]any)
+	if !ok {
+		return
+	}
+	for i, value := range values {
+		switch value := value.(type) {
+		case string:
+			if _, err := time.Parse(time.DateOnly, value); err == nil {
+				values[i] = "1970-01-01"
+			} else {
+				values[i] = "REDACTED"
+			}
+		case json.Number:
+			values[i] = 0
+		case bool:
+			values[i] = false
+		}
+	}
+}
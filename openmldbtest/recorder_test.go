@@ -0,0 +1,144 @@
+package openmldbtest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+	"github.com/4paradigm/openmldb-go-sdk/openmldbtest"
+)
+
+func openRecordedDB(t *testing.T, host string, rec *openmldbtest.Recorder) *sql.DB {
+	connector, err := openmldb.NewConnector(&openmldb.Config{Host: host, DB: "test_db", HTTPClient: rec.Client()})
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	run := func(db *sql.DB) string {
+		_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 string)")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES ('aa', ?)", "secret")
+		require.NoError(t, err)
+		require.NoError(t, openmldb.PutRow(ctx, db, "t1", map[string]any{"c1": "bb", "c2": "hidden"}))
+
+		var c1 string
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT c2 FROM t1 WHERE c1 = ?", "aa").Scan(&c1))
+		return c1
+	}
+
+	// record against emulator
+	srv := emulator.NewServer()
+	rec, err := openmldbtest.NewRecorder(path, openmldbtest.ModeReplayOrRecord, openmldbtest.WithRedactor(openmldbtest.RedactArgs()))
+	require.NoError(t, err)
+	assert.Equal(t, openmldbtest.ModeRecord, rec.Mode())
+	assert.Equal(t, "secret", run(openRecordedDB(t, srv.Host(), rec)))
+	require.NoError(t, rec.Stop())
+	srv.Close()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.NotContains(t, string(data), "hidden")
+	assert.Contains(t, string(data), "INSERT INTO t1 VALUES ('aa', ?)")
+
+	// replay without server
+	rec, err = openmldbtest.NewRecorder(path, openmldbtest.ModeReplayOrRecord, openmldbtest.WithRedactor(openmldbtest.RedactArgs()))
+	require.NoError(t, err)
+	assert.Equal(t, openmldbtest.ModeReplay, rec.Mode())
+	db := openRecordedDB(t, "127.0.0.1:1", rec)
+	assert.Equal(t, "REDACTED", run(db))
+	require.NoError(t, rec.Stop())
+
+	_, err = db.ExecContext(ctx, "DROP TABLE t1")
+	assert.True(t, errors.Is(err, openmldbtest.ErrUnrecorded), err)
+	assert.ErrorIs(t, rec.Stop(), openmldbtest.ErrUnrecorded)
+}
+
+func TestRecorderReplayMissingCassette(t *testing.T) {
+	_, err := openmldbtest.NewRecorder(filepath.Join(t.TempDir(), "missing.json"), openmldbtest.ModeReplay)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMatcher(t *testing.T) {
+	recorded := &openmldbtest.RecordedRequest{
+		Method: "POST",
+		Path:   "/dbs/test_db",
+		Body:   []byte(`{"mode":"online","sql":"SELECT c1\n FROM t1 WHERE c1 = ?","input":{"schema":["string"],"data":["aa"]}}`),
+	}
+	req := &openmldbtest.RecordedRequest{
+		Method: "POST",
+		Path:   "/dbs/test_db",
+		Body:   []byte(`{"sql":"SELECT c1 FROM t1 WHERE c1 = ?","mode":"online","input":{"schema":["string"],"data":["aa"]}}`),
+	}
+	assert.True(t, openmldbtest.DefaultMatcher(req, recorded))
+
+	req.Body = []byte(`{"sql":"SELECT c1 FROM t1 WHERE c1 = ?","mode":"online","input":{"schema":["string"],"data":["bb"]}}`)
+	assert.False(t, openmldbtest.DefaultMatcher(req, recorded))
+	assert.True(t, openmldbtest.MatchIgnoringArgs(req, recorded))
+
+	req.Path = "/dbs/other_db"
+	assert.False(t, openmldbtest.MatchIgnoringArgs(req, recorded))
+}
+
+func TestRedactHeaders(t *testing.T) {
+	in := &openmldbtest.Interaction{
+		Request:  openmldbtest.RecordedRequest{Header: http.Header{"Authorization": {"Basic xxx"}, "Accept": {"*/*"}}},
+		Response: openmldbtest.RecordedResponse{Header: http.Header{"Set-Cookie": {"id=1"}}},
+	}
+	openmldbtest.RedactHeaders("Authorization", "Set-Cookie")(in)
+	assert.Equal(t, http.Header{"Authorization": {"REDACTED"}, "Accept": {"*/*"}}, in.Request.Header)
+	assert.Equal(t, http.Header{"Set-Cookie": {"REDACTED"}}, in.Response.Header)
+}
+
+func TestRedactArgs(t *testing.T) {
+	put := &openmldbtest.Interaction{
+		Request: openmldbtest.RecordedRequest{
+			Method: "PUT",
+			Path:   "/dbs/test_db/tables/t1",
+			Body:   []byte(`{"value":[["aa",1,null]]}`),
+		},
+		Response: openmldbtest.RecordedResponse{Status: 200, Body: `{"code":0,"msg":"ok"}`},
+	}
+	openmldbtest.RedactArgs()(put)
+	assert.JSONEq(t, `{"value":[["REDACTED","REDACTED","REDACTED"]]}`, string(put.Request.Body))
+	assert.JSONEq(t, `{"code":0,"msg":"ok"}`, put.Response.Body)
+
+	query := &openmldbtest.Interaction{
+		Request: openmldbtest.RecordedRequest{
+			Method: "POST",
+			Path:   "/dbs/test_db",
+			Body:   []byte(`{"mode":"online","sql":"SELECT * FROM t1 WHERE c1 = ?","input":{"schema":["string"],"data":["aa"]}}`),
+		},
+		Response: openmldbtest.RecordedResponse{
+			Status: 200,
+			Body:   `{"code":0,"msg":"ok","data":{"schema":["string","int","bool","date","timestamp","string"],"data":[["aa",1,true,"2024-01-02",1700000000000,null]]}}`,
+		},
+	}
+	openmldbtest.RedactArgs()(query)
+	assert.JSONEq(t, `{"mode":"online","sql":"SELECT * FROM t1 WHERE c1 = ?","input":{"schema":["string"],"data":["REDACTED"]}}`, string(query.Request.Body))
+	assert.JSONEq(t, `{"code":0,"msg":"ok","data":{"schema":["string","int","bool","date","timestamp","string"],"data":[["REDACTED",0,false,"1970-01-01",0,null]]}}`, query.Response.Body)
+
+	deployment := &openmldbtest.Interaction{
+		Response: openmldbtest.RecordedResponse{
+			Status: 200,
+			Body:   `{"code":0,"msg":"ok","data":{"data":[["aa",2]],"common_cols_data":["bb"]}}`,
+		},
+	}
+	openmldbtest.RedactArgs()(deployment)
+	assert.JSONEq(t, `{"code":0,"msg":"ok","data":{"data":[["REDACTED",0]],"common_cols_data":["REDACTED"]}}`, deployment.Response.Body)
+}
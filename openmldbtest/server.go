@@ -0,0 +1,243 @@
+// Package openmldbtest provides a fake OpenMLDB api server for unit tests.
+//
+// The fake server speaks the HTTP API of api server the driver talks to, answers
+// requests by the expectations registered in test, so tests using the driver run
+// without an OpenMLDB cluster:
+//
+//	srv := openmldbtest.NewServer()
+//	defer srv.Close()
+//
+//	srv.ExpectQuery("SELECT c1, c2 FROM demo WHERE c1 = ?").
+//		WithArgs(int32(1)).
+//		WillReturnRows([]string{"int32", "string"}, []any{int32(1), "bb"})
+//
+//	db, _ := sql.Open("openmldb", srv.DSN("test_db"))
+//	// run code under test with db
+//
+//	if err := srv.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+package openmldbtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake api server. Create it by NewServer.
+type Server struct {
+	srv *httptest.Server
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	ordered      bool
+	errs         []error
+}
+
+// NewServer starts a fake api server, it should be closed by Close when finished.
+//
+// Expectations are matched in the order registered by default, see MatchExpectationsInOrder.
+func NewServer() *Server {
+	s := &Server{ordered: true}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Host returns the host:port the server listens on.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.srv.URL, "http://")
+}
+
+// DSN returns the data source name to open database db on the server, with optional DSN parameters
+// like "mode=offsync".
+func (s *Server) DSN(db string, params ...string) string {
+	dsn := fmt.Sprintf("openmldb://%s/%s", s.Host(), db)
+	if len(params) > 0 {
+		dsn += "?" + strings.Join(params, "&")
+	}
+	return dsn
+}
+
+// MatchExpectationsInOrder sets whether requests must match expectations in the order registered.
+// If false, a request matches any expectation not yet exhausted.
+func (s *Server) MatchExpectationsInOrder(ordered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ordered = ordered
+}
+
+// ExpectQuery expects a SQL request, matching the SQL exactly, with whitespace collapsed.
+func (s *Server) ExpectQuery(sql string) *Expectation {
+	return s.expect(&Expectation{kind: kindQuery, sql: normalizeSQL(sql)})
+}
+
+// ExpectQueryRegexp expects a SQL request, matching the SQL by regular expression.
+func (s *Server) ExpectQueryRegexp(pattern string) *Expectation {
+	return s.expect(&Expectation{kind: kindQuery, re: regexp.MustCompile(pattern)})
+}
+
+// ExpectDeployment expects a request to call deployment by name.
+func (s *Server) ExpectDeployment(name string) *Expectation {
+	return s.expect(&Expectation{kind: kindDeployment, deployment: name})
+}
+
+func (s *Server) expect(e *Expectation) *Expectation {
+	e.times = 1
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expectations = append(s.expectations, e)
+	return e
+}
+
+// ExpectationsWereMet reports error if any expectation is not satisfied,
+// or any request did not match an expectation.
+func (s *Server) ExpectationsWereMet() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := append([]error(nil), s.errs...)
+	for _, e := range s.expectations {
+		if e.calls < e.times {
+			errs = append(errs, fmt.Errorf("expectation not met: %s, called %d of %d times", e, e.calls, e.times))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type queryReq struct {
+	Mode  string `json:"mode"`
+	SQL   string `json:"sql"`
+	Input *struct {
+		Schema []string          `json:"schema"`
+		Data   []json.RawMessage `json:"data"`
+	} `json:"input,omitempty"`
+}
+
+type deploymentReq struct {
+	Input      [][]json.RawMessage `json:"input"`
+	NeedSchema bool                `json:"need_schema"`
+}
+
+// request is the fields of a request that expectations match against
+type request struct {
+	kind       expectKind
+	mode       string
+	sql        string
+	deployment string
+	args       []json.RawMessage
+	input      [][]json.RawMessage
+}
+
+func (r *request) String() string {
+	if r.kind == kindDeployment {
+		return fmt.Sprintf("deployment %s with %d rows", r.deployment, len(r.input))
+	}
+	return fmt.Sprintf("query '%s' in mode %s with %d args", r.sql, r.mode, len(r.args))
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	req, err := parseRequest(r)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	e := s.match(req)
+	if e == nil {
+		if req.kind == kindQuery && strings.EqualFold(req.sql, "SELECT 1") && req.args == nil {
+			// ping from driver, answered if not expected explicitly
+			writeJSON(w, map[string]any{"code": 0, "msg": "ok"})
+			return
+		}
+		s.fail(w, fmt.Errorf("unexpected request: %s", req))
+		return
+	}
+
+	e.respond(w, r)
+}
+
+func parseRequest(r *http.Request) (*request, error) {
+	// path is /dbs/{db} or /dbs/{db}/deployments/{name}
+	p := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "POST" && len(p) == 2 && p[0] == "dbs":
+		var q queryReq
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			return nil, fmt.Errorf("invalid query request: %w", err)
+		}
+		req := &request{kind: kindQuery, mode: q.Mode, sql: normalizeSQL(q.SQL)}
+		if q.Input != nil {
+			req.args = q.Input.Data
+		}
+		return req, nil
+	case r.Method == "POST" && len(p) == 4 && p[0] == "dbs" && p[2] == "deployments":
+		var d deploymentReq
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			return nil, fmt.Errorf("invalid deployment request: %w", err)
+		}
+		return &request{kind: kindDeployment, deployment: p[3], input: d.Input}, nil
+	default:
+		return nil, fmt.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+	}
+}
+
+func (s *Server) match(req *request) *Expectation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.expectations {
+		exhausted := !e.anyTimes && e.calls >= e.times
+		if !exhausted && e.matches(req) {
+			e.calls++
+			return e
+		}
+		if s.ordered && e.calls < e.times {
+			// an earlier expectation is not satisfied yet
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	s.mu.Lock()
+	s.errs = append(s.errs, err)
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{"code": -1, "msg": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+func normalizeSQL(sql string) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(sql, " "))
+}
+
+// delay sleeps for d, or until the request cancelled
+func delay(r *http.Request, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,142 @@
+package openmldbtest_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/openmldbtest"
+)
+
+func openDB(t *testing.T, srv *openmldbtest.Server, params ...string) *sql.DB {
+	db, err := sql.Open("openmldb", srv.DSN("test_db", params...))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestServerQuery(t *testing.T) {
+	srv := openmldbtest.NewServer()
+	defer srv.Close()
+
+	srv.ExpectQuery("CREATE TABLE demo (c1 int, c2 string, ts timestamp, dt date)")
+	srv.ExpectQuery(`SELECT * FROM   demo
+		WHERE c1 = ? AND ts > ?`).
+		WithArgs(int32(1), openmldbtest.AnyArg()).
+		WillReturnRows([]string{"int32", "string", "timestamp", "date"},
+			[]any{int32(1), "bb", time.UnixMilli(3000), time.Date(2022, time.December, 12, 0, 0, 0, 0, time.UTC)})
+
+	db := openDB(t, srv)
+	ctx := context.Background()
+
+	_, err := db.ExecContext(ctx, "CREATE TABLE demo (c1 int, c2 string, ts timestamp, dt date)")
+	assert.NoError(t, err)
+
+	type demo struct {
+		C1 int32
+		C2 string
+		TS time.Time
+		DT time.Time
+	}
+	result, err := openmldb.QueryStructs[demo](ctx, db, "SELECT * FROM demo WHERE c1 = ? AND ts > ?", int32(1), time.UnixMilli(0))
+	assert.NoError(t, err)
+	assert.Equal(t, []demo{{1, "bb", time.UnixMilli(3000), time.Date(2022, time.December, 12, 0, 0, 0, 0, time.UTC)}}, result)
+
+	assert.NoError(t, srv.ExpectationsWereMet())
+}
+
+func TestServerQueryRegexpAndMode(t *testing.T) {
+	srv := openmldbtest.NewServer()
+	defer srv.Close()
+
+	srv.ExpectQueryRegexp(`^SELECT .* FROM demo`).WithMode("offsync").Times(2).
+		WillReturnRows([]string{"int64"}, []any{int64(10)})
+
+	db := openDB(t, srv, "mode=offsync")
+	for i := 0; i < 2; i++ {
+		var cnt int64
+		assert.NoError(t, db.QueryRowContext(context.Background(), "SELECT count(*) FROM demo").Scan(&cnt))
+		assert.Equal(t, int64(10), cnt)
+	}
+
+	assert.NoError(t, srv.ExpectationsWereMet())
+}
+
+func TestServerFailures(t *testing.T) {
+	srv := openmldbtest.NewServer()
+	defer srv.Close()
+
+	srv.ExpectQuery("SELECT c1 FROM t1").WillReturnError(-1, "table not found")
+	srv.ExpectQuery("SELECT c1 FROM t2").WillCloseConnection()
+	srv.ExpectQuery("SELECT c1 FROM t3").WillDelay(time.Second)
+
+	db := openDB(t, srv)
+	ctx := context.Background()
+
+	_, err := db.QueryContext(ctx, "SELECT c1 FROM t1")
+	assert.EqualError(t, err, "execute error: table not found")
+
+	_, err = db.QueryContext(ctx, "SELECT c1 FROM t2")
+	assert.Error(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = db.QueryContext(timeoutCtx, "SELECT c1 FROM t3")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.NoError(t, srv.ExpectationsWereMet())
+}
+
+func TestServerUnmetExpectations(t *testing.T) {
+	srv := openmldbtest.NewServer()
+	defer srv.Close()
+
+	srv.ExpectQuery("SELECT c1 FROM t1")
+	srv.ExpectQuery("SELECT c1 FROM t2")
+
+	db := openDB(t, srv)
+
+	// out of order
+	_, err := db.ExecContext(context.Background(), "SELECT c1 FROM t2")
+	assert.Error(t, err)
+
+	err = srv.ExpectationsWereMet()
+	assert.ErrorContains(t, err, "unexpected request: query 'SELECT c1 FROM t2'")
+	assert.ErrorContains(t, err, "expectation not met: query 'SELECT c1 FROM t1'")
+}
+
+func TestServerDeployment(t *testing.T) {
+	srv := openmldbtest.NewServer()
+	defer srv.Close()
+
+	srv.ExpectDeployment("demo_deploy").
+		WithArgs("aa", int64(1000)).
+		WillReturnColumns("c1", "w_sum").
+		WillReturnRows([]string{"string", "double"}, []any{"aa", 3.5})
+
+	body, _ := json.Marshal(map[string]any{"input": [][]any{{"aa", 1000}}, "need_schema": true})
+	resp, err := http.Post("http://"+srv.Host()+"/dbs/test_db/deployments/demo_deploy", "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	var r struct {
+		Code int `json:"code"`
+		Data struct {
+			Data   [][]any             `json:"data"`
+			Schema []map[string]string `json:"schema"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+	assert.Equal(t, 0, r.Code)
+	assert.Equal(t, [][]any{{"aa", 3.5}}, r.Data.Data)
+	assert.Equal(t, []map[string]string{{"name": "c1", "type": "string"}, {"name": "w_sum", "type": "double"}}, r.Data.Schema)
+
+	assert.NoError(t, srv.ExpectationsWereMet())
+}
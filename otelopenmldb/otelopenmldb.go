@@ -0,0 +1,437 @@
+// Package otelopenmldb instruments the OpenMLDB driver with OpenTelemetry tracing and metrics.
+//
+// It wraps the HTTP transport of the driver, creates a client span for each request to api
+// server, propagates the trace context to api server in W3C trace context headers, and records
+// request latency and errors:
+//
+//	cfg, err := openmldb.ParseDSN("openmldb://127.0.0.1:8080/test_db")
+//	otelopenmldb.Instrument(cfg)
+//	connector, err := openmldb.NewConnector(cfg)
+//	db := sql.OpenDB(connector)
+//
+// Spans are children of the span in context passed to the driver, e.g. by db.QueryContext.
+// SQL in span attributes is sanitized, with literals replaced by '?'. Row counts and errors
+// of statements are from the driver hook Instrument registers, as responses are streamed to
+// the caller, so spans of a statement end once it's done, e.g. its rows closed.
+package otelopenmldb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/4paradigm/openmldb-go-sdk/otelopenmldb"
+
+// Attribute keys of spans and metrics, besides the ones of OpenTelemetry semantic conventions.
+const (
+	ModeKey       = attribute.Key("openmldb.mode")
+	DeploymentKey = attribute.Key("openmldb.deployment")
+	// ResponseCodeKey is the error code of api server, of requests failed with *openmldb.APIError.
+	ResponseCodeKey = attribute.Key("openmldb.response.code")
+)
+
+var (
+	dbSystemKey      = attribute.Key("db.system")
+	dbNamespaceKey   = attribute.Key("db.namespace")
+	dbOperationKey   = attribute.Key("db.operation.name")
+	dbQueryTextKey   = attribute.Key("db.query.text")
+	dbRowsKey        = attribute.Key("db.response.returned_rows")
+	serverAddressKey = attribute.Key("server.address")
+	errorTypeKey     = attribute.Key("error.type")
+	httpStatusKey    = attribute.Key("http.response.status_code")
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagators    propagation.TextMapPropagator
+}
+
+// Option configures the instrumentation.
+type Option func(*config)
+
+// WithTracerProvider sets the tracer provider, the global one by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider, the global one by default.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagators sets the propagators injecting trace context into requests, the global
+// ones by default.
+func WithPropagators(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = p
+	}
+}
+
+// Instrument sets HTTP client of cfg to the one with instrumented transport, based on the
+// transport of cfg.HTTPClient if set, and adds Hook to cfg.Hooks.
+func Instrument(cfg *openmldb.Config, opts ...Option) error {
+	client := http.Client{}
+	if cfg.HTTPClient != nil {
+		client = *cfg.HTTPClient
+	}
+	t, err := NewTransport(client.Transport, opts...)
+	if err != nil {
+		return err
+	}
+	client.Transport = t
+	cfg.HTTPClient = &client
+	cfg.Hooks = append(cfg.Hooks, Hook{})
+	return nil
+}
+
+// Hook is the driver hook ending spans of requests made by a statement, deployment call or
+// put once it's done, with its row count and error. Without it, spans of Transport end once
+// their responses read or closed, without row counts and errors in response body.
+type Hook struct{}
+
+// driverOpKey is the context key of *driverOp
+type driverOpKey struct{}
+
+// Before implements openmldb.Hook.
+func (Hook) Before(ctx context.Context, req *openmldb.Request) (context.Context, *openmldb.Result, error) {
+	return context.WithValue(ctx, driverOpKey{}, &driverOp{}), nil, nil
+}
+
+// After implements openmldb.Hook.
+func (Hook) After(ctx context.Context, req *openmldb.Request, res *openmldb.Result, err error) {
+	if op, ok := ctx.Value(driverOpKey{}).(*driverOp); ok {
+		op.end(req.Op, res, err)
+	}
+}
+
+// driverOp holds observations of requests made by an operation seen by Hook, ended by it
+type driverOp struct {
+	mu    sync.Mutex
+	obs   []*observation
+	ended bool
+}
+
+// add adds o to op, false if op already ended
+func (op *driverOp) add(o *observation) bool {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if op.ended {
+		return false
+	}
+	op.obs = append(op.obs, o)
+	o.op = op
+	return true
+}
+
+// end ends observations of op, the last with row count and error of the operation
+func (op *driverOp) end(kind openmldb.Op, res *openmldb.Result, err error) {
+	op.mu.Lock()
+	obs := op.obs
+	op.obs, op.ended = nil, true
+	op.mu.Unlock()
+
+	for i, o := range obs {
+		op.mu.Lock()
+		errType, oerr := o.errType, o.err
+		op.mu.Unlock()
+		rows := -1
+		if i == len(obs)-1 {
+			switch {
+			case errType == "" && err != nil:
+				errType, oerr = errorType(err), err
+			case err == nil && res != nil && kind != openmldb.OpPut:
+				rows = res.RowCount
+			}
+		}
+		o.end(errType, oerr, rows)
+	}
+}
+
+// Transport is a http.RoundTripper instruments requests to api server. Create it by NewTransport.
+type Transport struct {
+	base        http.RoundTripper
+	tracer      trace.Tracer
+	propagators propagation.TextMapPropagator
+	duration    metric.Float64Histogram
+	errors      metric.Int64Counter
+}
+
+// NewTransport creates Transport sends requests by base, http.DefaultTransport if nil.
+func NewTransport(base http.RoundTripper, opts ...Option) (*Transport, error) {
+	cfg := config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.tracerProvider == nil {
+		cfg.tracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.meterProvider == nil {
+		cfg.meterProvider = otel.GetMeterProvider()
+	}
+	if cfg.propagators == nil {
+		cfg.propagators = otel.GetTextMapPropagator()
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	meter := cfg.meterProvider.Meter(instrumentationName)
+	duration, err := meter.Float64Histogram("db.client.operation.duration",
+		metric.WithDescription("Duration of requests to OpenMLDB api server."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	errs, err := meter.Int64Counter("openmldb.client.errors",
+		metric.WithDescription("Number of failed requests to OpenMLDB api server."),
+		metric.WithUnit("{error}"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transport{
+		base:        base,
+		tracer:      cfg.tracerProvider.Tracer(instrumentationName),
+		propagators: cfg.propagators,
+		duration:    duration,
+		errors:      errs,
+	}, nil
+}
+
+// request is what instrumentation knows about a request from its path and body
+type request struct {
+	db         string
+	operation  string
+	mode       string
+	deployment string
+	sql        string
+}
+
+func parseRequest(req *http.Request, body []byte) request {
+	// paths are /dbs/{db}, /dbs/{db}/tables/{table} and /dbs/{db}/deployments/{name}
+	p := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	r := request{operation: req.Method}
+	if len(p) >= 2 && p[0] == "dbs" {
+		r.db = p[1]
+	}
+
+	switch {
+	case req.Method == "POST" && len(p) == 2:
+		var q struct {
+			Mode string `json:"mode"`
+			SQL  string `json:"sql"`
+		}
+		json.Unmarshal(body, &q)
+		r.mode = q.Mode
+		r.sql = q.SQL
+		r.operation = operation(q.SQL)
+	case req.Method == "POST" && len(p) == 4 && p[2] == "deployments":
+		r.mode = "request"
+		r.deployment = p[3]
+		r.operation = "CALL"
+	case req.Method == "PUT" && len(p) == 4 && p[2] == "tables":
+		r.operation = "PUT"
+	case req.Method == "GET" && len(p) == 4 && p[2] == "tables":
+		r.operation = "DESCRIBE"
+	}
+	return r
+}
+
+func (r request) spanName() string {
+	if r.deployment != "" {
+		return fmt.Sprintf("%s %s.%s", r.operation, r.db, r.deployment)
+	}
+	if r.db != "" {
+		return r.operation + " " + r.db
+	}
+	return r.operation
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	r := parseRequest(req, body)
+
+	attrs := []attribute.KeyValue{
+		dbSystemKey.String("openmldb"),
+		dbOperationKey.String(r.operation),
+		serverAddressKey.String(req.URL.Host),
+	}
+	if r.db != "" {
+		attrs = append(attrs, dbNamespaceKey.String(r.db))
+	}
+	if r.mode != "" {
+		attrs = append(attrs, ModeKey.String(r.mode))
+	}
+	if r.deployment != "" {
+		attrs = append(attrs, DeploymentKey.String(r.deployment))
+	}
+	spanAttrs := attrs
+	if r.sql != "" {
+		spanAttrs = append(spanAttrs[:len(spanAttrs):len(spanAttrs)], dbQueryTextKey.String(Sanitize(r.sql)))
+	}
+
+	ctx, span := t.tracer.Start(req.Context(), r.spanName(),
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(spanAttrs...))
+
+	req = req.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	t.propagators.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	o := &observation{t: t, ctx: ctx, span: span, start: start, attrs: attrs}
+	if op, ok := req.Context().Value(driverOpKey{}).(*driverOp); ok {
+		op.add(o)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		o.done(errorType(err), err)
+		return nil, err
+	}
+
+	span.SetAttributes(httpStatusKey.Int(resp.StatusCode))
+	resp.Body = &observedBody{ReadCloser: resp.Body, o: o, status: resp.StatusCode}
+	return resp, nil
+}
+
+// observation ends span and records metrics of a request once
+type observation struct {
+	t     *Transport
+	ctx   context.Context
+	span  trace.Span
+	start time.Time
+	attrs []attribute.KeyValue
+	once  sync.Once
+
+	// op is the operation ending the observation, with errType and err of the request
+	// recorded until then, guarded by op.mu
+	op      *driverOp
+	errType string
+	err     error
+}
+
+// done ends the observation when the request done, or records its error for the operation
+// to end it
+func (o *observation) done(errType string, err error) {
+	if o.op == nil {
+		o.end(errType, err, -1)
+		return
+	}
+	o.op.mu.Lock()
+	defer o.op.mu.Unlock()
+	if o.errType == "" {
+		o.errType, o.err = errType, err
+	}
+}
+
+// end ends the observation, with rows returned if not negative
+func (o *observation) end(errType string, err error, rows int) {
+	o.once.Do(func() {
+		if rows >= 0 {
+			o.span.SetAttributes(dbRowsKey.Int(rows))
+		}
+		attrs := o.attrs
+		if errType != "" {
+			attrs = append(attrs[:len(attrs):len(attrs)], errorTypeKey.String(errType))
+			var apiErr *openmldb.APIError
+			if errors.As(err, &apiErr) {
+				attrs = append(attrs, ResponseCodeKey.Int(apiErr.Code))
+			}
+			o.t.errors.Add(o.ctx, 1, metric.WithAttributes(attrs...))
+			o.span.SetAttributes(attrs[len(o.attrs):]...)
+			if err != nil {
+				o.span.RecordError(err)
+				o.span.SetStatus(codes.Error, err.Error())
+			} else {
+				o.span.SetStatus(codes.Error, errType)
+			}
+		}
+		o.t.duration.Record(o.ctx, time.Since(o.start).Seconds(), metric.WithAttributes(attrs...))
+		o.span.End()
+	})
+}
+
+// observedBody ends the observation when response read to EOF or closed
+type observedBody struct {
+	io.ReadCloser
+	o      *observation
+	status int
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.o.done(errorType(err), err)
+	} else if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *observedBody) finish() {
+	if b.status >= 400 {
+		b.o.done(strconv.Itoa(b.status), nil)
+		return
+	}
+	b.o.done("", nil)
+}
+
+func errorType(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	var apiErr *openmldb.APIError
+	if errors.As(err, &apiErr) {
+		return "api_error"
+	}
+	switch t := fmt.Sprintf("%T", err); t {
+	case "*errors.errorString", "*fmt.wrapError":
+		// errors of no type
+		return "_OTHER"
+	default:
+		return t
+	}
+}
@@ -0,0 +1,180 @@
+package otelopenmldb_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+	"github.com/4paradigm/openmldb-go-sdk/otelopenmldb"
+)
+
+func TestInstrument(t *testing.T) {
+	srv := emulator.NewServer()
+	defer srv.Close()
+
+	var traceparents []string
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	cfg, err := openmldb.ParseDSN(srv.DSN("test_db"))
+	require.NoError(t, err)
+	cfg.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		traceparents = append(traceparents, req.Header.Get("traceparent"))
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	require.NoError(t, otelopenmldb.Instrument(cfg,
+		otelopenmldb.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		otelopenmldb.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		otelopenmldb.WithPropagators(propagation.TraceContext{}),
+	))
+	connector, err := openmldb.NewConnector(cfg)
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES ('secret', 1), ('bb', 2)")
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, "SELECT c1 FROM t1 WHERE c2 > ?", int32(0))
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	_, err = db.QueryContext(ctx, "SELECT c9 FROM t1")
+	assert.Error(t, err)
+
+	ended := spans.Ended()
+	require.Len(t, ended, 5) // with ping
+	for i, s := range ended {
+		assert.Equal(t, "00-"+s.SpanContext().TraceID().String()+"-"+s.SpanContext().SpanID().String()+"-01", traceparents[i])
+	}
+
+	insert := attrs(ended[2].Attributes())
+	assert.Equal(t, "INSERT test_db", ended[2].Name())
+	assert.Equal(t, "INSERT INTO t1 VALUES (?, ?), (?, ?)", insert["db.query.text"])
+	assert.Equal(t, "online", insert["openmldb.mode"])
+
+	query := attrs(ended[3].Attributes())
+	assert.Equal(t, "SELECT", query["db.operation.name"])
+	assert.Equal(t, int64(2), query["db.response.returned_rows"])
+
+	failed := attrs(ended[4].Attributes())
+	assert.Equal(t, codes.Error, ended[4].Status().Code)
+	assert.Contains(t, ended[4].Status().Description, "execute error")
+	assert.Equal(t, "api_error", failed["error.type"])
+	assert.Equal(t, int64(-1), failed["openmldb.response.code"])
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	metrics := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m.Data
+	}
+	duration := metrics["db.client.operation.duration"].(metricdata.Histogram[float64])
+	var count uint64
+	for _, p := range duration.DataPoints {
+		count += p.Count
+	}
+	assert.Equal(t, uint64(5), count)
+	errs := metrics["openmldb.client.errors"].(metricdata.Sum[int64])
+	require.Len(t, errs.DataPoints, 1)
+	assert.Equal(t, int64(1), errs.DataPoints[0].Value)
+	code, ok := errs.DataPoints[0].Attributes.Value(otelopenmldb.ResponseCodeKey)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-1), code.AsInt64())
+}
+
+func TestDeploymentSpan(t *testing.T) {
+	srv := emulator.NewServer()
+	defer srv.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	cfg, err := openmldb.ParseDSN(srv.DSN("test_db"))
+	require.NoError(t, err)
+	require.NoError(t, otelopenmldb.Instrument(cfg,
+		otelopenmldb.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))))
+	connector, err := openmldb.NewConnector(cfg)
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DEPLOY demo SELECT c1 FROM t1")
+	require.NoError(t, err)
+	_, err = openmldb.CallDeployment(ctx, db, "demo", []any{"aa", 1})
+	require.NoError(t, err)
+
+	ended := spans.Ended()
+	call := ended[len(ended)-1]
+	assert.Equal(t, "CALL test_db.demo", call.Name())
+	a := attrs(call.Attributes())
+	assert.Equal(t, "demo", a["openmldb.deployment"])
+	assert.Equal(t, "request", a["openmldb.mode"])
+	assert.Equal(t, int64(1), a["db.response.returned_rows"])
+}
+
+func TestTransportWithoutHook(t *testing.T) {
+	srv := emulator.NewServer()
+	defer srv.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	tr, err := otelopenmldb.NewTransport(nil,
+		otelopenmldb.WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))))
+	require.NoError(t, err)
+	connector, err := openmldb.NewConnector(&openmldb.Config{Host: srv.Host(), DB: "test_db",
+		HTTPClient: &http.Client{Transport: tr}})
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	// spans end once responses read, without row counts
+	rows, err := db.QueryContext(context.Background(), "SHOW TABLES")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	ended := spans.Ended()
+	require.Len(t, ended, 2) // with ping
+	assert.Equal(t, "SHOW TABLES", attrs(ended[1].Attributes())["db.operation.name"])
+	assert.NotContains(t, attrs(ended[1].Attributes()), "db.response.returned_rows")
+}
+
+func TestSanitize(t *testing.T) {
+	for sql, expect := range map[string]string{
+		"SELECT * FROM t1 WHERE c1 = 'a''b' AND c2 > 10.5":     "SELECT * FROM t1 WHERE c1 = ? AND c2 > ?",
+		"INSERT INTO `t 1` VALUES (\"x\\\"y\", -1, ?) -- note": "INSERT INTO `t 1` VALUES (?, -?, ?)",
+		"SELECT c1 /* hint */ FROM t2\n\tLIMIT 3":              "SELECT c1 FROM t2 LIMIT ?",
+		"SELECT c1 FROM t1 WHERE c1 = 'unterminated":           "SELECT c1 FROM t1 WHERE c1 = ?",
+		"SELECT sum(c2) OVER w FROM t1 WINDOW w AS (PARTITION BY c1 ORDER BY ts ROWS_RANGE BETWEEN 3d PRECEDING AND CURRENT ROW)": "SELECT sum(c2) OVER w FROM t1 WINDOW w AS (PARTITION BY c1 ORDER BY ts ROWS_RANGE BETWEEN ? PRECEDING AND CURRENT ROW)",
+	} {
+		assert.Equal(t, expect, otelopenmldb.Sanitize(sql))
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func attrs(kvs []attribute.KeyValue) map[string]any {
+	m := map[string]any{}
+	for _, kv := range kvs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
@@ -0,0 +1,59 @@
+package otelopenmldb
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+// Sanitize replaces string and number literals in sql with '?', and removes comments, so
+// that SQL is safe and of low cardinality as span attribute. Identifiers are kept.
+func Sanitize(sql string) string {
+	toks, err := sqlparse.Lex(sql)
+
+	var b strings.Builder
+	b.Grow(len(sql))
+	end := 0
+	write := func(pos int, s string) {
+		// whitespaces and comments between tokens are written as a single space
+		if pos > end && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s)
+	}
+	for _, tok := range toks {
+		switch tok.Kind {
+		case sqlparse.EOF:
+			continue
+		case sqlparse.String, sqlparse.Number:
+			write(tok.Pos, "?")
+		default:
+			write(tok.Pos, tok.Text)
+		}
+		end = tok.End
+	}
+
+	// the rest of unterminated quoted string is a literal
+	var lexErr *sqlparse.Error
+	if errors.As(err, &lexErr) && lexErr.Pos < len(sql) && strings.IndexByte("'\"`", sql[lexErr.Pos]) >= 0 {
+		write(lexErr.Pos, "?")
+	}
+	return b.String()
+}
+
+// operation returns the kind of statement by its leading keywords, e.g. SELECT or CREATE TABLE
+func operation(sql string) string {
+	fields := strings.Fields(Sanitize(sql))
+	if len(fields) == 0 {
+		return "EXECUTE"
+	}
+	op := strings.ToUpper(fields[0])
+	switch op {
+	case "CREATE", "DROP", "SHOW", "LOAD":
+		if len(fields) > 1 {
+			return op + " " + strings.ToUpper(strings.TrimRight(fields[1], "(;"))
+		}
+	}
+	return strings.TrimRight(op, "(;")
+}
@@ -0,0 +1,228 @@
+package openmldb
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+// KindCall is the kind of deployment calls by CallDeployment, matched by Rule.Kinds. Rows put
+// by PutRow are of kind DML.
+const KindCall = "CALL"
+
+// readKinds are the kinds allowed by read-only connections, SET only of session variables,
+// see sessionSet
+var readKinds = map[string]bool{
+	sqlparse.KindDQL.String():  true,
+	sqlparse.KindShow.String(): true,
+	sqlparse.KindUse.String():  true,
+	KindCall:                   true,
+}
+
+// Policy allows or denies requests before they are sent to api server, see Config.Policy.
+type Policy struct {
+	// Rules are checked in order, the first rule matching a statement decides.
+	Rules []Rule
+	// DenyUnmatched denies statements matched by no rule, which are allowed otherwise.
+	DenyUnmatched bool
+}
+
+// Rule matches statements by kind, table and mode. Empty conditions match all.
+type Rule struct {
+	// Deny denies the matched statements, which are allowed otherwise.
+	Deny bool
+	// Kinds are statement kinds, e.g. DQL, DML, DDL, DEPLOY, SHOW, LOAD DATA, SELECT INTO or
+	// CALL, as StatementResult.Kind.
+	Kinds []string
+	// Tables are patterns of tables in path.Match syntax, e.g. "tmp_*", matched if any table of
+	// the statement matches. Patterns with a dot match tables qualified by database, e.g. "db1.*".
+	// Statements without tables are not matched.
+	Tables []string
+	// Modes are execution modes.
+	Modes []queryMode
+}
+
+// PolicyError is returned for requests denied by Config.ReadOnly, Config.Policy or
+// Config.GuardOffline, before they are sent.
+type PolicyError struct {
+	Op Op
+	// SQL is the denied statement of OpQuery.
+	SQL string
+	// Kind is the statement kind, e.g. DDL, or CALL for OpDeployment.
+	Kind string
+	// Tables are the tables of statement, qualified by database.
+	Tables []string
+	Mode   queryMode
+	// Reason is why the statement is denied.
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	if len(e.Tables) == 0 {
+		return fmt.Sprintf("policy violation: %s: %s", e.Kind, e.Reason)
+	}
+	return fmt.Sprintf("policy violation: %s on %s: %s", e.Kind, strings.Join(e.Tables, ", "), e.Reason)
+}
+
+type permitOfflineKey struct{}
+
+// PermitOffline returns a context permitting offline jobs on connections guarded by
+// Config.GuardOffline.
+func PermitOffline(ctx context.Context) context.Context {
+	return context.WithValue(ctx, permitOfflineKey{}, true)
+}
+
+// policyHook enforces the policy of Config, installed after all hooks of Config.Hooks so the
+// request checked is the one sent
+type policyHook struct {
+	readOnly     bool
+	policy       *Policy
+	guardOffline bool
+}
+
+func newPolicyHook(cfg *Config) *policyHook {
+	return &policyHook{readOnly: cfg.ReadOnly, policy: cfg.Policy, guardOffline: cfg.GuardOffline}
+}
+
+// Before implements Hook.
+func (h *policyHook) Before(ctx context.Context, req *Request) (context.Context, *Result, error) {
+	for _, e := range h.statements(req) {
+		if err := h.check(ctx, e); err != nil {
+			return ctx, nil, err
+		}
+	}
+	return ctx, nil, nil
+}
+
+// After implements Hook.
+func (h *policyHook) After(context.Context, *Request, *Result, error) {}
+
+// statements returns the statements of request to check, one for each statement of a
+// multi-statement query
+func (h *policyHook) statements(req *Request) []*PolicyError {
+	switch req.Op {
+	case OpDeployment:
+		return []*PolicyError{{Op: req.Op, Kind: KindCall, Mode: ModeOnline}}
+	case OpPut:
+		return []*PolicyError{{Op: req.Op, Kind: sqlparse.KindDML.String(), Tables: []string{req.DB + "." + req.Table},
+			Mode: ModeOnline}}
+	}
+
+	sqls := []string{req.SQL}
+	if spans, err := sqlparse.Split(req.SQL); err == nil && len(spans) > 1 {
+		sqls = sqls[:0]
+		for _, span := range spans {
+			sqls = append(sqls, span.Text)
+		}
+	}
+	var stmts []*PolicyError
+	for _, sql := range sqls {
+		e := &PolicyError{Op: req.Op, SQL: sql, Kind: sqlparse.KindOther.String(), Mode: req.Mode}
+		// statements not classified are of kind OTHER
+		if stmt, err := sqlparse.Classify(sql); err == nil {
+			e.Kind = stmt.Kind.String()
+			for _, t := range stmt.Tables {
+				if !strings.Contains(t, ".") {
+					t = req.DB + "." + t
+				}
+				e.Tables = append(e.Tables, t)
+			}
+		}
+		stmts = append(stmts, e)
+	}
+	return stmts
+}
+
+// check returns e with reason set if statement is denied, nil otherwise
+func (h *policyHook) check(ctx context.Context, e *PolicyError) error {
+	if h.readOnly && !readKinds[e.Kind] && !(e.Kind == sqlparse.KindSet.String() && sessionSet(e.SQL)) {
+		e.Reason = "read-only connection"
+		return e
+	}
+	if h.policy != nil {
+		allowed, matched := !h.policy.DenyUnmatched, false
+		for i, r := range h.policy.Rules {
+			if r.match(e) {
+				allowed, matched = !r.Deny, true
+				if !allowed {
+					e.Reason = fmt.Sprintf("denied by rule %d", i)
+				}
+				break
+			}
+		}
+		if !allowed {
+			if !matched {
+				e.Reason = "not allowed by any rule"
+			}
+			return e
+		}
+	}
+	if h.guardOffline && isOfflineJob(e) && ctx.Value(permitOfflineKey{}) == nil {
+		e.Reason = "offline job not permitted, see PermitOffline"
+		return e
+	}
+	return nil
+}
+
+// sessionSet reports whether SET statement sets session variables only, e.g. SET @@a = 1 or
+// SET @@session.a = 1, not SET GLOBAL a = 1 or SET @@global.a = 1, which change the cluster
+func sessionSet(sql string) bool {
+	toks, err := sqlparse.Lex(sql)
+	if err != nil || len(toks) < 2 || toks[1].Kind != sqlparse.Variable {
+		return false
+	}
+	for _, t := range toks {
+		if t.Kind == sqlparse.Variable && strings.HasPrefix(strings.ToLower(t.Text), "@@global.") {
+			return false
+		}
+	}
+	return true
+}
+
+// isOfflineJob reports whether statement runs as a job, in offline mode or loading data
+func isOfflineJob(e *PolicyError) bool {
+	return (e.Mode != "" && e.Mode != ModeOnline) ||
+		e.Kind == sqlparse.KindLoadData.String() || e.Kind == sqlparse.KindSelectInto.String()
+}
+
+func (r *Rule) match(e *PolicyError) bool {
+	if len(r.Kinds) > 0 && !containsFold(r.Kinds, e.Kind) {
+		return false
+	}
+	if len(r.Modes) > 0 {
+		found := false
+		for _, m := range r.Modes {
+			found = found || m == e.Mode
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Tables) > 0 {
+		for _, pattern := range r.Tables {
+			for _, t := range e.Tables {
+				name := t
+				if !strings.Contains(pattern, ".") {
+					name = t[strings.LastIndexByte(t, '.')+1:]
+				}
+				if ok, _ := path.Match(pattern, name); ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
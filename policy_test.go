@@ -0,0 +1,135 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+func TestPolicy(t *testing.T) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	open := func(cfg *Config) *sql.DB {
+		cfg.Host, cfg.DB = srv.Host(), "test_db"
+		connector, err := NewConnector(cfg)
+		require.NoError(t, err)
+		db := sql.OpenDB(connector)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	violation := func(err error) *PolicyError {
+		var perr *PolicyError
+		require.True(t, errors.As(err, &perr), "%v", err)
+		return perr
+	}
+
+	admin := open(&Config{})
+	for _, s := range []string{
+		"CREATE TABLE t1 (c1 string, c2 int, INDEX(KEY=c1))",
+		"CREATE TABLE tmp_t2 (c1 string, c2 int, INDEX(KEY=c1))",
+		"INSERT INTO t1 VALUES ('a', 1)",
+		"DEPLOY d1 SELECT c1, c2 FROM t1",
+	} {
+		_, err := admin.ExecContext(ctx, s)
+		require.NoError(t, err)
+	}
+
+	t.Run("read only", func(t *testing.T) {
+		cfg, err := ParseDSN(srv.DSN("test_db") + "?readonly=true")
+		require.NoError(t, err)
+		db := open(cfg)
+
+		var n int32
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT c2 FROM t1").Scan(&n))
+		rows, err := db.QueryContext(ctx, "SHOW TABLES")
+		require.NoError(t, err)
+		rows.Close()
+		_, err = CallDeployment(ctx, db, "d1", []any{"a", 1})
+		require.NoError(t, err)
+
+		_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES ('b', 2)")
+		assert.EqualError(t, err, "policy violation: DML on test_db.t1: read-only connection")
+		assert.Equal(t, "INSERT INTO t1 VALUES ('b', 2)", violation(err).SQL)
+
+		_, err = db.ExecContext(ctx, "SELECT 1; DROP TABLE t1")
+		perr := violation(err)
+		assert.Equal(t, "DDL", perr.Kind)
+		assert.Equal(t, "DROP TABLE t1", perr.SQL)
+
+		err = PutRow(ctx, db, "t1", map[string]any{"c1": "b", "c2": 2})
+		assert.Equal(t, OpPut, violation(err).Op)
+
+		_, err = db.ExecContext(ctx, "SELECT * FROM t1 INTO OUTFILE '/tmp/t1.csv'")
+		assert.Equal(t, "SELECT INTO", violation(err).Kind)
+
+		for _, s := range []string{"SET @@execute_mode = 'online'", "SET @@session.execute_mode = 'online'"} {
+			_, err = db.ExecContext(ctx, s)
+			assert.NoError(t, err, s)
+		}
+		for _, s := range []string{"SET GLOBAL execute_mode = 'offline'", "SET @@global.execute_mode = 'offline'",
+			"SET @@execute_mode = 'online', @@GLOBAL.execute_mode = 'offline'"} {
+			_, err = db.ExecContext(ctx, s)
+			assert.EqualError(t, err, "policy violation: SET: read-only connection", s)
+		}
+
+		tables, err := DescribeTables(ctx, db)
+		require.NoError(t, err)
+		assert.Len(t, tables, 2)
+	})
+
+	t.Run("rules", func(t *testing.T) {
+		db := open(&Config{Policy: &Policy{
+			Rules: []Rule{
+				{Deny: true, Kinds: []string{"dql"}, Modes: []queryMode{ModeOffsync}},
+				{Kinds: []string{"DQL", "SHOW"}},
+				{Kinds: []string{"DML"}, Tables: []string{"tmp_*"}},
+				{Deny: true, Tables: []string{"test_db.t*"}},
+			},
+			DenyUnmatched: true,
+		}})
+
+		var n int32
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT c2 FROM t1").Scan(&n))
+		_, err := db.ExecContext(ctx, "INSERT INTO tmp_t2 VALUES ('a', 1)")
+		require.NoError(t, err)
+
+		_, err = db.ExecContext(ctx, "INSERT INTO t1 VALUES ('b', 2)")
+		assert.EqualError(t, err, "policy violation: DML on test_db.t1: denied by rule 3")
+		_, err = db.ExecContext(ctx, "CREATE TABLE t3 (c1 string)")
+		assert.EqualError(t, err, "policy violation: DDL on test_db.t3: denied by rule 3")
+		_, err = db.ExecContext(ctx, "CREATE DATABASE db2")
+		assert.EqualError(t, err, "policy violation: DDL: not allowed by any rule")
+
+		cfg := &Config{Mode: ModeOffsync, Policy: &Policy{Rules: []Rule{
+			{Deny: true, Kinds: []string{"DQL"}, Modes: []queryMode{ModeOffsync}},
+		}}}
+		_, err = open(cfg).QueryContext(ctx, "SELECT c2 FROM t1")
+		assert.EqualError(t, err, "policy violation: DQL on test_db.t1: denied by rule 0")
+	})
+
+	t.Run("offline guard", func(t *testing.T) {
+		cfg, err := ParseDSN(srv.DSN("test_db") + "?mode=offsync&guardOffline=true")
+		require.NoError(t, err)
+		db := open(cfg)
+
+		_, err = db.QueryContext(ctx, "SELECT c2 FROM t1")
+		assert.EqualError(t, err, "policy violation: DQL on test_db.t1: offline job not permitted, see PermitOffline")
+		rows, err := db.QueryContext(PermitOffline(ctx), "SELECT c2 FROM t1")
+		require.NoError(t, err)
+		rows.Close()
+
+		online := open(&Config{GuardOffline: true})
+		var n int32
+		require.NoError(t, online.QueryRowContext(ctx, "SELECT c2 FROM t1").Scan(&n))
+		_, err = online.ExecContext(ctx, "LOAD DATA INFILE 'file:///tmp/t1.csv' INTO TABLE t1")
+		assert.Equal(t, "LOAD DATA", violation(err).Kind)
+	})
+}
@@ -0,0 +1,291 @@
+package openmldb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// columnDesc describes a column in table schema returned by api server
+type columnDesc struct {
+	Name     string `json:"name"`
+	DataType string `json:"data_type"`
+	NotNull  bool   `json:"not_null"`
+}
+
+type tableResp struct {
+	Code  int        `json:"code"`
+	Msg   string     `json:"msg"`
+	Table *tableDesc `json:"table,omitempty"`
+}
+
+type putReq struct {
+	Value [][]any `json:"value"`
+}
+
+type putResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// PutRow inserts a row into table via the row insert API of api server, which skips SQL
+// parsing, so it is a lower latency write path than INSERT statement.
+//
+// values are keyed by column name, and validated against table schema: unknown column,
+// missing NOT NULL column and value not convertible to column type are reported as error.
+// Missing nullable columns are inserted as NULL.
+func PutRow(ctx context.Context, db *sql.DB, table string, values map[string]any) error {
+	return PutRows(ctx, db, table, []map[string]any{values})
+}
+
+// PutRows inserts rows into table by PutRow, table schema fetched once for all rows.
+//
+// Rows are put one by one, error returned on the first failed row, rows before it are inserted.
+func PutRows(ctx context.Context, db *sql.DB, table string, rows []map[string]any) error {
+	dbConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	return dbConn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return fmt.Errorf("not an openmldb connection: %T", driverConn)
+		}
+
+		schema, err := c.tableSchema(ctx, table)
+		if err != nil {
+			return err
+		}
+
+		for i, values := range rows {
+			row, err := orderRowValues(schema, values, c.times)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", i, err)
+			}
+			if err := c.putRow(ctx, table, row); err != nil {
+				return fmt.Errorf("row %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (c *conn) tableSchema(ctx context.Context, table string) ([]columnDesc, error) {
+	var r tableResp
+	path := fmt.Sprintf("/dbs/%s/tables/%s", url.PathEscape(c.db), url.PathEscape(table))
+	if err := c.doJSON(ctx, "GET", path, nil, &r); err != nil {
+		return nil, err
+	}
+	if r.Code != 0 {
+		return nil, fmt.Errorf("get table error: %s", r.Msg)
+	}
+	if r.Table == nil {
+		return nil, fmt.Errorf("get table error: table %s not found", table)
+	}
+	return r.Table.ColumnDesc, nil
+}
+
+func (c *conn) putRow(ctx context.Context, table string, row []any) error {
+	req := &Request{Op: OpPut, Endpoint: c.host, DB: c.db, Table: table, Rows: [][]any{row}}
+	_, err := c.intercept(ctx, req, func(ctx context.Context, req *Request) (*Result, error) {
+		var r putResp
+		path := fmt.Sprintf("/dbs/%s/tables/%s", url.PathEscape(req.DB), url.PathEscape(req.Table))
+		if err := c.doJSON(ctx, "PUT", path, putReq{Value: req.Rows}, &r); err != nil {
+			return nil, err
+		}
+		if r.Code != 0 {
+			return nil, &APIError{Op: OpPut, Code: r.Code, Msg: r.Msg}
+		}
+		return &Result{RowCount: len(req.Rows)}, nil
+	})
+	return err
+}
+
+// doJSON sends request with JSON body to path of api server, and decodes JSON response into resp.
+// Responses of non-2xx status are errors, with the start of body quoted.
+func (c *conn) doJSON(ctx context.Context, method string, path string, body any, resp any) error {
+	if c.closed {
+		return driver.ErrBadConn
+	}
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s%s", c.host, path), &reqBody)
+	if err != nil {
+		return err
+	}
+
+	r, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer closeBody(r.Body)
+
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(r.Body, maxErrorBody))
+		return fmt.Errorf("%s %s: %s: %s", method, path, r.Status, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(r.Body).Decode(resp); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+	}
+	return nil
+}
+
+// maxErrorBody bounds the body of a non-2xx response quoted in error
+const maxErrorBody = 512
+
+// orderRowValues orders values by columns in schema, converting each value
+// into the JSON representation of column type in put API, dates in the location of tc
+func orderRowValues(schema []columnDesc, values map[string]any, tc timeCodec) ([]any, error) {
+	row := make([]any, len(schema))
+	found := 0
+	for i, col := range schema {
+		v, ok := values[col.Name]
+		if ok {
+			found++
+		}
+
+		jv, err := putValue(col, v, tc)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = jv
+	}
+
+	if found != len(values) {
+		for name := range values {
+			if !hasColumn(schema, name) {
+				return nil, fmt.Errorf("unknown column '%s'", name)
+			}
+		}
+	}
+	return row, nil
+}
+
+func hasColumn(schema []columnDesc, name string) bool {
+	for _, col := range schema {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func putValue(col columnDesc, v any, tc timeCodec) (any, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		if err != nil {
+			return nil, fmt.Errorf("column '%s': %w", col.Name, err)
+		}
+		v = dv
+	}
+
+	if v == nil {
+		if col.NotNull {
+			return nil, fmt.Errorf("column '%s': NULL value for NOT NULL column", col.Name)
+		}
+		return nil, nil
+	}
+
+	var (
+		jv  any
+		err error
+	)
+	switch col.DataType {
+	case "kBool":
+		b, ok := v.(bool)
+		if !ok {
+			err = errInvalidPutValue
+		}
+		jv = b
+	case "kSmallInt":
+		jv, err = putInt(v, math.MinInt16, math.MaxInt16)
+	case "kInt":
+		jv, err = putInt(v, math.MinInt32, math.MaxInt32)
+	case "kBigInt":
+		jv, err = putInt(v, math.MinInt64, math.MaxInt64)
+	case "kFloat", "kDouble":
+		jv, err = putFloat(v)
+	case "kVarchar", "kString":
+		s, ok := v.(string)
+		if !ok {
+			err = errInvalidPutValue
+		}
+		jv = s
+	case "kDate":
+		t, ok := v.(time.Time)
+		if !ok {
+			err = errInvalidPutValue
+		}
+		jv = tc.dateIn(t).Format(time.DateOnly)
+	case "kTimestamp":
+		if t, ok := v.(time.Time); ok {
+			jv = t.UnixMilli()
+		} else {
+			jv, err = putInt(v, math.MinInt64, math.MaxInt64)
+		}
+	default:
+		return nil, fmt.Errorf("column '%s': unsupported type %s", col.Name, col.DataType)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("column '%s': %w: %v (%T) for type %s", col.Name, err, v, v, col.DataType)
+	}
+	return jv, nil
+}
+
+var (
+	errInvalidPutValue = errors.New("invalid value")
+	errPutOutOfRange   = errors.New("value out of range")
+)
+
+func putInt(v any, lo int64, hi int64) (int64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if i < lo || i > hi {
+			return 0, errPutOutOfRange
+		}
+		return i, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u > uint64(hi) {
+			return 0, errPutOutOfRange
+		}
+		return int64(u), nil
+	default:
+		return 0, errInvalidPutValue
+	}
+}
+
+func putFloat(v any) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	default:
+		return 0, errInvalidPutValue
+	}
+}
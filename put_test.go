@@ -0,0 +1,136 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var putTestSchema = []columnDesc{
+	{"c1", "kInt", true},
+	{"c2", "kVarchar", false},
+	{"ts", "kTimestamp", false},
+	{"dt", "kDate", false},
+	{"val", "kDouble", false},
+}
+
+func TestOrderRowValues(t *testing.T) {
+	for _, tc := range []struct {
+		values map[string]any
+		expect []any
+		err    string
+	}{
+		{
+			map[string]any{
+				"dt":  NullDate{Null: sql.Null[time.Time]{V: time.Date(2022, time.October, 10, 0, 0, 0, 0, time.UTC), Valid: true}},
+				"ts":  time.UnixMilli(4000),
+				"c2":  "bb",
+				"c1":  int16(1),
+				"val": float32(1.5),
+			},
+			[]any{int64(1), "bb", int64(4000), "2022-10-10", float64(1.5)},
+			"",
+		},
+		{
+			map[string]any{"c1": 1, "c2": Null[string]{}},
+			[]any{int64(1), nil, nil, nil, nil},
+			"",
+		},
+		{map[string]any{"c2": "bb"}, nil, "column 'c1': NULL value for NOT NULL column"},
+		{map[string]any{"c1": int64(1 << 40)}, nil, "column 'c1': value out of range: 1099511627776 (int64) for type kInt"},
+		{map[string]any{"c1": "1"}, nil, "column 'c1': invalid value: 1 (string) for type kInt"},
+		{map[string]any{"c1": 1, "c3": 1}, nil, "unknown column 'c3'"},
+	} {
+		row, err := orderRowValues(putTestSchema, tc.values, timeCodec{})
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err)
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expect, row)
+		}
+	}
+	// dates are put in loc, 2022-10-10 20:00 UTC is 2022-10-11 in Shanghai
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	row, err := orderRowValues(putTestSchema, map[string]any{
+		"c1": 1,
+		"dt": NullDate{Null: sql.Null[time.Time]{V: time.Date(2022, time.October, 10, 20, 0, 0, 0, time.UTC), Valid: true}},
+	}, timeCodec{loc: shanghai})
+	require.NoError(t, err)
+	assert.Equal(t, "2022-10-11", row[3])
+}
+
+func TestPutRows(t *testing.T) {
+	var puts [][]any
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/dbs/test_db/tables/demo":
+			json.NewEncoder(w).Encode(map[string]any{
+				"code":  0,
+				"msg":   "ok",
+				"table": map[string]any{"name": "demo", "column_desc": putTestSchema},
+			})
+		case r.Method == "PUT" && r.URL.Path == "/dbs/test_db/tables/demo":
+			var req struct {
+				Value [][]any `json:"value"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			puts = append(puts, req.Value...)
+			w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+		default:
+			// ping
+			w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+		}
+	})
+
+	err := PutRows(context.Background(), db, "demo", []map[string]any{
+		{"c1": int32(1), "c2": "bb"},
+		{"c1": int32(2), "ts": time.UnixMilli(3000)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]any{
+		{float64(1), "bb", nil, nil, nil},
+		{float64(2), nil, float64(3000), nil, nil},
+	}, puts)
+
+	err = PutRow(context.Background(), db, "demo", map[string]any{"c2": "bb"})
+	assert.EqualError(t, err, "row 0: column 'c1': NULL value for NOT NULL column")
+}
+
+func TestPutRowsErrors(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/dbs/my/db/tables/"):
+			paths = append(paths, r.URL.EscapedPath())
+			json.NewEncoder(w).Encode(map[string]any{
+				"code":  0,
+				"msg":   "ok",
+				"table": map[string]any{"name": "demo", "column_desc": putTestSchema},
+			})
+		case r.Method == "PUT":
+			paths = append(paths, r.URL.EscapedPath())
+			http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		default:
+			// ping
+			w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	connector, err := NewConnector(&Config{Host: strings.TrimPrefix(srv.URL, "http://"), DB: "my/db"})
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+
+	err = PutRow(context.Background(), db, "demo", map[string]any{"c1": int32(1)})
+	assert.EqualError(t, err, "row 0: PUT /dbs/my%2Fdb/tables/demo: 502 Bad Gateway: upstream unavailable")
+	assert.Equal(t, []string{"/dbs/my%2Fdb/tables/demo", "/dbs/my%2Fdb/tables/demo"}, paths)
+}
@@ -0,0 +1,73 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+)
+
+// DeployBuilder builds a DEPLOY statement of a SELECT. Methods modify and return the builder.
+type DeployBuilder struct {
+	name        string
+	query       *SelectBuilder
+	ifNotExists bool
+	options     map[string]string
+}
+
+// Deploy starts a DEPLOY statement of q named name.
+func Deploy(name string, q *SelectBuilder) *DeployBuilder {
+	return &DeployBuilder{name: name, query: q, options: map[string]string{}}
+}
+
+// IfNotExists deploys only if deployment not exists.
+func (d *DeployBuilder) IfNotExists() *DeployBuilder {
+	d.ifNotExists = true
+	return d
+}
+
+// Option sets an option of deployment, e.g. Option("long_windows", "w1:1d").
+func (d *DeployBuilder) Option(key, value string) *DeployBuilder {
+	d.options[strings.ToUpper(key)] = value
+	return d
+}
+
+// Build returns the SQL of DEPLOY. The deployed query can't have arguments, as the values
+// of a deployment are requested rows.
+func (d *DeployBuilder) Build() (string, error) {
+	if !plainIdent.MatchString(d.name) {
+		return "", fmt.Errorf("query: invalid deployment name %q", d.name)
+	}
+	sql, args, err := d.query.Build()
+	if err != nil {
+		return "", err
+	}
+	if len(args) > 0 {
+		return "", fmt.Errorf("query: deployment %s: deployed query has %d arguments", d.name, len(args))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("DEPLOY ")
+	if d.ifNotExists {
+		sb.WriteString("IF NOT EXISTS ")
+	}
+	sb.WriteString(d.name)
+	if len(d.options) > 0 {
+		keys := make([]string, 0, len(d.options))
+		for k := range d.options {
+			if !plainIdent.MatchString(k) {
+				return "", fmt.Errorf("query: deployment %s: invalid option %q", d.name, k)
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		opts := make([]string, len(keys))
+		for i, k := range keys {
+			opts[i] = k + "=" + openmldb.QuoteLiteral(d.options[k])
+		}
+		sb.WriteString(" OPTIONS(" + strings.Join(opts, ", ") + ")")
+	}
+	sb.WriteString(" " + sql)
+	return sb.String(), nil
+}
@@ -0,0 +1,265 @@
+// Package query builds SQL of the OpenMLDB dialect: SELECT with windows and LAST JOIN, and
+// DEPLOY of it. Values are passed as arguments of `?` placeholders, so they go through the
+// parameter path of the driver instead of being formatted into SQL:
+//
+//	q := query.Select("c1", "sum(c2) OVER w1 AS s").
+//		From("t1").
+//		Where("c1 = ?", "a").
+//		Window(query.NewWindow("w1").PartitionBy("c1").OrderBy("ts").
+//			RowsRange(query.Preceding("3d"), query.CurrentRow))
+//	sql, args, err := q.Build()
+//	rows, err := db.QueryContext(ctx, sql, args...)
+//
+// Columns, tables and conditions are SQL fragments written as is, names of windows and
+// deployments are checked to be plain identifiers.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+var plainIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// fragment is a SQL fragment with arguments of its placeholders
+type fragment struct {
+	sql  string
+	args []any
+}
+
+// check reports an error if the number of placeholders in fragment differs from arguments
+func (f fragment) check(clause string) error {
+	toks, err := sqlparse.Lex(f.sql)
+	if err != nil {
+		return fmt.Errorf("query: %s %q: %w", clause, f.sql, err)
+	}
+	n := 0
+	for _, t := range toks {
+		if t.Kind == sqlparse.Placeholder {
+			n++
+		}
+	}
+	if n != len(f.args) {
+		return fmt.Errorf("query: %s %q: %d placeholders but %d arguments", clause, f.sql, n, len(f.args))
+	}
+	return nil
+}
+
+// join is a LAST JOIN clause
+type join struct {
+	table   string
+	orderBy string
+	on      fragment
+}
+
+// SelectBuilder builds a SELECT statement. Methods modify and return the builder.
+type SelectBuilder struct {
+	columns []string
+	from    string
+	sub     *SelectBuilder
+	joins   []join
+	where   []fragment
+	groupBy []string
+	having  []fragment
+	windows []*WindowBuilder
+	orderBy []string
+	limit   int
+	err     error
+}
+
+// Select starts a SELECT statement of columns, which are expressions, e.g. "c1",
+// "sum(c2) OVER w1 AS s".
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns, limit: -1}
+}
+
+// From sets the table, e.g. "t1" or "db1.t1 AS a".
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from, b.sub = table, nil
+	return b
+}
+
+// FromQuery sets a subquery as the table, named alias.
+func (b *SelectBuilder) FromQuery(q *SelectBuilder, alias string) *SelectBuilder {
+	if !plainIdent.MatchString(alias) {
+		b.setErr(fmt.Errorf("query: invalid alias %q", alias))
+	}
+	b.from, b.sub = alias, q
+	return b
+}
+
+// LastJoin adds LAST JOIN of table on condition, which joins the last matched row of table
+// in its storage order.
+func (b *SelectBuilder) LastJoin(table string, on string, args ...any) *SelectBuilder {
+	return b.LastJoinOrderBy(table, "", on, args...)
+}
+
+// LastJoinOrderBy adds LAST JOIN of table ORDER BY column on condition, which joins the
+// matched row of table with the largest column.
+func (b *SelectBuilder) LastJoinOrderBy(table string, orderBy string, on string, args ...any) *SelectBuilder {
+	b.joins = append(b.joins, join{table: table, orderBy: orderBy, on: fragment{on, args}})
+	return b
+}
+
+// Where adds condition, ANDed with the others.
+func (b *SelectBuilder) Where(cond string, args ...any) *SelectBuilder {
+	b.where = append(b.where, fragment{cond, args})
+	return b
+}
+
+// GroupBy adds GROUP BY expressions.
+func (b *SelectBuilder) GroupBy(exprs ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, exprs...)
+	return b
+}
+
+// Having adds HAVING condition, ANDed with the others.
+func (b *SelectBuilder) Having(cond string, args ...any) *SelectBuilder {
+	b.having = append(b.having, fragment{cond, args})
+	return b
+}
+
+// Window adds named windows.
+func (b *SelectBuilder) Window(windows ...*WindowBuilder) *SelectBuilder {
+	b.windows = append(b.windows, windows...)
+	return b
+}
+
+// OrderBy adds ORDER BY expressions, e.g. "c1 DESC".
+func (b *SelectBuilder) OrderBy(exprs ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, exprs...)
+	return b
+}
+
+// Limit sets LIMIT of rows.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	if n < 0 {
+		b.setErr(fmt.Errorf("query: invalid limit %d", n))
+	}
+	b.limit = n
+	return b
+}
+
+func (b *SelectBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Build returns the SQL and the arguments of its placeholders, in order.
+func (b *SelectBuilder) Build() (string, []any, error) {
+	var (
+		sb   strings.Builder
+		args []any
+	)
+	if err := b.build(&sb, &args); err != nil {
+		return "", nil, err
+	}
+	return sb.String(), args, nil
+}
+
+// String returns the SQL, or the error of Build.
+func (b *SelectBuilder) String() string {
+	sql, _, err := b.Build()
+	if err != nil {
+		return err.Error()
+	}
+	return sql
+}
+
+func (b *SelectBuilder) build(sb *strings.Builder, args *[]any) error {
+	if b.err != nil {
+		return b.err
+	}
+	if len(b.columns) == 0 {
+		return fmt.Errorf("query: no columns selected")
+	}
+
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(b.columns, ", "))
+
+	if b.sub != nil {
+		sb.WriteString(" FROM (")
+		if err := b.sub.build(sb, args); err != nil {
+			return err
+		}
+		sb.WriteString(") AS ")
+		sb.WriteString(b.from)
+	} else if b.from != "" {
+		sb.WriteString(" FROM ")
+		sb.WriteString(b.from)
+	}
+
+	for _, j := range b.joins {
+		if b.from == "" {
+			return fmt.Errorf("query: LAST JOIN without FROM")
+		}
+		if err := j.on.check("LAST JOIN"); err != nil {
+			return err
+		}
+		sb.WriteString(" LAST JOIN ")
+		sb.WriteString(j.table)
+		if j.orderBy != "" {
+			sb.WriteString(" ORDER BY ")
+			sb.WriteString(j.orderBy)
+		}
+		sb.WriteString(" ON ")
+		sb.WriteString(j.on.sql)
+		*args = append(*args, j.on.args...)
+	}
+
+	if err := writeConditions(sb, args, "WHERE", b.where); err != nil {
+		return err
+	}
+	if len(b.groupBy) > 0 {
+		sb.WriteString(" GROUP BY ")
+		sb.WriteString(strings.Join(b.groupBy, ", "))
+	}
+	if err := writeConditions(sb, args, "HAVING", b.having); err != nil {
+		return err
+	}
+
+	for i, w := range b.windows {
+		if i == 0 {
+			sb.WriteString(" WINDOW ")
+		} else {
+			sb.WriteString(", ")
+		}
+		if err := w.build(sb); err != nil {
+			return err
+		}
+	}
+
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(b.orderBy, ", "))
+	}
+	if b.limit >= 0 {
+		fmt.Fprintf(sb, " LIMIT %d", b.limit)
+	}
+	return nil
+}
+
+// writeConditions writes conditions ANDed, parenthesized if more than one
+func writeConditions(sb *strings.Builder, args *[]any, clause string, conds []fragment) error {
+	for i, c := range conds {
+		if err := c.check(clause); err != nil {
+			return err
+		}
+		if i == 0 {
+			sb.WriteString(" " + clause + " ")
+		} else {
+			sb.WriteString(" AND ")
+		}
+		if len(conds) > 1 {
+			sb.WriteString("(" + c.sql + ")")
+		} else {
+			sb.WriteString(c.sql)
+		}
+		*args = append(*args, c.args...)
+	}
+	return nil
+}
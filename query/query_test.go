@@ -0,0 +1,155 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+func TestBuild(t *testing.T) {
+	q := Select("t1.c1", "sum(t1.c2) OVER w1 AS s", "count(t1.c2) OVER w2 AS n", "t2.c3").
+		From("t1").
+		LastJoinOrderBy("t2", "t2.ts", "t1.c1 = t2.c1 AND t2.c3 > ?", 10).
+		Where("t1.c1 = ?", "a").
+		Where("t1.c2 > ? OR t1.c2 < ?", 1, 100).
+		Window(
+			NewWindow("w1").Union("t3").PartitionBy("c1").OrderBy("ts").
+				RowsRange(Preceding("3d"), CurrentRow).MaxSize(100).ExcludeCurrentRow().ExcludeCurrentTime(),
+			NewWindow("w2").Union("t3", "t4").PartitionBy("c1", "c2").OrderBy("ts").
+				Rows(OpenPreceding("10"), CurrentRow).InstanceNotInWindow(),
+		).
+		Limit(10)
+	sql, args, err := q.Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT t1.c1, sum(t1.c2) OVER w1 AS s, count(t1.c2) OVER w2 AS n, t2.c3 FROM t1 "+
+		"LAST JOIN t2 ORDER BY t2.ts ON t1.c1 = t2.c1 AND t2.c3 > ? "+
+		"WHERE (t1.c1 = ?) AND (t1.c2 > ? OR t1.c2 < ?) "+
+		"WINDOW w1 AS (UNION t3 PARTITION BY c1 ORDER BY ts ROWS_RANGE BETWEEN 3d PRECEDING AND CURRENT ROW "+
+		"MAXSIZE 100 EXCLUDE CURRENT_ROW EXCLUDE CURRENT_TIME), "+
+		"w2 AS (UNION (t3, t4) PARTITION BY c1, c2 ORDER BY ts ROWS BETWEEN 10 OPEN PRECEDING AND CURRENT ROW "+
+		"INSTANCE_NOT_IN_WINDOW) LIMIT 10", sql)
+	assert.Equal(t, []any{10, "a", 1, 100}, args)
+
+	stmt, err := sqlparse.Classify(sql)
+	require.NoError(t, err)
+	assert.Equal(t, sqlparse.KindDQL, stmt.Kind)
+	assert.Equal(t, []string{"t1", "t2", "t3", "t4"}, stmt.Tables)
+	assert.Equal(t, 4, stmt.Placeholders)
+
+	sql, args, err = Select("c1", "count(*) AS n").
+		FromQuery(Select("c1").From("t1").Where("c2 = ?", 1), "sub").
+		GroupBy("c1").
+		Having("count(*) > ?", 2).
+		OrderBy("n DESC").
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT c1, count(*) AS n FROM (SELECT c1 FROM t1 WHERE c2 = ?) AS sub "+
+		"GROUP BY c1 HAVING count(*) > ? ORDER BY n DESC", sql)
+	assert.Equal(t, []any{1, 2}, args)
+}
+
+func TestBuildErrors(t *testing.T) {
+	for _, c := range []struct {
+		q   *SelectBuilder
+		err string
+	}{
+		{Select(), "no columns selected"},
+		{Select("c1").From("t1").Where("c1 = ? AND c2 = ?", 1), "2 placeholders but 1 arguments"},
+		{Select("c1").From("t1").Where("c1 = '?'", 1), "0 placeholders but 1 arguments"},
+		{Select("c1").From("t1").LastJoin("t2", "t1.c1 = ?"), "LAST JOIN \"t1.c1 = ?\": 1 placeholders but 0 arguments"},
+		{Select("c1").From("t1").Where("c1 = 'a"), "unterminated"},
+		{Select("c1").LastJoin("t2", "t1.c1 = t2.c1"), "LAST JOIN without FROM"},
+		{Select("c1").From("t1").Limit(-1), "invalid limit -1"},
+		{Select("c1").FromQuery(Select("c1").From("t1"), "a b"), "invalid alias"},
+		{Select("c1").From("t1").Window(NewWindow("w 1")), "invalid window name"},
+		{Select("c1").From("t1").Window(NewWindow("w").PartitionBy("c1").OrderBy("ts")), "frame required"},
+		{Select("c1").From("t1").Window(NewWindow("w").PartitionBy("c1").OrderBy("ts").
+			Rows(Preceding("3d"), CurrentRow)), "invalid frame offset \"3d\""},
+		{Select("c1").From("t1").Window(NewWindow("w").PartitionBy("c1").OrderBy("ts").
+			Rows(Preceding("3"), CurrentRow).MaxSize(10)), "MAXSIZE of ROWS frame"},
+		{Select("c1").From("t1").Window(NewWindow("w").PartitionBy("c1").OrderBy("ts").
+			RowsRange(Preceding("1; DROP TABLE t1"), CurrentRow)), "invalid frame offset"},
+		{Select("c1").From("t1").Window(NewWindow("w").PartitionBy("c1").OrderBy("ts").
+			RowsRange(CurrentRow, UnboundedPreceding)), "frame end UNBOUNDED PRECEDING"},
+	} {
+		_, _, err := c.q.Build()
+		assert.ErrorContains(t, err, c.err)
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	q := Select("c1", "sum(c2) OVER w AS s").From("t1").
+		Window(NewWindow("w").PartitionBy("c1").OrderBy("ts").RowsRange(Preceding("1d"), CurrentRow))
+	sql, err := Deploy("d1", q).IfNotExists().Option("long_windows", "w:1d").Option("skip_index_check", "true").Build()
+	require.NoError(t, err)
+	assert.Equal(t, "DEPLOY IF NOT EXISTS d1 OPTIONS(LONG_WINDOWS='w:1d', SKIP_INDEX_CHECK='true') "+
+		"SELECT c1, sum(c2) OVER w AS s FROM t1 "+
+		"WINDOW w AS (PARTITION BY c1 ORDER BY ts ROWS_RANGE BETWEEN 1d PRECEDING AND CURRENT ROW)", sql)
+	stmt, err := sqlparse.Classify(sql)
+	require.NoError(t, err)
+	assert.Equal(t, sqlparse.KindDeploy, stmt.Kind)
+
+	_, err = Deploy("d1", Select("c1").From("t1").Where("c1 = ?", 1)).Build()
+	assert.ErrorContains(t, err, "deployed query has 1 arguments")
+	_, err = Deploy("d-1", q).Build()
+	assert.ErrorContains(t, err, "invalid deployment name")
+	_, err = Deploy("d1", q).Option("a b", "c").Build()
+	assert.ErrorContains(t, err, "invalid option")
+}
+
+func TestQueryEmulator(t *testing.T) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	db, err := sql.Open("openmldb", srv.DSN("test_db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+
+	for _, s := range []string{
+		"CREATE TABLE t1 (c1 string, c2 int, ts timestamp, INDEX(KEY=c1, TS=ts))",
+		"CREATE TABLE t2 (c1 string, c3 int, ts timestamp, INDEX(KEY=c1, TS=ts))",
+		"INSERT INTO t1 VALUES ('a', 1, 1000), ('a', 2, 2000), ('b', 3, 3000)",
+		"INSERT INTO t2 VALUES ('a', 10, 1000), ('a', 20, 2000)",
+	} {
+		_, err := db.ExecContext(ctx, s)
+		require.NoError(t, err)
+	}
+
+	q := Select("t1.c1", "t1.c2", "sum(t1.c2) OVER w AS s", "t2.c3").
+		From("t1").
+		LastJoinOrderBy("t2", "t2.ts", "t1.c1 = t2.c1").
+		Where("t1.c1 = ?", "a").
+		Window(NewWindow("w").PartitionBy("t1.c1").OrderBy("t1.ts").RowsRange(Preceding("1s"), CurrentRow))
+	sql, args, err := q.Build()
+	require.NoError(t, err)
+	rows, err := db.QueryContext(ctx, sql, args...)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	type row struct {
+		c1        string
+		c2, s, c3 int32
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		require.NoError(t, rows.Scan(&r.c1, &r.c2, &r.s, &r.c3))
+		got = append(got, r)
+	}
+	require.NoError(t, rows.Err())
+	assert.ElementsMatch(t, []row{{"a", 1, 1, 20}, {"a", 2, 3, 20}}, got)
+
+	deploy, err := Deploy("d1", Select("c1", "sum(c2) OVER w AS s").From("t1").
+		Window(NewWindow("w").PartitionBy("c1").OrderBy("ts").RowsRange(Preceding("1d"), CurrentRow))).
+		Option("long_windows", "w:1d").Build()
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, deploy)
+	require.NoError(t, err)
+}
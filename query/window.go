@@ -0,0 +1,181 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Bound is a bound of window frame.
+type Bound struct {
+	offset    string
+	open      bool
+	unbounded bool
+	current   bool
+}
+
+var (
+	// UnboundedPreceding is the first row of partition.
+	UnboundedPreceding = Bound{unbounded: true}
+	// CurrentRow is the current row.
+	CurrentRow = Bound{current: true}
+)
+
+// Preceding is the bound offset before the current row, in rows for ROWS, or in milliseconds
+// or with a unit of s, m, h or d for ROWS_RANGE, e.g. "3d".
+func Preceding(offset string) Bound {
+	return Bound{offset: offset}
+}
+
+// OpenPreceding is the open bound offset before the current row, the row at offset excluded.
+func OpenPreceding(offset string) Bound {
+	return Bound{offset: offset, open: true}
+}
+
+var (
+	rowsOffset  = regexp.MustCompile(`^\d+$`)
+	rangeOffset = regexp.MustCompile(`^\d+[smhd]?$`)
+)
+
+func (bd Bound) build(rowsRange bool) (string, error) {
+	switch {
+	case bd.unbounded:
+		return "UNBOUNDED PRECEDING", nil
+	case bd.current:
+		return "CURRENT ROW", nil
+	}
+	valid := rowsOffset.MatchString(bd.offset)
+	if rowsRange {
+		valid = rangeOffset.MatchString(bd.offset)
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid frame offset %q", bd.offset)
+	}
+	if bd.open {
+		return bd.offset + " OPEN PRECEDING", nil
+	}
+	return bd.offset + " PRECEDING", nil
+}
+
+// WindowBuilder builds a named window of SELECT. Methods modify and return the builder.
+type WindowBuilder struct {
+	name        string
+	union       []string
+	partitionBy []string
+	orderBy     string
+	frame       string // ROWS or ROWS_RANGE
+	start, end  Bound
+	maxSize     int
+	excludeRow  bool
+	excludeTime bool
+	notInWindow bool
+}
+
+// NewWindow starts a window named name, referred by OVER name in columns of SELECT.
+func NewWindow(name string) *WindowBuilder {
+	return &WindowBuilder{name: name}
+}
+
+// Union adds tables whose rows are unioned into the window, but not to the result.
+func (w *WindowBuilder) Union(tables ...string) *WindowBuilder {
+	w.union = append(w.union, tables...)
+	return w
+}
+
+// PartitionBy adds PARTITION BY expressions.
+func (w *WindowBuilder) PartitionBy(exprs ...string) *WindowBuilder {
+	w.partitionBy = append(w.partitionBy, exprs...)
+	return w
+}
+
+// OrderBy sets ORDER BY expression, usually the timestamp column.
+func (w *WindowBuilder) OrderBy(expr string) *WindowBuilder {
+	w.orderBy = expr
+	return w
+}
+
+// Rows sets the frame in number of rows, e.g. Rows(Preceding("10"), CurrentRow).
+func (w *WindowBuilder) Rows(start, end Bound) *WindowBuilder {
+	w.frame, w.start, w.end = "ROWS", start, end
+	return w
+}
+
+// RowsRange sets the frame in range of ORDER BY, e.g. RowsRange(Preceding("3d"), CurrentRow).
+func (w *WindowBuilder) RowsRange(start, end Bound) *WindowBuilder {
+	w.frame, w.start, w.end = "ROWS_RANGE", start, end
+	return w
+}
+
+// MaxSize limits the number of rows of ROWS_RANGE frame.
+func (w *WindowBuilder) MaxSize(n int) *WindowBuilder {
+	w.maxSize = n
+	return w
+}
+
+// ExcludeCurrentRow excludes the current row from the window.
+func (w *WindowBuilder) ExcludeCurrentRow() *WindowBuilder {
+	w.excludeRow = true
+	return w
+}
+
+// ExcludeCurrentTime excludes the other rows of the same time as the current row.
+func (w *WindowBuilder) ExcludeCurrentTime() *WindowBuilder {
+	w.excludeTime = true
+	return w
+}
+
+// InstanceNotInWindow excludes the rows of the main table except the current row, so the
+// window consists of rows of Union tables.
+func (w *WindowBuilder) InstanceNotInWindow() *WindowBuilder {
+	w.notInWindow = true
+	return w
+}
+
+func (w *WindowBuilder) build(sb *strings.Builder) error {
+	if !plainIdent.MatchString(w.name) {
+		return fmt.Errorf("query: invalid window name %q", w.name)
+	}
+	if len(w.partitionBy) == 0 || w.orderBy == "" || w.frame == "" {
+		return fmt.Errorf("query: window %s: PARTITION BY, ORDER BY and frame required", w.name)
+	}
+	rowsRange := w.frame == "ROWS_RANGE"
+	if w.maxSize != 0 && !rowsRange {
+		return fmt.Errorf("query: window %s: MAXSIZE of ROWS frame", w.name)
+	}
+	if w.end.unbounded {
+		return fmt.Errorf("query: window %s: frame end UNBOUNDED PRECEDING", w.name)
+	}
+	start, err := w.start.build(rowsRange)
+	if err != nil {
+		return fmt.Errorf("query: window %s: %w", w.name, err)
+	}
+	end, err := w.end.build(rowsRange)
+	if err != nil {
+		return fmt.Errorf("query: window %s: %w", w.name, err)
+	}
+
+	fmt.Fprintf(sb, "%s AS (", w.name)
+	switch len(w.union) {
+	case 0:
+	case 1:
+		sb.WriteString("UNION " + w.union[0] + " ")
+	default:
+		sb.WriteString("UNION (" + strings.Join(w.union, ", ") + ") ")
+	}
+	fmt.Fprintf(sb, "PARTITION BY %s ORDER BY %s %s BETWEEN %s AND %s",
+		strings.Join(w.partitionBy, ", "), w.orderBy, w.frame, start, end)
+	if w.maxSize != 0 {
+		fmt.Fprintf(sb, " MAXSIZE %d", w.maxSize)
+	}
+	if w.excludeRow {
+		sb.WriteString(" EXCLUDE CURRENT_ROW")
+	}
+	if w.excludeTime {
+		sb.WriteString(" EXCLUDE CURRENT_TIME")
+	}
+	if w.notInWindow {
+		sb.WriteString(" INSTANCE_NOT_IN_WINDOW")
+	}
+	sb.WriteString(")")
+	return nil
+}
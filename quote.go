@@ -0,0 +1,215 @@
+package openmldb
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+// QuoteIdentifier quotes name in backticks as a single identifier, e.g. a table or column
+// name. Names qualified by database are quoted separately, e.g. QuoteIdentifier(db) + "." +
+// QuoteIdentifier(table).
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteLiteral quotes s as SQL string literal, with backslash, quote and line breaks escaped.
+func QuoteLiteral(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case 0:
+			b.WriteString(`\0`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// Interpolate replaces the `?` placeholders of sql with args as SQL literals, for statements
+// which don't take parameters, e.g. DDL, DEPLOY or LOAD DATA. Values are formatted as they
+// are sent by parameters of queries: time.Time as timestamp in milliseconds, NullDate as date
+// string, maps and slices as MAP(k, v, ...) and ARRAY[...], invalid Null[T] or nil as NULL,
+// and driver.Valuer by its value. Dates are formatted in their own location, as Interpolate
+// has no Config.Loc, convert them beforehand to format them in another.
+func Interpolate(sql string, args ...any) (string, error) {
+	toks, err := sqlparse.Lex(sql)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		b    strings.Builder
+		last int
+		n    int
+	)
+	for _, t := range toks {
+		if t.Kind != sqlparse.Placeholder {
+			continue
+		}
+		if n >= len(args) {
+			return "", fmt.Errorf("interpolate: not enough arguments for placeholder at %d", t.Pos)
+		}
+		lit, err := literal(args[n])
+		if err != nil {
+			return "", fmt.Errorf("interpolate: argument %d: %w", n, err)
+		}
+		b.WriteString(sql[last:t.Pos])
+		b.WriteString(lit)
+		last = t.End
+		n++
+	}
+	if n < len(args) {
+		return "", fmt.Errorf("interpolate: %d arguments but %d placeholders", len(args), n)
+	}
+	b.WriteString(sql[last:])
+	return b.String(), nil
+}
+
+// literal formats v as SQL literal, negative numbers are parenthesized to not form a comment
+// after a minus
+func literal(v any) (string, error) {
+	var s string
+	switch vv := v.(type) {
+	case nil:
+		return "NULL", nil
+	case NullDate:
+		if !vv.Valid {
+			return "NULL", nil
+		}
+		return QuoteLiteral(vv.V.Format(time.DateOnly)), nil
+	case bool:
+		return strconv.FormatBool(vv), nil
+	case int:
+		s = strconv.FormatInt(int64(vv), 10)
+	case int8:
+		s = strconv.FormatInt(int64(vv), 10)
+	case int16:
+		s = strconv.FormatInt(int64(vv), 10)
+	case int32:
+		s = strconv.FormatInt(int64(vv), 10)
+	case int64:
+		s = strconv.FormatInt(vv, 10)
+	case uint:
+		s = strconv.FormatUint(uint64(vv), 10)
+	case uint8:
+		s = strconv.FormatUint(uint64(vv), 10)
+	case uint16:
+		s = strconv.FormatUint(uint64(vv), 10)
+	case uint32:
+		s = strconv.FormatUint(uint64(vv), 10)
+	case uint64:
+		s = strconv.FormatUint(vv, 10)
+	case float32:
+		if math.IsNaN(float64(vv)) || math.IsInf(float64(vv), 0) {
+			return "", fmt.Errorf("unsupported float %v", vv)
+		}
+		s = strconv.FormatFloat(float64(vv), 'g', -1, 32)
+	case float64:
+		if math.IsNaN(vv) || math.IsInf(vv, 0) {
+			return "", fmt.Errorf("unsupported float %v", vv)
+		}
+		s = strconv.FormatFloat(vv, 'g', -1, 64)
+	case string:
+		return QuoteLiteral(vv), nil
+	case []byte:
+		if vv == nil {
+			return "NULL", nil
+		}
+		return QuoteLiteral(string(vv)), nil
+	case time.Time:
+		s = strconv.FormatInt(vv.UnixMilli(), 10)
+	case composite:
+		// NullMap and NullArray
+		t, cv, valid := vv.composite()
+		if t == nil {
+			return "", fmt.Errorf("unsupported type %T", v)
+		}
+		if !valid {
+			return "NULL", nil
+		}
+		return compositeLiteral(t, cv)
+	case driver.Valuer:
+		// Null[T] and sql.NullXXX
+		dv, err := vv.Value()
+		if err != nil {
+			return "", err
+		}
+		if _, ok := dv.(driver.Valuer); ok {
+			return "", fmt.Errorf("unsupported type %T", v)
+		}
+		return literal(dv)
+	default:
+		if t, ok := compositeType(reflect.TypeOf(v)); ok {
+			return compositeLiteral(t, reflect.ValueOf(v))
+		}
+		return "", fmt.Errorf("unsupported type %T", v)
+	}
+	if strings.HasPrefix(s, "-") {
+		return "(" + s + ")", nil
+	}
+	return s, nil
+}
+
+// compositeLiteral formats v of MAP or ARRAY type t as MAP(k1, v1, ...) or ARRAY[e1, ...],
+// with keys of map sorted so that a map is always formatted the same
+func compositeLiteral(t *dataType, v reflect.Value) (string, error) {
+	if v.IsNil() {
+		return "NULL", nil
+	}
+
+	elem := func(t *dataType, v reflect.Value) (string, error) {
+		if t.scalar() {
+			return literal(v.Interface())
+		}
+		return compositeLiteral(t, v)
+	}
+	if t.name == "array" {
+		elems := make([]string, v.Len())
+		for i := range elems {
+			var err error
+			if elems[i], err = elem(t.elem, v.Index(i)); err != nil {
+				return "", err
+			}
+		}
+		return "ARRAY[" + strings.Join(elems, ", ") + "]", nil
+	}
+
+	pairs := make([][2]string, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k, err := elem(t.key, iter.Key())
+		if err != nil {
+			return "", err
+		}
+		e, err := elem(t.elem, iter.Value())
+		if err != nil {
+			return "", err
+		}
+		pairs = append(pairs, [2]string{k, e})
+	}
+	slices.SortFunc(pairs, func(a, b [2]string) int { return strings.Compare(a[0], b[0]) })
+	elems := make([]string, 0, 2*len(pairs))
+	for _, p := range pairs {
+		elems = append(elems, p[0], p[1])
+	}
+	return "MAP(" + strings.Join(elems, ", ") + ")", nil
+}
@@ -0,0 +1,187 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "`t1`", QuoteIdentifier("t1"))
+	assert.Equal(t, "`a``b`", QuoteIdentifier("a`b"))
+	assert.Equal(t, "`db.t`", QuoteIdentifier("db.t"))
+
+	assert.Equal(t, "''", QuoteLiteral(""))
+	assert.Equal(t, `'it\'s'`, QuoteLiteral("it's"))
+	assert.Equal(t, `'a\\'`, QuoteLiteral(`a\`))
+	assert.Equal(t, `'"\n\r\0'`, QuoteLiteral("\"\n\r\x00"))
+}
+
+func TestInterpolate(t *testing.T) {
+	ts := time.UnixMilli(1700000000123)
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	s, err := Interpolate("INSERT INTO t VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '?', `?`) -- ?",
+		true, int16(-1), int32(2), int64(3), float32(1.5), 2.25, "a'b", ts,
+		NullDate{sql.Null[time.Time]{V: date, Valid: true}}, Null[int32]{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `INSERT INTO t VALUES (true, (-1), 2, 3, 1.5, 2.25, 'a\'b', 1700000000123, '2024-01-02', NULL, NULL, '?', `+
+		"`?`) -- ?", s)
+
+	s, err = Interpolate("SELECT 1-?", -5)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 1-(-5)", s)
+
+	s, err = Interpolate("SELECT ?, ?", Null[string]{sql.Null[string]{V: "x", Valid: true}}, sql.NullInt64{Int64: 7, Valid: true})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 'x', 7", s)
+
+	s, err = Interpolate("SELECT ?, ?, ?, ?, ?, ?",
+		map[string]int32{"b": -2, "a": 1}, []string{"x", "y'"}, map[int64][]time.Time{1: {ts}},
+		NullArray[int64]{sql.Null[[]int64]{V: []int64{}, Valid: true}}, NullMap[string, string]{}, tags(nil))
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT MAP('a', 1, 'b', (-2)), ARRAY['x', 'y\''], MAP(1, ARRAY[1700000000123]), ARRAY[], NULL, ''`, s)
+
+	_, err = Interpolate("SELECT ?, ?", 1)
+	assert.ErrorContains(t, err, "not enough arguments for placeholder at 10")
+	_, err = Interpolate("SELECT ?", 1, 2)
+	assert.ErrorContains(t, err, "2 arguments but 1 placeholders")
+	_, err = Interpolate("SELECT ?", math.NaN())
+	assert.ErrorContains(t, err, "argument 0: unsupported float NaN")
+	_, err = Interpolate("SELECT ?", struct{}{})
+	assert.ErrorContains(t, err, "unsupported type struct {}")
+	_, err = Interpolate("SELECT ?", []uint{1})
+	assert.ErrorContains(t, err, "unsupported type []uint")
+	_, err = Interpolate("SELECT '?", 1)
+	assert.Error(t, err)
+}
+
+func TestInterpolateEmulator(t *testing.T) {
+	db := openHookedDB(t)
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int, ts timestamp, dt date, INDEX(KEY=c1, TS=ts))")
+	require.NoError(t, err)
+
+	value := "it's a \\ 'quoted'\n value; DROP TABLE t1 --"
+	ts := time.UnixMilli(1700000000123)
+	insert, err := Interpolate("INSERT INTO t1 VALUES (?, ?, ?, ?)", value, int32(-3), ts,
+		NullDate{sql.Null[time.Time]{V: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Valid: true}})
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, insert)
+	require.NoError(t, err)
+
+	var (
+		c1 string
+		c2 int32
+		at time.Time
+		dt time.Time
+	)
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT c1, c2, ts, dt FROM t1").Scan(&c1, &c2, &at, &dt))
+	assert.Equal(t, value, c1)
+	assert.Equal(t, int32(-3), c2)
+	assert.Equal(t, ts.UnixMilli(), at.UnixMilli())
+	assert.Equal(t, "2024-01-02", dt.Format(time.DateOnly))
+}
+
+func FuzzQuoteLiteral(f *testing.F) {
+	for _, s := range []string{"", "a", "it's", `\`, `\'`, "''", "\n\r\x00", "`", "?", "--", "/*", "'; DROP TABLE t --"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		toks, err := sqlparse.Lex("SELECT " + QuoteLiteral(s) + ", " + QuoteIdentifier(s) + " FROM t")
+		require.NoError(t, err)
+		require.Len(t, toks, 7)
+		assert.Equal(t, sqlparse.String, toks[1].Kind)
+		assert.Equal(t, s, toks[1].Value)
+		assert.Equal(t, sqlparse.QuotedIdent, toks[3].Kind)
+		assert.Equal(t, s, toks[3].Value)
+		assert.True(t, toks[4].Is("FROM"))
+	})
+}
+
+func FuzzInterpolate(f *testing.F) {
+	f.Add("a", int64(1), 1.5, true)
+	f.Add("'", int64(-1), -0.5, false)
+	f.Add(`\`, int64(math.MinInt64), 1e300, true)
+	f.Fuzz(func(t *testing.T, s string, n int64, x float64, b bool) {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			t.Skip()
+		}
+		sql, err := Interpolate("SELECT ?-?, ?-?, ? FROM t WHERE c = ?", s, n, n, x, b, s)
+		require.NoError(t, err)
+
+		stmt, err := sqlparse.Classify(sql)
+		require.NoError(t, err)
+		assert.Equal(t, sqlparse.KindDQL, stmt.Kind)
+		assert.Equal(t, []string{"t"}, stmt.Tables)
+		assert.Zero(t, stmt.Placeholders)
+
+		var strs []string
+		for _, tok := range stmt.Tokens {
+			if tok.Kind == sqlparse.String {
+				strs = append(strs, tok.Value)
+			}
+		}
+		assert.Equal(t, []string{s, s}, strs)
+	})
+}
+
+// sqlHook records SQL and number of arguments of requests
+type sqlHook struct {
+	sqls *[]string
+}
+
+func (h sqlHook) Before(ctx context.Context, req *Request) (context.Context, *Result, error) {
+	*h.sqls = append(*h.sqls, fmt.Sprintf("%s [%d]", req.SQL, len(req.Args)))
+	return ctx, nil, nil
+}
+
+func (h sqlHook) After(context.Context, *Request, *Result, error) {}
+
+func TestInterpolateParams(t *testing.T) {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+
+	open := func(dsn string) (*sql.DB, *[]string) {
+		cfg, err := ParseDSN(dsn)
+		require.NoError(t, err)
+		var sqls []string
+		cfg.Hooks = []Hook{sqlHook{&sqls}}
+		connector, err := NewConnector(cfg)
+		require.NoError(t, err)
+		db := sql.OpenDB(connector)
+		t.Cleanup(func() { db.Close() })
+		return db, &sqls
+	}
+
+	db, sqls := open(srv.DSN("test_db") + "?interpolateParams=auto")
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int DEFAULT ?, ts timestamp, INDEX(KEY=c1, TS=ts))", 7)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 (c1, ts) VALUES (?, ?)", "a", time.UnixMilli(1000))
+	require.NoError(t, err)
+	var c2 int32
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT c2 FROM t1 WHERE c1 = ?", "a").Scan(&c2))
+	assert.Equal(t, int32(7), c2)
+	assert.Equal(t, []string{
+		"CREATE TABLE t1 (c1 string, c2 int DEFAULT 7, ts timestamp, INDEX(KEY=c1, TS=ts)) [0]",
+		"INSERT INTO t1 (c1, ts) VALUES (?, ?) [2]",
+		"SELECT c2 FROM t1 WHERE c1 = ? [1]",
+	}, (*sqls)[len(*sqls)-3:])
+
+	db, sqls = open(srv.DSN("test_db") + "?interpolateParams=true")
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT c2 FROM t1 WHERE c1 = ?", "a").Scan(&c2))
+	assert.Equal(t, int32(7), c2)
+	assert.Equal(t, "SELECT c2 FROM t1 WHERE c1 = 'a' [0]", (*sqls)[len(*sqls)-1])
+
+	_, err = db.ExecContext(ctx, "SELECT c2 FROM t1 WHERE c1 = ?", struct{}{})
+	assert.Error(t, err)
+}
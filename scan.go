@@ -0,0 +1,292 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScanOption configures how result columns are mapped to struct fields.
+type ScanOption func(*scanOptions)
+
+type scanOptions struct {
+	// lenient discards result columns that has no matching struct field,
+	// instead of returning an error
+	lenient bool
+}
+
+// Strict reports an error if a result column can not be mapped to any struct field.
+// This is the default behavior.
+func Strict() ScanOption {
+	return func(o *scanOptions) { o.lenient = false }
+}
+
+// Lenient silently discards result columns that can not be mapped to any struct field.
+func Lenient() ScanOption {
+	return func(o *scanOptions) { o.lenient = true }
+}
+
+// QueryStructs executes the query and scans all result rows into a slice of T.
+//
+// See ScanAll for how columns are mapped to fields of T.
+func QueryStructs[T any](ctx context.Context, db *sql.DB, query string, args ...any) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ScanAll[T](rows)
+}
+
+// ScanAll scans all remaining rows into a slice of T, and closes rows.
+//
+// If T is a struct type, a column maps to the exported field whose `openmldb` tag
+// equals the column name, or otherwise the field whose name equals the column name
+// case-insensitively. Fields tagged `openmldb:"-"` are ignored, and fields of embedded
+// structs are promoted as if they were declared in T. Options after a comma in the tag,
+// used by CreateTableFromStruct, are ignored.
+//
+// API server does not report column names for SQL queries, in that case columns
+// are mapped to the fields by declaration order.
+//
+// Any field type that is scannable by database/sql works, including Null[T] and NullDate.
+// Other types of T, e.g. int32 or Null[string], are scanned from the single column of result.
+func ScanAll[T any](rows *sql.Rows, opts ...ScanOption) ([]T, error) {
+	defer rows.Close()
+
+	s, err := newRowScanner[T](rows, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []T
+	for rows.Next() {
+		var v T
+		if err := s.scan(rows, &v); err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ScanOne scans the first row into T, and closes rows.
+//
+// sql.ErrNoRows returned if there is no row. See ScanAll for how columns are mapped to fields of T.
+func ScanOne[T any](rows *sql.Rows, opts ...ScanOption) (T, error) {
+	defer rows.Close()
+
+	var v T
+	s, err := newRowScanner[T](rows, opts...)
+	if err != nil {
+		return v, err
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return v, err
+		}
+		return v, sql.ErrNoRows
+	}
+	if err := s.scan(rows, &v); err != nil {
+		return v, err
+	}
+	return v, rows.Close()
+}
+
+// rowScanner scans rows into values of T
+type rowScanner[T any] struct {
+	// field index path in T for each column, nil if the column is discarded,
+	// empty if the column scanned into T itself
+	targets [][]int
+}
+
+func newRowScanner[T any](rows *sql.Rows, opts ...ScanOption) (*rowScanner[T], error) {
+	var o scanOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeFor[T]()
+	if !isStructRow(t) {
+		// scan single column into T
+		if len(cols) == 0 || (len(cols) > 1 && !o.lenient) {
+			return nil, fmt.Errorf("%d columns in result but scan into single value of %s", len(cols), t)
+		}
+		targets := make([][]int, len(cols))
+		targets[0] = []int{}
+		return &rowScanner[T]{targets: targets}, nil
+	}
+
+	fields, err := structFieldsOf(t)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := fields.bind(cols, o.lenient)
+	if err != nil {
+		return nil, err
+	}
+	return &rowScanner[T]{targets: targets}, nil
+}
+
+func (s *rowScanner[T]) scan(rows *sql.Rows, dst *T) error {
+	v := reflect.ValueOf(dst).Elem()
+	dest := make([]any, len(s.targets))
+	for i, index := range s.targets {
+		if index == nil {
+			dest[i] = new(any)
+			continue
+		}
+		dest[i] = fieldByIndexAlloc(v, index).Addr().Interface()
+	}
+	return rows.Scan(dest...)
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, except that nil pointers
+// to embedded structs are allocated
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+type structField struct {
+	name  string // tag name or field name
+	index []int
+}
+
+// structFields are the mappable fields of a struct type, in declaration order
+type structFields []structField
+
+var structFieldsCache sync.Map // reflect.Type -> structFields
+
+func structFieldsOf(t reflect.Type) (structFields, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("scan into non-struct type %s", t)
+	}
+
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.(structFields), nil
+	}
+
+	var fields structFields
+	collectStructFields(t, nil, &fields)
+	structFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+var (
+	scannerType = reflect.TypeFor[sql.Scanner]()
+	timeType    = reflect.TypeFor[time.Time]()
+)
+
+// isStructRow reports whether t is a struct that a row scanned into field by field
+func isStructRow(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
+
+func collectStructFields(t reflect.Type, parent []int, fields *structFields) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("openmldb")
+		if tag == "-" {
+			continue
+		}
+
+		index := append(append([]int(nil), parent...), i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && !hasTag && isStructRow(ft) {
+			// promote fields of embedded struct, even if it is unexported.
+			// unexported embedded pointer is not settable, so skipped
+			if f.IsExported() || f.Type.Kind() != reflect.Pointer {
+				collectStructFields(ft, index, fields)
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tagName, _, _ := strings.Cut(tag, ","); tagName != "" {
+			name = tagName
+		}
+		*fields = append(*fields, structField{name: name, index: index})
+	}
+}
+
+// bind resolves the field for each column
+func (fs structFields) bind(cols []string, lenient bool) ([][]int, error) {
+	targets := make([][]int, len(cols))
+
+	named := false
+	for _, c := range cols {
+		if c != "" {
+			named = true
+			break
+		}
+	}
+
+	if !named {
+		// column names unknown, map by position
+		if len(cols) > len(fs) && !lenient {
+			return nil, fmt.Errorf("%d columns in result but only %d fields to scan into", len(cols), len(fs))
+		}
+		for i := range cols {
+			if i < len(fs) {
+				targets[i] = fs[i].index
+			}
+		}
+		return targets, nil
+	}
+
+	for i, c := range cols {
+		f := fs.lookup(c)
+		if f == nil {
+			if lenient {
+				continue
+			}
+			return nil, fmt.Errorf("no field to scan column '%s' at index %d into", c, i)
+		}
+		targets[i] = f.index
+	}
+	return targets, nil
+}
+
+func (fs structFields) lookup(col string) *structField {
+	for i := range fs {
+		if fs[i].name == col {
+			return &fs[i]
+		}
+	}
+	for i := range fs {
+		if strings.EqualFold(fs[i].name, col) {
+			return &fs[i]
+		}
+	}
+	return nil
+}
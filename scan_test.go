@@ -0,0 +1,121 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDB(t *testing.T, handler http.HandlerFunc) *sql.DB {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	db, err := sql.Open("openmldb", fmt.Sprintf("openmldb://%s/test_db", strings.TrimPrefix(srv.URL, "http://")))
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func respondWith(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}
+}
+
+type scanBase struct {
+	C1 int32
+}
+
+type scanDemo struct {
+	scanBase
+	C2      Null[string]
+	TS      Null[time.Time] `openmldb:"ts"`
+	DT      NullDate
+	Ignored string `openmldb:"-"`
+}
+
+func TestQueryStructs(t *testing.T) {
+	db := newTestDB(t, respondWith(`{
+		"code": 0,
+		"msg": "ok",
+		"data": {
+			"schema": ["Int32", "String", "Timestamp", "Date"],
+			"data": [[1, "bb", 3000, "2022-12-12"], [2, null, null, null]]
+		}
+	}`))
+
+	result, err := QueryStructs[scanDemo](context.Background(), db, "SELECT * FROM demo")
+	assert.NoError(t, err)
+	assert.Equal(t, []scanDemo{
+		{
+			scanBase: scanBase{1},
+			C2:       Null[string]{Null: sql.Null[string]{V: "bb", Valid: true}},
+			TS:       Null[time.Time]{Null: sql.Null[time.Time]{V: time.UnixMilli(3000), Valid: true}},
+			DT:       NullDate{Null: sql.Null[time.Time]{V: time.Date(2022, time.December, 12, 0, 0, 0, 0, time.UTC), Valid: true}},
+		},
+		{scanBase: scanBase{2}},
+	}, result)
+}
+
+func TestScanOne(t *testing.T) {
+	db := newTestDB(t, respondWith(`{
+		"code": 0,
+		"msg": "ok",
+		"data": {
+			"schema": ["Int32", "String"],
+			"data": [[1, "bb"]]
+		}
+	}`))
+
+	{
+		rows, err := db.QueryContext(context.Background(), "SELECT c1, c2 FROM demo")
+		assert.NoError(t, err)
+		_, err = ScanOne[scanBase](rows)
+		assert.Error(t, err, "unmapped column should fail in strict mode")
+	}
+
+	{
+		rows, err := db.QueryContext(context.Background(), "SELECT c1, c2 FROM demo")
+		assert.NoError(t, err)
+		v, err := ScanOne[scanBase](rows, Lenient())
+		assert.NoError(t, err)
+		assert.Equal(t, scanBase{1}, v)
+	}
+}
+
+func TestScanOneNoRows(t *testing.T) {
+	db := newTestDB(t, respondWith(`{"code": 0, "msg": "ok", "data": {"schema": ["Int32"], "data": []}}`))
+
+	rows, err := db.QueryContext(context.Background(), "SELECT c1 FROM demo")
+	assert.NoError(t, err)
+	_, err = ScanOne[scanBase](rows)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestStructFieldsBind(t *testing.T) {
+	fields, err := structFieldsOf(reflect.TypeFor[scanDemo]())
+	assert.NoError(t, err)
+
+	{
+		targets, err := fields.bind([]string{"dt", "ts", "c1"}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]int{{3}, {2}, {0, 0}}, targets)
+	}
+	{
+		_, err := fields.bind([]string{"c1", "unknown"}, false)
+		assert.Error(t, err)
+	}
+	{
+		targets, err := fields.bind([]string{"c1", "unknown"}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]int{{0, 0}, nil}, targets)
+	}
+}
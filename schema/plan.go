@@ -0,0 +1,526 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+// ErrDestructive is returned by Apply for a Changeset with destructive changes, unless
+// WithDestructive.
+var ErrDestructive = errors.New("schema: destructive changes")
+
+// Change is a statement of Changeset.
+type Change struct {
+	SQL string
+	// Destructive is set if the change drops data, e.g. DROP INDEX.
+	Destructive bool
+	// Comment explains the change, e.g. why a deployment is re-deployed.
+	Comment string
+}
+
+// Changeset is the ordered changes from the live database to the desired one: CREATE
+// DATABASE, DROP DEPLOYMENT of deployments to re-deploy, CREATE TABLE, CREATE INDEX of new
+// and temporary indexes, DROP INDEX, CREATE INDEX of changed indexes and DROP INDEX of
+// temporary ones, then DEPLOY.
+type Changeset struct {
+	Changes []Change
+	// Warnings are differences not planned, e.g. columns of existing tables, and notes of
+	// destructive changes.
+	Warnings []string
+}
+
+// Destructive reports whether any change is destructive.
+func (cs *Changeset) Destructive() bool {
+	for _, c := range cs.Changes {
+		if c.Destructive {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the changes as SQL script, with warnings and comments.
+func (cs *Changeset) String() string {
+	var b strings.Builder
+	for _, w := range cs.Warnings {
+		fmt.Fprintf(&b, "-- WARNING: %s\n", w)
+	}
+	for _, c := range cs.Changes {
+		if c.Comment != "" {
+			fmt.Fprintf(&b, "-- %s\n", c.Comment)
+		}
+		fmt.Fprintf(&b, "%s;\n", c.SQL)
+	}
+	return b.String()
+}
+
+// Plan compares the live database of db with desired, and returns the changes to make. The
+// name of desired must be the database of DSN.
+func Plan(ctx context.Context, db *sql.DB, desired *Database) (*Changeset, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+
+	current, err := openmldb.CurrentDatabase(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if current != desired.Name {
+		// statements and the live schema are unqualified, in the database of DSN
+		return nil, fmt.Errorf("schema: database %s differs from %s of DSN", desired.Name, current)
+	}
+
+	cs := &Changeset{}
+	dbs, err := openmldb.Databases(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		liveTables []openmldb.TableInfo
+		liveDeps   []openmldb.DeploymentInfo
+	)
+	if contains(dbs, desired.Name) {
+		if liveTables, err = openmldb.DescribeTables(ctx, db); err != nil {
+			return nil, err
+		}
+		if liveDeps, err = openmldb.DescribeDeployments(ctx, db); err != nil {
+			return nil, err
+		}
+	} else {
+		cs.Changes = append(cs.Changes, Change{SQL: "CREATE DATABASE " + ident(desired.Name)})
+	}
+
+	var (
+		creates, addIndexes, dropIndexes, recreateIndexes, drops, deploys []Change
+		changed                                                           = map[string]bool{}
+	)
+	tables := map[string]*openmldb.TableInfo{}
+	for i := range liveTables {
+		tables[liveTables[i].Name] = &liveTables[i]
+	}
+	declared := map[string]bool{}
+	for _, t := range desired.Tables {
+		declared[t.Name] = true
+		live, ok := tables[t.Name]
+		if !ok {
+			creates = append(creates, Change{SQL: createTableSQL(&t)})
+			continue
+		}
+
+		cs.Warnings = append(cs.Warnings, diffTable(&t, live)...)
+		a, d, r, err := diffIndexes(&t, live)
+		if err != nil {
+			return nil, err
+		}
+		if len(a) > 0 || len(d) > 0 {
+			changed[t.Name] = true
+		}
+		addIndexes = append(addIndexes, a...)
+		dropIndexes = append(dropIndexes, d...)
+		recreateIndexes = append(recreateIndexes, r...)
+	}
+	for _, t := range liveTables {
+		if !declared[t.Name] {
+			cs.Warnings = append(cs.Warnings, fmt.Sprintf("table %s is not declared, left unchanged", t.Name))
+		}
+	}
+
+	deps := map[string]openmldb.DeploymentInfo{}
+	for _, d := range liveDeps {
+		deps[d.Name] = d
+	}
+	declared = map[string]bool{}
+	for _, d := range desired.Deployments {
+		declared[d.Name] = true
+		deploy := Change{SQL: deploySQL(&d)}
+		live, ok := deps[d.Name]
+		switch {
+		case !ok:
+		case normalizeSQL(live.SQL) != normalizeSQL(d.SQL):
+			deploy.Comment = fmt.Sprintf("re-deploy %s, query changed", d.Name)
+		case usesAny(live.SQL, changed) != "":
+			deploy.Comment = fmt.Sprintf("re-deploy %s, indexes of table %s changed", d.Name, usesAny(live.SQL, changed))
+		default:
+			continue
+		}
+		if ok {
+			drops = append(drops, Change{SQL: "DROP DEPLOYMENT " + ident(d.Name), Comment: deploy.Comment})
+		}
+		deploys = append(deploys, deploy)
+	}
+	for _, d := range liveDeps {
+		if declared[d.Name] {
+			continue
+		}
+		msg := fmt.Sprintf("deployment %s is not declared, left unchanged", d.Name)
+		if t := usesAny(d.SQL, changed); t != "" {
+			msg += fmt.Sprintf(", but indexes of table %s it uses are changed", t)
+		}
+		cs.Warnings = append(cs.Warnings, msg)
+	}
+
+	for _, c := range dropIndexes {
+		cs.Warnings = append(cs.Warnings, c.Comment)
+	}
+	cs.Changes = append(cs.Changes, drops...)
+	cs.Changes = append(cs.Changes, creates...)
+	cs.Changes = append(cs.Changes, addIndexes...)
+	cs.Changes = append(cs.Changes, dropIndexes...)
+	cs.Changes = append(cs.Changes, recreateIndexes...)
+	cs.Changes = append(cs.Changes, deploys...)
+	return cs, nil
+}
+
+// ApplyOption configures Apply.
+type ApplyOption func(*applyOptions)
+
+type applyOptions struct {
+	destructive bool
+}
+
+// WithDestructive allows destructive changes in Apply.
+func WithDestructive() ApplyOption {
+	return func(o *applyOptions) { o.destructive = true }
+}
+
+// Apply executes changes in order, stops at the first failure. Changesets with destructive
+// changes are refused unless WithDestructive.
+func Apply(ctx context.Context, db *sql.DB, cs *Changeset, opts ...ApplyOption) error {
+	o := applyOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if cs.Destructive() && !o.destructive {
+		return ErrDestructive
+	}
+
+	for i, c := range cs.Changes {
+		if _, err := db.ExecContext(ctx, c.SQL); err != nil {
+			return fmt.Errorf("schema: change %d %s: %w", i, c.SQL, err)
+		}
+	}
+	return nil
+}
+
+// diffTable returns warnings of differences of columns and options, which can't be changed
+func diffTable(t *Table, live *openmldb.TableInfo) []string {
+	var warnings []string
+	cols := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = columnSQL(c.Name, c.Type, c.NotNull)
+	}
+	liveCols := make([]string, len(live.Columns))
+	for i, c := range live.Columns {
+		liveCols[i] = columnSQL(c.Name, c.Type, c.NotNull)
+	}
+	if strings.Join(cols, ", ") != strings.Join(liveCols, ", ") {
+		warnings = append(warnings, fmt.Sprintf("columns of table %s can't be changed: (%s), want (%s)",
+			t.Name, strings.Join(liveCols, ", "), strings.Join(cols, ", ")))
+	}
+
+	if t.PartitionNum != 0 && t.PartitionNum != live.PartitionNum {
+		warnings = append(warnings, fmt.Sprintf("partition_num of table %s can't be changed: %d, want %d",
+			t.Name, live.PartitionNum, t.PartitionNum))
+	}
+	if t.ReplicaNum != 0 && t.ReplicaNum != live.ReplicaNum {
+		warnings = append(warnings, fmt.Sprintf("replica_num of table %s can't be changed: %d, want %d",
+			t.Name, live.ReplicaNum, t.ReplicaNum))
+	}
+	if t.StorageMode != "" && !strings.EqualFold(t.StorageMode, live.StorageMode) {
+		warnings = append(warnings, fmt.Sprintf("storage_mode of table %s can't be changed: %s, want %s",
+			t.Name, live.StorageMode, t.StorageMode))
+	}
+	return warnings
+}
+
+// diffIndexes returns CREATE INDEX of desired indexes not live, DROP INDEX of live indexes not
+// desired or changed, and CREATE INDEX of the changed. New indexes are added before drops, as
+// the last index of table can't be dropped. Changed indexes keep their live names, as indexes
+// can't be renamed, and if no other index is left while they are dropped, the first one is
+// created under a temporary name before drops, and the temporary index dropped after the
+// changed ones are recreated.
+func diffIndexes(t *Table, live *openmldb.TableInfo) (adds []Change, drops []Change, recreates []Change, err error) {
+	matched := map[int]bool{}
+	kept := 0
+	var changed []Index
+	for _, idx := range t.Indexes {
+		i := matchIndex(&idx, live.Indexes, matched)
+		if i >= 0 {
+			matched[i] = true
+			if indexEqual(&idx, &live.Indexes[i]) {
+				kept++
+				continue
+			}
+			idx.Name = live.Indexes[i].Name
+			drops = append(drops, dropIndex(t.Name, live.Indexes[i].Name, "changed"))
+			recreates = append(recreates, createIndex(t.Name, &idx))
+			changed = append(changed, idx)
+			continue
+		}
+		if idx.Name == "" {
+			return nil, nil, nil, fmt.Errorf("schema: table %s: name required for new index of keys %s",
+				t.Name, strings.Join(idx.Keys, ","))
+		}
+		adds = append(adds, createIndex(t.Name, &idx))
+	}
+	for i, idx := range live.Indexes {
+		if !matched[i] {
+			drops = append(drops, dropIndex(t.Name, idx.Name, "not declared"))
+		}
+	}
+
+	if kept == 0 && len(adds) == 0 && len(changed) > 0 {
+		tmp := changed[0]
+		tmp.Name = tempIndexName(t, live, tmp.Name)
+		adds = append(adds, Change{
+			SQL:     createIndexSQL(t.Name, &tmp),
+			Comment: fmt.Sprintf("temporary index %s of table %s, as the last index can't be dropped", tmp.Name, t.Name),
+		})
+		recreates = append(recreates, Change{
+			SQL:     fmt.Sprintf("DROP INDEX %s.%s", ident(t.Name), ident(tmp.Name)),
+			Comment: fmt.Sprintf("temporary index %s of table %s is dropped", tmp.Name, t.Name),
+		})
+	}
+	return adds, drops, recreates, nil
+}
+
+// tempIndexName returns name suffixed by _tmp, numbered if taken by another index of table
+func tempIndexName(t *Table, live *openmldb.TableInfo, name string) string {
+	taken := map[string]bool{}
+	for _, idx := range t.Indexes {
+		taken[strings.ToLower(idx.Name)] = true
+	}
+	for _, idx := range live.Indexes {
+		taken[strings.ToLower(idx.Name)] = true
+	}
+	tmp := name + "_tmp"
+	for i := 1; taken[strings.ToLower(tmp)]; i++ {
+		tmp = fmt.Sprintf("%s_tmp%d", name, i)
+	}
+	return tmp
+}
+
+func createIndex(table string, idx *Index) Change {
+	return Change{
+		SQL:     createIndexSQL(table, idx),
+		Comment: fmt.Sprintf("index %s of existing table %s is loaded from offline data", idx.Name, table),
+	}
+}
+
+func dropIndex(table string, name string, why string) Change {
+	return Change{
+		SQL:         fmt.Sprintf("DROP INDEX %s.%s", ident(table), ident(name)),
+		Destructive: true,
+		Comment:     fmt.Sprintf("index %s of table %s is dropped, %s", name, table, why),
+	}
+}
+
+// matchIndex returns the live index matching idx by name, or by keys and ts, -1 if not found
+func matchIndex(idx *Index, live []openmldb.IndexInfo, matched map[int]bool) int {
+	if idx.Name != "" {
+		for i, l := range live {
+			if l.Name == idx.Name && !matched[i] {
+				return i
+			}
+		}
+	}
+	for i, l := range live {
+		if !matched[i] && strings.Join(l.Keys, ",") == strings.Join(idx.Keys, ",") && l.TS == idx.TS {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexEqual(idx *Index, live *openmldb.IndexInfo) bool {
+	if strings.Join(idx.Keys, ",") != strings.Join(live.Keys, ",") || idx.TS != live.TS ||
+		idx.ttlType() != live.TTLType {
+		return false
+	}
+	abs, _ := idx.absTTL()
+	switch idx.ttlType() {
+	case "absolute":
+		return abs == live.AbsTTL
+	case "latest":
+		return idx.LatTTL == live.LatTTL
+	default:
+		return abs == live.AbsTTL && idx.LatTTL == live.LatTTL
+	}
+}
+
+func createTableSQL(t *Table) string {
+	var defs []string
+	for _, c := range t.Columns {
+		defs = append(defs, columnSQL(c.Name, c.Type, c.NotNull))
+	}
+	for _, idx := range t.Indexes {
+		defs = append(defs, "INDEX("+strings.Join(indexOptions(&idx, true), ", ")+")")
+	}
+	sql := fmt.Sprintf("CREATE TABLE %s (%s)", ident(t.Name), strings.Join(defs, ", "))
+
+	var opts []string
+	if t.PartitionNum != 0 {
+		opts = append(opts, fmt.Sprintf("PARTITIONNUM=%d", t.PartitionNum))
+	}
+	if t.ReplicaNum != 0 {
+		opts = append(opts, fmt.Sprintf("REPLICANUM=%d", t.ReplicaNum))
+	}
+	if t.StorageMode != "" {
+		opts = append(opts, "STORAGE_MODE="+openmldb.QuoteLiteral(t.StorageMode))
+	}
+	if len(opts) > 0 {
+		sql += " OPTIONS (" + strings.Join(opts, ", ") + ")"
+	}
+	return sql
+}
+
+func createIndexSQL(table string, idx *Index) string {
+	keys := make([]string, len(idx.Keys))
+	for i, k := range idx.Keys {
+		keys[i] = ident(k)
+	}
+	sql := fmt.Sprintf("CREATE INDEX %s ON %s (%s)", ident(idx.Name), ident(table), strings.Join(keys, ", "))
+	if opts := indexOptions(idx, false); len(opts) > 0 {
+		sql += " OPTIONS (" + strings.Join(opts, ", ") + ")"
+	}
+	return sql
+}
+
+// indexOptions returns KEY, TS and TTL options of index, KEY only if withKey
+func indexOptions(idx *Index, withKey bool) []string {
+	var opts []string
+	if withKey {
+		keys := make([]string, len(idx.Keys))
+		for i, k := range idx.Keys {
+			keys[i] = ident(k)
+		}
+		if len(keys) == 1 {
+			opts = append(opts, "KEY="+keys[0])
+		} else {
+			opts = append(opts, "KEY=("+strings.Join(keys, ", ")+")")
+		}
+	}
+	if idx.TS != "" {
+		opts = append(opts, "TS="+ident(idx.TS))
+	}
+
+	abs, _ := idx.absTTL()
+	switch typ := idx.ttlType(); {
+	case typ == "absolute" && abs == 0:
+	case typ == "absolute":
+		opts = append(opts, "TTL_TYPE=absolute", "TTL="+formatTTL(abs))
+	case typ == "latest":
+		opts = append(opts, "TTL_TYPE=latest", fmt.Sprintf("TTL=%d", idx.LatTTL))
+	default:
+		opts = append(opts, "TTL_TYPE="+typ, fmt.Sprintf("TTL=(%s, %d)", formatTTL(abs), idx.LatTTL))
+	}
+	return opts
+}
+
+// formatTTL formats absolute TTL in the largest unit of days, hours or minutes
+func formatTTL(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+func columnSQL(name string, typ string, notNull bool) string {
+	s := ident(name) + " " + strings.ToLower(typ)
+	if notNull {
+		s += " NOT NULL"
+	}
+	return s
+}
+
+func deploySQL(d *Deployment) string {
+	sql := "DEPLOY " + ident(d.Name)
+	if len(d.Options) > 0 {
+		keys := make([]string, 0, len(d.Options))
+		for k := range d.Options {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		opts := make([]string, len(keys))
+		for i, k := range keys {
+			opts[i] = strings.ToUpper(k) + "=" + openmldb.QuoteLiteral(d.Options[k])
+		}
+		sql += " OPTIONS (" + strings.Join(opts, ", ") + ")"
+	}
+	return sql + " " + strings.TrimRight(strings.TrimSpace(d.SQL), "; \n")
+}
+
+// normalizeSQL returns the query of deployment with tokens separated by single space and
+// identifiers in lower case, DEPLOY and its options stripped if any
+func normalizeSQL(sql string) string {
+	toks, err := sqlparse.Lex(sql)
+	if err != nil {
+		return sql
+	}
+	if len(toks) > 0 && toks[0].Is("DEPLOY") {
+		for i, t := range toks {
+			if t.Is("SELECT") {
+				toks = toks[i:]
+				break
+			}
+		}
+	}
+
+	var parts []string
+	for _, t := range toks {
+		switch {
+		case t.Kind == sqlparse.EOF, t.Kind == sqlparse.Punct && t.Text == ";":
+		case t.Kind == sqlparse.Ident:
+			parts = append(parts, strings.ToLower(t.Text))
+		default:
+			parts = append(parts, t.Text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// usesAny returns the first table of tables used by query, empty if none
+func usesAny(query string, tables map[string]bool) string {
+	stmt, err := sqlparse.Classify(query)
+	if err != nil {
+		return ""
+	}
+	for _, t := range stmt.Tables {
+		if tables[t] {
+			return t
+		}
+	}
+	return ""
+}
+
+var plainIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ident quotes name by backticks unless it is a plain identifier
+func ident(name string) string {
+	if plainIdent.MatchString(name) {
+		return name
+	}
+	return openmldb.QuoteIdentifier(name)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
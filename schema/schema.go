@@ -0,0 +1,183 @@
+// Package schema keeps the tables, indexes and deployments of an OpenMLDB database as declared,
+// in Go or YAML. Plan compares the declared database with the live one and returns the ordered
+// statements to reach it, Apply executes them.
+//
+// Only additive changes and index changes are planned: tables and deployments not declared are
+// left unchanged, and columns or options of existing tables, which OpenMLDB can't alter, are
+// reported as warnings.
+package schema
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Database is the desired state of a database.
+type Database struct {
+	// Name is the database, which must be the database of DSN.
+	Name        string       `yaml:"name"`
+	Tables      []Table      `yaml:"tables"`
+	Deployments []Deployment `yaml:"deployments"`
+}
+
+// Table is the desired state of a table.
+type Table struct {
+	Name    string   `yaml:"name"`
+	Columns []Column `yaml:"columns"`
+	Indexes []Index  `yaml:"indexes"`
+	// PartitionNum, ReplicaNum and StorageMode are options of table, zero for server default.
+	PartitionNum int    `yaml:"partition_num"`
+	ReplicaNum   int    `yaml:"replica_num"`
+	StorageMode  string `yaml:"storage_mode"`
+}
+
+// Column is a column of table.
+type Column struct {
+	Name string `yaml:"name"`
+	// Type is the SQL type, e.g. int, bigint, string, timestamp or date.
+	Type    string `yaml:"type"`
+	NotNull bool   `yaml:"not_null"`
+}
+
+// Index is an index of table. Indexes are matched with live ones by name, or by keys and ts
+// if not found by name. Name is required for index added to an existing table.
+type Index struct {
+	Name string   `yaml:"name"`
+	Keys []string `yaml:"keys"`
+	TS   string   `yaml:"ts"`
+	// TTLType is absolute, latest, absorlat or absandlat, default to absolute.
+	TTLType string `yaml:"ttl_type"`
+	// AbsTTL is the absolute TTL in OpenMLDB syntax, e.g. 30d, 12h or 10m, empty or 0m for no limit.
+	AbsTTL string `yaml:"abs_ttl"`
+	// LatTTL is the number of latest rows kept for a key, 0 for no limit.
+	LatTTL int64 `yaml:"lat_ttl"`
+}
+
+// Deployment is a deployment of database.
+type Deployment struct {
+	Name string `yaml:"name"`
+	// SQL is the deployed query.
+	SQL string `yaml:"sql"`
+	// Options are options of DEPLOY, e.g. long_windows.
+	Options map[string]string `yaml:"options"`
+}
+
+// Load reads Database in YAML from r.
+func Load(r io.Reader) (*Database, error) {
+	var d Database
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	return &d, d.Validate()
+}
+
+// LoadFile reads Database in YAML from file.
+func LoadFile(name string) (*Database, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Validate checks names are set and unique, and index columns and TTL are valid.
+func (d *Database) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("schema: database name required")
+	}
+	tables := map[string]bool{}
+	for _, t := range d.Tables {
+		if t.Name == "" {
+			return fmt.Errorf("schema: table name required")
+		}
+		if tables[t.Name] {
+			return fmt.Errorf("schema: duplicate table %s", t.Name)
+		}
+		tables[t.Name] = true
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("schema: table %s: %w", t.Name, err)
+		}
+	}
+	deployments := map[string]bool{}
+	for _, dep := range d.Deployments {
+		if dep.Name == "" || dep.SQL == "" {
+			return fmt.Errorf("schema: deployment name and sql required")
+		}
+		if deployments[dep.Name] {
+			return fmt.Errorf("schema: duplicate deployment %s", dep.Name)
+		}
+		deployments[dep.Name] = true
+	}
+	return nil
+}
+
+func (t *Table) validate() error {
+	if len(t.Columns) == 0 {
+		return fmt.Errorf("no columns")
+	}
+	columns := map[string]bool{}
+	for _, c := range t.Columns {
+		if c.Name == "" || c.Type == "" {
+			return fmt.Errorf("column name and type required")
+		}
+		if columns[c.Name] {
+			return fmt.Errorf("duplicate column %s", c.Name)
+		}
+		columns[c.Name] = true
+	}
+	for _, idx := range t.Indexes {
+		if len(idx.Keys) == 0 {
+			return fmt.Errorf("index %s: keys required", idx.Name)
+		}
+		for _, k := range append(append([]string(nil), idx.Keys...), idx.TS) {
+			if k != "" && !columns[k] {
+				return fmt.Errorf("index %s: unknown column %s", idx.Name, k)
+			}
+		}
+		switch idx.ttlType() {
+		case "absolute", "latest", "absorlat", "absandlat":
+		default:
+			return fmt.Errorf("index %s: invalid ttl_type %s", idx.Name, idx.TTLType)
+		}
+		if _, err := idx.absTTL(); err != nil {
+			return fmt.Errorf("index %s: %w", idx.Name, err)
+		}
+	}
+	return nil
+}
+
+func (idx *Index) ttlType() string {
+	if idx.TTLType == "" {
+		return "absolute"
+	}
+	return strings.ToLower(idx.TTLType)
+}
+
+// absTTL parses AbsTTL, in minutes if no unit
+func (idx *Index) absTTL() (time.Duration, error) {
+	s := strings.ToLower(strings.TrimSpace(idx.AbsTTL))
+	if s == "" {
+		return 0, nil
+	}
+	units := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour}
+	unit, ok := units[s[len(s)-1]]
+	if ok {
+		s = s[:len(s)-1]
+	} else {
+		unit = time.Minute
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid abs_ttl %s", idx.AbsTTL)
+	}
+	return time.Duration(n) * unit, nil
+}
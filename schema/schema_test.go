@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	openmldb "github.com/4paradigm/openmldb-go-sdk"
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+const testYAML = `
+name: test_db
+tables:
+  - name: users
+    columns:
+      - {name: id, type: string, not_null: true}
+      - {name: age, type: int}
+      - {name: ts, type: timestamp}
+    indexes:
+      - {name: by_id, keys: [id], ts: ts, ttl_type: absolute, abs_ttl: 30d}
+    replica_num: 1
+deployments:
+  - name: d1
+    sql: SELECT id, sum(age) OVER w AS s FROM users WINDOW w AS (PARTITION BY id ORDER BY ts ROWS BETWEEN 2 PRECEDING AND CURRENT ROW)
+`
+
+func openDB(t *testing.T) *sql.DB {
+	srv := emulator.NewServer()
+	t.Cleanup(srv.Close)
+	db, err := sql.Open("openmldb", srv.DSN("test_db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoad(t *testing.T) {
+	d, err := Load(strings.NewReader(testYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "test_db", d.Name)
+	require.Len(t, d.Tables, 1)
+	assert.Equal(t, Index{Name: "by_id", Keys: []string{"id"}, TS: "ts", TTLType: "absolute", AbsTTL: "30d"}, d.Tables[0].Indexes[0])
+
+	for yml, msg := range map[string]string{
+		"tables: []":                    "schema: database name required",
+		"name: db\ntables: [{name: t}]": "schema: table t: no columns",
+		"name: db\nunknown: 1":          "schema: yaml: unmarshal errors:\n  line 2: field unknown not found in type schema.Database",
+		"name: db\ntables: [{name: t, columns: [{name: c, type: int}], indexes: [{keys: [x]}]}]":             "schema: table t: index : unknown column x",
+		"name: db\ntables: [{name: t, columns: [{name: c, type: int}], indexes: [{keys: [c], abs_ttl: x}]}]": "schema: table t: index : invalid abs_ttl x",
+	} {
+		_, err := Load(strings.NewReader(yml))
+		assert.EqualError(t, err, msg, yml)
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	desired, err := Load(strings.NewReader(testYAML))
+	require.NoError(t, err)
+
+	cs, err := Plan(ctx, db, desired)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users (id string NOT NULL, age int, ts timestamp, INDEX(KEY=id, TS=ts, TTL_TYPE=absolute, TTL=30d)) OPTIONS (REPLICANUM=1);\n"+
+		"DEPLOY d1 "+desired.Deployments[0].SQL+";\n", cs.String())
+	require.NoError(t, Apply(ctx, db, cs))
+
+	// applied, nothing to change but the unnamed index
+	desired.Tables[0].Indexes[0].Name = ""
+	cs, err = Plan(ctx, db, desired)
+	require.NoError(t, err)
+	assert.Empty(t, cs.Changes)
+	assert.Empty(t, cs.Warnings)
+
+	// add an index, change ttl of the other, add a column
+	desired.Tables[0].Indexes[0].AbsTTL = "12h"
+	desired.Tables[0].Indexes = append(desired.Tables[0].Indexes, Index{Name: "by_age", Keys: []string{"age"}, TS: "ts", TTLType: "latest", LatTTL: 10})
+	desired.Tables[0].Columns = append(desired.Tables[0].Columns, Column{Name: "extra", Type: "string"})
+	cs, err = Plan(ctx, db, desired)
+	require.NoError(t, err)
+	var stmts []string
+	for _, c := range cs.Changes {
+		stmts = append(stmts, c.SQL)
+	}
+	assert.Equal(t, []string{
+		"DROP DEPLOYMENT d1",
+		"CREATE INDEX by_age ON users (age) OPTIONS (TS=ts, TTL_TYPE=latest, TTL=10)",
+		"DROP INDEX users.INDEX_0",
+		"CREATE INDEX INDEX_0 ON users (id) OPTIONS (TS=ts, TTL_TYPE=absolute, TTL=12h)",
+		"DEPLOY d1 " + desired.Deployments[0].SQL,
+	}, stmts)
+	assert.True(t, cs.Destructive())
+	assert.Equal(t, []string{
+		"columns of table users can't be changed: (id string NOT NULL, age int, ts timestamp), want (id string NOT NULL, age int, ts timestamp, extra string)",
+		"index INDEX_0 of table users is dropped, changed",
+	}, cs.Warnings)
+
+	assert.ErrorIs(t, Apply(ctx, db, cs), ErrDestructive)
+	require.NoError(t, Apply(ctx, db, cs, WithDestructive()))
+
+	tables, err := openmldb.DescribeTables(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, tables[0].Indexes, 2)
+	assert.Equal(t, "latest", tables[0].Indexes[0].TTLType)
+	assert.Equal(t, int64(10), tables[0].Indexes[0].LatTTL)
+	assert.Equal(t, 12*time.Hour, tables[0].Indexes[1].AbsTTL)
+
+	// database other than DSN
+	_, err = Plan(ctx, db, &Database{Name: "other_db"})
+	assert.EqualError(t, err, "schema: database other_db differs from test_db of DSN")
+}
+
+func TestPlanSingleIndexChange(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, "CREATE TABLE t (c1 string, ts timestamp, INDEX(KEY=c1, TS=ts, TTL_TYPE=absolute, TTL=1d))")
+	require.NoError(t, err)
+
+	desired := &Database{Name: "test_db", Tables: []Table{{
+		Name:    "t",
+		Columns: []Column{{Name: "c1", Type: "string"}, {Name: "ts", Type: "timestamp"}},
+		Indexes: []Index{{Name: "idx", Keys: []string{"c1"}, TS: "ts", TTLType: "absolute", AbsTTL: "2d"}},
+	}}}
+	cs, err := Plan(ctx, db, desired)
+	require.NoError(t, err)
+	var stmts []string
+	for _, c := range cs.Changes {
+		stmts = append(stmts, c.SQL)
+	}
+	assert.Equal(t, []string{
+		"CREATE INDEX INDEX_0_tmp ON t (c1) OPTIONS (TS=ts, TTL_TYPE=absolute, TTL=2d)",
+		"DROP INDEX t.INDEX_0",
+		"CREATE INDEX INDEX_0 ON t (c1) OPTIONS (TS=ts, TTL_TYPE=absolute, TTL=2d)",
+		"DROP INDEX t.INDEX_0_tmp",
+	}, stmts)
+	require.NoError(t, Apply(ctx, db, cs, WithDestructive()))
+
+	tables, err := openmldb.DescribeTables(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, tables[0].Indexes, 1)
+	assert.Equal(t, "INDEX_0", tables[0].Indexes[0].Name)
+	assert.Equal(t, 48*time.Hour, tables[0].Indexes[0].AbsTTL)
+
+	cs, err = Plan(ctx, db, desired)
+	require.NoError(t, err)
+	assert.Empty(t, cs.Changes)
+}
+
+func TestPlanDeployment(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, "CREATE TABLE t1 (c1 string, c2 int, ts timestamp, INDEX(KEY=c1, TS=ts))")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DEPLOY d1 SELECT c1, c2 FROM t1")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DEPLOY d2 SELECT c1 FROM t1")
+	require.NoError(t, err)
+
+	desired := &Database{Name: "test_db", Deployments: []Deployment{
+		{Name: "d1", SQL: "select c1,  c2\nFROM t1;"},
+		{Name: "d2", SQL: "SELECT c2 FROM t1", Options: map[string]string{"long_windows": "w1:1d"}},
+	}}
+	cs, err := Plan(ctx, db, desired)
+	require.NoError(t, err)
+	assert.Equal(t, "-- WARNING: table t1 is not declared, left unchanged\n"+
+		"-- re-deploy d2, query changed\n"+
+		"DROP DEPLOYMENT d2;\n"+
+		"-- re-deploy d2, query changed\n"+
+		"DEPLOY d2 OPTIONS (LONG_WINDOWS='w1:1d') SELECT c2 FROM t1;\n", cs.String())
+}
@@ -0,0 +1,404 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/4paradigm/openmldb-go-sdk/internal/sqlparse"
+)
+
+var (
+	_ driver.RowsNextResultSet              = (*multiRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*multiRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*multiRows)(nil)
+)
+
+// ScriptOption configures ExecScript.
+type ScriptOption func(*scriptOptions)
+
+type scriptOptions struct {
+	continueOnError bool
+}
+
+// WithContinueOnError executes the rest of statements after a statement failed, by default
+// ExecScript stops at the first failure.
+func WithContinueOnError() ScriptOption {
+	return func(o *scriptOptions) { o.continueOnError = true }
+}
+
+// StatementResult is the result of a statement in script.
+type StatementResult struct {
+	// SQL is the statement text, without ';'.
+	SQL string
+	// Line is the line of statement start in script, from 1.
+	Line int
+	// Kind is the kind of statement, e.g. DDL, DML, DQL, DEPLOY, SET or LOAD DATA.
+	Kind string
+	// DB and Mode are the database and execution mode statement executed in.
+	DB   string
+	Mode queryMode
+	// Rows is the number of rows returned.
+	Rows     int
+	Duration time.Duration
+	// Err is the error of statement, nil if succeeded.
+	Err error
+}
+
+// StatementError is the error of a statement in script.
+type StatementError struct {
+	Index int // index of statement in script
+	Line  int // line of statement start
+	SQL   string
+	Err   error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d (line %d): %s", e.Index, e.Line, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// ScriptError reports the statements failed in ExecScript, sorted by index.
+type ScriptError struct {
+	Statements []*StatementError
+}
+
+func (e *ScriptError) Error() string {
+	msgs := make([]string, len(e.Statements))
+	for i, s := range e.Statements {
+		msgs[i] = s.Error()
+	}
+	return fmt.Sprintf("script: %d statements failed: %s", len(e.Statements), strings.Join(msgs, "; "))
+}
+
+func (e *ScriptError) Unwrap() []error {
+	errs := make([]error, len(e.Statements))
+	for i, s := range e.Statements {
+		errs[i] = s
+	}
+	return errs
+}
+
+// ExecScript executes statements in script read from r in order, on a single connection of db.
+//
+// Statements are split by ';' out of string literals, quoted identifiers and comments. Rows
+// returned by queries are read and counted. `USE db` and `SET @@execute_mode`/`SET @@sync_job`
+// are applied to the following statements of script, instead of sent to api server, e.g.
+// `SET @@execute_mode='offline'` runs following statements in offsync mode, or offasync mode
+// if `SET @@sync_job=false`.
+//
+// Results are returned for statements executed. A *ScriptError returned if any statement
+// failed, by default execution stops at the first failure, see WithContinueOnError.
+func ExecScript(ctx context.Context, db *sql.DB, r io.Reader, opts ...ScriptOption) ([]StatementResult, error) {
+	o := scriptOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	script := string(b)
+	spans, err := sqlparse.Split(script)
+	if err != nil {
+		var lexErr *sqlparse.Error
+		if errors.As(err, &lexErr) {
+			return nil, fmt.Errorf("script: line %d: %s", lineOf(script, lexErr.Pos), lexErr.Msg)
+		}
+		return nil, err
+	}
+
+	dbConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer dbConn.Close()
+
+	var (
+		results []StatementResult
+		failed  []*StatementError
+	)
+	err = dbConn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return fmt.Errorf("not an openmldb connection: %T", driverConn)
+		}
+
+		sess := newSession(c)
+		for i, span := range spans {
+			start := time.Now()
+			res := c.execScriptStatement(ctx, sess, span.Text)
+			res.Duration = time.Since(start)
+			res.Line = lineOf(script, span.Pos)
+			results = append(results, res)
+			if res.Err == nil {
+				continue
+			}
+			failed = append(failed, &StatementError{Index: i, Line: res.Line, SQL: res.SQL, Err: res.Err})
+			if !o.continueOnError || ctx.Err() != nil {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return results, err
+	}
+	if len(failed) > 0 {
+		return results, &ScriptError{Statements: failed}
+	}
+	return results, nil
+}
+
+// execScriptStatement executes a statement in session, rows are read and counted
+func (c *conn) execScriptStatement(ctx context.Context, sess *session, sql string) StatementResult {
+	res := StatementResult{SQL: sql}
+
+	stmt, err := sqlparse.Classify(sql)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Kind = stmt.Kind.String()
+
+	local, err := sess.apply(stmt)
+	res.DB, res.Mode = sess.db, sess.mode
+	if local || err != nil {
+		res.Err = err
+		return res
+	}
+
+	rows, err := c.execute(ctx, sess.db, sess.mode, sql)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if rows != nil {
+		res.Rows, res.Err = drainRows(rows)
+	}
+	return res
+}
+
+// drainRows reads and closes rows, returns the number of rows read
+func drainRows(rows driver.Rows) (int, error) {
+	defer rows.Close()
+	dest := make([]driver.Value, len(rows.Columns()))
+	n := 0
+	for {
+		if err := rows.Next(dest); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func lineOf(s string, pos int) int {
+	return strings.Count(s[:pos], "\n") + 1
+}
+
+// session is the database and mode of statements executed in order, changed by USE and SET
+type session struct {
+	db   string
+	mode queryMode
+	// async is whether offline statements run in offasync mode, by SET @@sync_job
+	async bool
+}
+
+func newSession(c *conn) *session {
+	return &session{db: c.db, mode: c.mode, async: c.mode == ModeOffasync}
+}
+
+// apply applies USE and SET of execute_mode or sync_job to session, reports whether the
+// statement is done locally. Other statements are left to api server.
+func (s *session) apply(stmt *sqlparse.Statement) (bool, error) {
+	toks := stmt.Tokens
+	switch stmt.Kind {
+	case sqlparse.KindUse:
+		if len(toks) != 3 || (toks[1].Kind != sqlparse.Ident && toks[1].Kind != sqlparse.QuotedIdent) {
+			return false, nil
+		}
+		s.db = toks[1].Value
+		return true, nil
+	case sqlparse.KindSet:
+		// SET @@[session.]name = value
+		if len(toks) != 5 || toks[1].Kind != sqlparse.Variable || toks[2].Text != "=" {
+			return false, nil
+		}
+		name := strings.Replace(strings.ToLower(toks[1].Value), "@@session.", "@@", 1)
+		value := strings.ToLower(toks[3].Value)
+		switch name {
+		case "@@execute_mode":
+			switch value {
+			case "online":
+				s.mode = ModeOnline
+			case "offline":
+				s.mode = s.offlineMode()
+			default:
+				return true, fmt.Errorf("unsupported execute_mode: %s", toks[3].Value)
+			}
+			return true, nil
+		case "@@sync_job":
+			switch value {
+			case "true", "1":
+				s.async = false
+			case "false", "0":
+				s.async = true
+			default:
+				return true, fmt.Errorf("invalid sync_job: %s", toks[3].Value)
+			}
+			if s.mode != ModeOnline {
+				s.mode = s.offlineMode()
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *session) offlineMode() queryMode {
+	if s.async {
+		return ModeOffasync
+	}
+	return ModeOffsync
+}
+
+// executeMulti executes query of one or more statements. Result sets are the rows of
+// statements returning rows, the first is returned, the following are executed on demand by
+// NextResultSet, with statements not returning rows executed in between.
+func (c *conn) executeMulti(ctx context.Context, query string, parameters []driver.Value) (driver.Rows, error) {
+	spans, err := sqlparse.Split(query)
+	if err != nil || len(spans) <= 1 {
+		// single statement, invalid SQL is reported by api server
+		return c.execute(ctx, c.db, c.mode, query, parameters...)
+	}
+
+	m := &multiRows{ctx: ctx, c: c, sess: newSession(c)}
+	for _, span := range spans {
+		stmt, err := sqlparse.Classify(span.Text)
+		if err != nil {
+			return nil, err
+		}
+		if stmt.Placeholders > len(parameters) {
+			return nil, fmt.Errorf("not enough arguments: %d placeholders in statements", stmt.Placeholders)
+		}
+		m.stmts = append(m.stmts, stmt)
+		m.sqls = append(m.sqls, span.Text)
+		m.args = append(m.args, parameters[:stmt.Placeholders])
+		parameters = parameters[stmt.Placeholders:]
+	}
+	if len(parameters) > 0 {
+		return nil, fmt.Errorf("too many arguments: %d not used by placeholders", len(parameters))
+	}
+
+	if err := m.NextResultSet(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return m, nil
+}
+
+// multiRows are the result sets of multiple statements
+type multiRows struct {
+	ctx  context.Context
+	c    *conn
+	sess *session
+
+	stmts []*sqlparse.Statement
+	sqls  []string
+	args  [][]driver.Value
+	next  int
+
+	cur driver.Rows
+}
+
+// Columns implements driver.Rows.
+func (m *multiRows) Columns() []string {
+	if m.cur == nil {
+		return nil
+	}
+	return m.cur.Columns()
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+func (m *multiRows) ColumnTypeDatabaseTypeName(index int) string {
+	if r, ok := m.cur.(*respDataRows); ok {
+		return r.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+// ColumnTypeScanType implements driver.RowsColumnTypeScanType.
+func (m *multiRows) ColumnTypeScanType(index int) reflect.Type {
+	if r, ok := m.cur.(*respDataRows); ok {
+		return r.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf((*any)(nil)).Elem()
+}
+
+// Close implements driver.Rows.
+func (m *multiRows) Close() error {
+	m.next = len(m.stmts)
+	if m.cur == nil {
+		return nil
+	}
+	cur := m.cur
+	m.cur = nil
+	return cur.Close()
+}
+
+// Next implements driver.Rows.
+func (m *multiRows) Next(dest []driver.Value) error {
+	if m.cur == nil {
+		return io.EOF
+	}
+	return m.cur.Next(dest)
+}
+
+// HasNextResultSet implements driver.RowsNextResultSet.
+func (m *multiRows) HasNextResultSet() bool {
+	return m.next < len(m.stmts)
+}
+
+// NextResultSet implements driver.RowsNextResultSet.
+//
+// Executes statements until one returns rows, io.EOF returned if none.
+func (m *multiRows) NextResultSet() error {
+	if m.cur != nil {
+		if err := m.cur.Close(); err != nil {
+			return err
+		}
+		m.cur = nil
+	}
+
+	for m.next < len(m.stmts) {
+		i := m.next
+		m.next++
+		local, err := m.sess.apply(m.stmts[i])
+		if err != nil {
+			return err
+		}
+		if local {
+			continue
+		}
+		rows, err := m.c.execute(m.ctx, m.sess.db, m.sess.mode, m.sqls[i], m.args[i]...)
+		if err != nil {
+			return err
+		}
+		if rows != nil {
+			m.cur = rows
+			return nil
+		}
+	}
+	return io.EOF
+}
@@ -0,0 +1,115 @@
+package openmldb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScript = `-- tables
+CREATE TABLE t1 (c1 string, c2 int);
+INSERT INTO t1 VALUES ('a;1', 1), ('b', 2); /* ; */
+SET @@execute_mode = 'offline';
+SELECT c1 FROM t1;
+SET @@sync_job = false;
+SELECT c1 FROM t1 WHERE c2 = 2;
+SET @@execute_mode = 'online';
+`
+
+func TestExecScript(t *testing.T) {
+	var reqs []*Request
+	db := openHookedDB(t, HookFuncs{BeforeFunc: func(ctx context.Context, req *Request) (context.Context, *Result, error) {
+		reqs = append(reqs, req)
+		return ctx, nil, nil
+	}})
+
+	results, err := ExecScript(context.Background(), db, strings.NewReader(testScript))
+	require.NoError(t, err)
+	require.Len(t, results, 7)
+
+	type result struct {
+		Line int
+		Kind string
+		Mode queryMode
+		Rows int
+	}
+	var got []result
+	for _, r := range results {
+		assert.NoError(t, r.Err)
+		assert.Equal(t, "test_db", r.DB)
+		got = append(got, result{r.Line, r.Kind, r.Mode, r.Rows})
+	}
+	assert.Equal(t, []result{
+		{2, "DDL", ModeOnline, 0},
+		{3, "DML", ModeOnline, 0},
+		{4, "SET", ModeOffsync, 0},
+		{5, "DQL", ModeOffsync, 2},
+		{6, "SET", ModeOffasync, 0},
+		{7, "DQL", ModeOffasync, 1},
+		{8, "SET", ModeOnline, 0},
+	}, got)
+	assert.Equal(t, "INSERT INTO t1 VALUES ('a;1', 1), ('b', 2)", results[1].SQL)
+
+	// SET applied locally
+	require.Len(t, reqs, 4)
+	assert.Equal(t, ModeOffasync, reqs[3].Mode)
+}
+
+func TestExecScriptError(t *testing.T) {
+	db := openHookedDB(t)
+	ctx := context.Background()
+	script := "CREATE TABLE t1 (c1 string);\nINSERT INTO t2 VALUES ('a');\nSELECT * FROM t1;"
+
+	results, err := ExecScript(ctx, db, strings.NewReader(script))
+	var scriptErr *ScriptError
+	require.ErrorAs(t, err, &scriptErr)
+	require.Len(t, scriptErr.Statements, 1)
+	assert.Equal(t, 1, scriptErr.Statements[0].Index)
+	assert.Equal(t, 2, scriptErr.Statements[0].Line)
+	require.Len(t, results, 2)
+	assert.Error(t, results[1].Err)
+
+	results, err = ExecScript(ctx, db, strings.NewReader("INSERT INTO t2 VALUES ('a'); SELECT * FROM t1"), WithContinueOnError())
+	require.ErrorAs(t, err, &scriptErr)
+	require.Len(t, results, 2)
+	assert.NoError(t, results[1].Err)
+
+	_, err = ExecScript(ctx, db, strings.NewReader("SELECT 1;\nSELECT 'a"))
+	assert.EqualError(t, err, "script: line 2: unterminated quoted string")
+}
+
+func TestQueryNextResultSet(t *testing.T) {
+	db := openHookedDB(t)
+	ctx := context.Background()
+
+	rows, err := db.QueryContext(ctx, `CREATE TABLE t1 (c1 string, c2 int);
+		INSERT INTO t1 VALUES ('a', 1), ('b', 2);
+		SELECT c1 FROM t1 WHERE c2 = ?;
+		SELECT c2 FROM t1 WHERE c1 = ?;`, int32(2), "a")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var sets [][]any
+	for {
+		var set []any
+		for rows.Next() {
+			var v any
+			require.NoError(t, rows.Scan(&v))
+			set = append(set, v)
+		}
+		sets = append(sets, set)
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][]any{{"b"}, {int32(1)}}, sets)
+
+	_, err = db.QueryContext(ctx, "SELECT 1; SELECT ?")
+	assert.EqualError(t, err, "not enough arguments: 1 placeholders in statements")
+	_, err = db.QueryContext(ctx, "SELECT 1; SELECT ?", 1, 2)
+	assert.EqualError(t, err, "too many arguments: 1 not used by placeholders")
+}
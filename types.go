@@ -6,12 +6,17 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"reflect"
 	"time"
 )
 
 var (
 	_ sql.Scanner   = (*NullDate)(nil)
 	_ driver.Valuer = NullDate{}
+
+	_ sql.Scanner = (*NullMap[string, int64])(nil)
+	_ sql.Scanner = (*NullArray[int64])(nil)
 )
 
 // Null represents a value that may be null.
@@ -32,7 +37,30 @@ type NullDate struct {
 	sql.Null[time.Time]
 }
 
-// MarshalJSON implements json.Marshaler
+// Scan implements sql.Scanner.
+//
+// Timestamps are truncated to the date in their location, so either a date or timestamp
+// column is scanned as date. Dates decoded as strings by Config.RawTime are parsed.
+func (dst *NullDate) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		dst.V, dst.Valid = time.Time{}, false
+	case time.Time:
+		y, m, d := v.Date()
+		dst.V, dst.Valid = time.Date(y, m, d, 0, 0, 0, 0, v.Location()), true
+	case string:
+		t, err := parseDateStr(v, time.UTC)
+		if err != nil {
+			return err
+		}
+		dst.V, dst.Valid = t, true
+	default:
+		return fmt.Errorf("unsupported type %T for date", value)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, formats the date of V in its location.
 func (src NullDate) MarshalJSON() ([]byte, error) {
 	if !src.Valid {
 		return json.Marshal(nil)
@@ -49,9 +77,66 @@ func (src Null[T]) MarshalJSON() ([]byte, error) {
 	var v any = src.V
 	switch val := v.(type) {
 	case time.Time:
-		// timestamp, marshal to int64 unix epoch time in millisecond
+		// timestamp, marshal to int64 unix epoch time in millisecond, regardless of location
 		return json.Marshal(val.UnixMilli())
 	default:
 		return json.Marshal(src.V)
 	}
 }
+
+// composite is a nullable MAP or ARRAY value, sent by its type even if null
+type composite interface {
+	composite() (t *dataType, v reflect.Value, valid bool)
+}
+
+// NullMap represents nullable SQL MAP<K, V> in go.
+//
+// Scan converts keys and values of the map decoded into K and V, e.g. map[string]int32 of
+// MAP<STRING, INT32> into NullMap[string, int64], and sent as MAP of K and V.
+type NullMap[K comparable, V any] struct {
+	sql.Null[map[K]V]
+}
+
+// Scan implements sql.Scanner.
+func (dst *NullMap[K, V]) Scan(value any) error {
+	if value == nil {
+		dst.V, dst.Valid = nil, false
+		return nil
+	}
+	if err := convertComposite(reflect.ValueOf(&dst.V).Elem(), reflect.ValueOf(value)); err != nil {
+		return err
+	}
+	dst.Valid = true
+	return nil
+}
+
+func (src NullMap[K, V]) composite() (*dataType, reflect.Value, bool) {
+	t, _ := goDataType(reflect.TypeFor[map[K]V]())
+	return t, reflect.ValueOf(src.V), src.Valid
+}
+
+// NullArray represents nullable SQL ARRAY<T> in go.
+//
+// Scan converts elements of the slice decoded into T, e.g. []int32 of ARRAY<INT32> into
+// NullArray[int64], and sent as ARRAY of T.
+type NullArray[T any] struct {
+	sql.Null[[]T]
+}
+
+// Scan implements sql.Scanner.
+func (dst *NullArray[T]) Scan(value any) error {
+	if value == nil {
+		dst.V, dst.Valid = nil, false
+		return nil
+	}
+	if err := convertComposite(reflect.ValueOf(&dst.V).Elem(), reflect.ValueOf(value)); err != nil {
+		return err
+	}
+	dst.Valid = true
+	return nil
+}
+
+func (src NullArray[T]) composite() (*dataType, reflect.Value, bool) {
+	t, _ := goDataType(reflect.TypeFor[[]T]())
+	return t, reflect.ValueOf(src.V), src.Valid
+}
@@ -0,0 +1,433 @@
+package openmldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrWriterClosed is returned by Writer.Write after Writer closed.
+	ErrWriterClosed = errors.New("openmldb: writer closed")
+	// ErrQueueFull is returned by Writer.Write if the queue is full and rows are dropped.
+	ErrQueueFull = errors.New("openmldb: writer queue full, row dropped")
+)
+
+// QueueFullPolicy decides what Writer.Write does when the queue is full.
+type QueueFullPolicy int
+
+const (
+	// Block waits until there is room in queue.
+	Block QueueFullPolicy = iota
+	// Drop drops the row and returns ErrQueueFull.
+	Drop
+)
+
+// WriterOption configures Writer.
+type WriterOption func(*writerOptions)
+
+type writerOptions struct {
+	batchSize     int
+	flushInterval time.Duration
+	queueSize     int
+	policy        QueueFullPolicy
+	maxRetries    int
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	onError       func(*WriteError)
+	logger        *slog.Logger
+}
+
+// WithBatchSize sets the maximum rows in a batch written at once, default to 500.
+func WithBatchSize(n int) WriterOption {
+	return func(o *writerOptions) { o.batchSize = n }
+}
+
+// WithFlushInterval sets how often buffered rows are written regardless of batch size, default to 1 second.
+func WithFlushInterval(d time.Duration) WriterOption {
+	return func(o *writerOptions) { o.flushInterval = d }
+}
+
+// WithQueueSize sets the maximum rows waiting to be written, default to 10 times of batch size.
+func WithQueueSize(n int) WriterOption {
+	return func(o *writerOptions) { o.queueSize = n }
+}
+
+// WithQueueFullPolicy sets what Write does when the queue is full, default to Block.
+func WithQueueFullPolicy(p QueueFullPolicy) WriterOption {
+	return func(o *writerOptions) { o.policy = p }
+}
+
+// WithRetry retries rows failed by transient errors at most maxRetries times, the backoff
+// between retries starts from backoff and doubles each time, up to maxBackoff.
+// Default to 3 retries, backoff from 100ms up to 5s.
+func WithRetry(maxRetries int, backoff time.Duration, maxBackoff time.Duration) WriterOption {
+	return func(o *writerOptions) {
+		o.maxRetries = maxRetries
+		o.backoff = backoff
+		o.maxBackoff = maxBackoff
+	}
+}
+
+// WithErrorHandler sets the callback for rows failed to write. It is called
+// from the writer goroutine, so should not block for long.
+func WithErrorHandler(fn func(*WriteError)) WriterOption {
+	return func(o *writerOptions) { o.onError = fn }
+}
+
+// WithLogger logs retries of writer at warning level, string literals in errors redacted.
+func WithLogger(logger *slog.Logger) WriterOption {
+	return func(o *writerOptions) { o.logger = logger }
+}
+
+// WriteError reports rows failed to write after retries.
+type WriteError struct {
+	Rows [][]any
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("write %d rows: %s", len(e.Rows), e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// WriterStats are counters of Writer.
+type WriterStats struct {
+	Written int64 // rows written
+	Failed  int64 // rows failed after retries
+	Dropped int64 // rows dropped as queue full
+	Queued  int64 // rows accepted by Write, not yet written or failed
+}
+
+// Writer writes rows into a table asynchronously.
+//
+// Rows passed to Write are queued, and written by BulkInsert in batches once
+// batch size reached or flush interval elapsed. Rows failed by transient errors,
+// e.g. connection reset, are retried with backoff, others are reported by
+// error handler and Errors channel. Writer is safe for concurrent use.
+//
+// Rows are queued by reference, not copied, the caller must not modify or reuse a row
+// after passing it to Write.
+type Writer struct {
+	db      *sql.DB
+	table   string
+	columns []string
+	opts    writerOptions
+
+	mu     sync.RWMutex // guards closed, and queue from closing during Write
+	closed bool
+	queue  chan []any
+	flush  chan chan error
+	done   chan struct{}
+	errs   chan *WriteError
+
+	// closing is closed once Close called, waking Write blocked and backoff of retries
+	closing     chan struct{}
+	closingOnce sync.Once
+	// closeErr is the error of rows written after closing, set before done closed
+	closeErr error
+
+	written atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+	queued  atomic.Int64
+}
+
+// NewWriter creates Writer to table, each row written should have values of columns in order.
+// If columns is empty, row values should be in the order of table schema.
+//
+// Close should be called to write the rest of rows and release the writer goroutine.
+func NewWriter(db *sql.DB, table string, columns []string, opts ...WriterOption) *Writer {
+	o := writerOptions{
+		batchSize:     defaultChunkRows,
+		flushInterval: time.Second,
+		maxRetries:    3,
+		backoff:       100 * time.Millisecond,
+		maxBackoff:    5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.batchSize <= 0 {
+		o.batchSize = defaultChunkRows
+	}
+	if o.queueSize <= 0 {
+		o.queueSize = 10 * o.batchSize
+	}
+
+	w := &Writer{
+		db:      db,
+		table:   table,
+		columns: columns,
+		opts:    o,
+		queue:   make(chan []any, o.queueSize),
+		flush:   make(chan chan error),
+		done:    make(chan struct{}),
+		errs:    make(chan *WriteError, 16),
+		closing: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues the row to write, row is kept by reference until written.
+//
+// If queue is full, it blocks until there is room, ctx done or Writer closed, or returns
+// ErrQueueFull, depends on QueueFullPolicy.
+func (w *Writer) Write(ctx context.Context, row []any) error {
+	if len(w.columns) > 0 && len(row) != len(w.columns) {
+		return fmt.Errorf("row has %d values, expect %d", len(row), len(w.columns))
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	if w.opts.policy == Drop {
+		select {
+		case w.queue <- row:
+		default:
+			w.dropped.Add(1)
+			return ErrQueueFull
+		}
+	} else {
+		select {
+		case w.queue <- row:
+		case <-w.closing:
+			return ErrWriterClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	w.queued.Add(1)
+	return nil
+}
+
+// Flush writes all queued rows, returns the errors of rows failed in this flush.
+//
+// Rows failed in earlier batches are reported by error handler and Errors channel only.
+func (w *Writer) Flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case w.flush <- reply:
+	case <-w.done:
+		return ErrWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes all queued rows and stops the writer, returns the errors of rows failed.
+// Rows waiting for retry are retried without backoff.
+func (w *Writer) Close() error {
+	// wake Write blocked on queue, so that lock is acquired without waiting for them
+	w.closingOnce.Do(func() { close(w.closing) })
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWriterClosed
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+	return w.closeErr
+}
+
+// Errors returns the channel of write errors, as an alternative to error handler.
+//
+// Errors are dropped if channel is full, the channel is closed after Writer closed.
+func (w *Writer) Errors() <-chan *WriteError {
+	return w.errs
+}
+
+// Stats returns the counters of rows.
+func (w *Writer) Stats() WriterStats {
+	return WriterStats{
+		Written: w.written.Load(),
+		Failed:  w.failed.Load(),
+		Dropped: w.dropped.Load(),
+		Queued:  w.queued.Load(),
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	defer close(w.errs)
+
+	ticker := time.NewTicker(w.opts.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]any, 0, w.opts.batchSize)
+	// errors of rows written after closing, returned by Close
+	var closeErrs []error
+	for {
+		select {
+		case row, ok := <-w.queue:
+			if !ok {
+				w.closeErr = errors.Join(append(closeErrs, w.writeBatch(batch))...)
+				return
+			}
+			batch = append(batch, row)
+			if len(batch) >= w.opts.batchSize {
+				if err := w.writeBatch(batch); w.isClosing() {
+					closeErrs = append(closeErrs, err)
+				}
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.writeBatch(batch)
+				batch = batch[:0]
+			}
+		case reply := <-w.flush:
+			var errs []error
+		drain:
+			for {
+				select {
+				case row, ok := <-w.queue:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, row)
+					if len(batch) >= w.opts.batchSize {
+						errs = append(errs, w.writeBatch(batch))
+						batch = batch[:0]
+					}
+				default:
+					break drain
+				}
+			}
+			errs = append(errs, w.writeBatch(batch))
+			batch = batch[:0]
+			reply <- errors.Join(errs...)
+		}
+	}
+}
+
+// writeBatch writes rows with retries, returns the error of failed rows
+func (w *Writer) writeBatch(rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// rows is reused by caller
+	pending := append([][]any(nil), rows...)
+	backoff := w.opts.backoff
+	for attempt := 0; ; attempt++ {
+		err := BulkInsert(context.Background(), w.db, w.table, w.columns, pending, WithChunkRows(w.opts.batchSize))
+		if err == nil {
+			w.succeed(len(pending))
+			return nil
+		}
+
+		var bulkErr *BulkInsertError
+		if !errors.As(err, &bulkErr) {
+			return w.fail(pending, err)
+		}
+
+		// retry rows failed by transient error, report the others
+		var retry [][]any
+		var retryErr error
+		var errs []error
+		inserted := len(pending)
+		for _, c := range bulkErr.Chunks {
+			chunkRows := pending[c.Offset : c.Offset+c.Rows]
+			inserted -= c.Rows
+			if attempt < w.opts.maxRetries && isTransient(c.Err) {
+				retry = append(retry, chunkRows...)
+				retryErr = c.Err
+			} else {
+				errs = append(errs, w.fail(chunkRows, c.Err))
+			}
+		}
+		w.succeed(inserted)
+
+		if len(retry) == 0 {
+			return errors.Join(errs...)
+		}
+		pending = retry
+
+		logRetry(w.opts.logger, w.table, attempt+1, len(retry), backoff, retryErr)
+		w.sleep(backoff)
+		backoff = min(2*backoff, w.opts.maxBackoff)
+	}
+}
+
+// sleep waits for d, or until the writer is closing
+func (w *Writer) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-w.closing:
+	}
+}
+
+func (w *Writer) isClosing() bool {
+	select {
+	case <-w.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *Writer) succeed(n int) {
+	w.written.Add(int64(n))
+	w.queued.Add(-int64(n))
+}
+
+func (w *Writer) fail(rows [][]any, err error) error {
+	w.failed.Add(int64(len(rows)))
+	w.queued.Add(-int64(len(rows)))
+
+	werr := &WriteError{Rows: rows, Err: err}
+	if w.opts.onError != nil {
+		w.opts.onError(werr)
+	}
+	select {
+	case w.errs <- werr:
+	default:
+	}
+	return werr
+}
+
+// isTransient reports whether err is caused by network or connection issue,
+// that the same request may succeed if retried. Context errors are not, though some are
+// net.Error.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.As(err, &netErr)
+}
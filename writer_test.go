@@ -0,0 +1,225 @@
+package openmldb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/4paradigm/openmldb-go-sdk/emulator"
+)
+
+func TestWriter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inserted []any
+		attempts int
+	)
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		var req queryReq
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Input == nil {
+			w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			// the first insert fails by connection reset, retried later
+			conn, _, err := w.(http.Hijacker).Hijack()
+			assert.NoError(t, err)
+			conn.Close()
+			return
+		}
+		if req.Input.Data[1] == "bad" {
+			w.Write([]byte(`{"code": -1, "msg": "insert failed"}`))
+			return
+		}
+		for _, v := range req.Input.Data {
+			inserted = append(inserted, v)
+		}
+		w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+	})
+
+	var handled []*WriteError
+	writer := NewWriter(db, "demo", []string{"c1", "c2"},
+		WithBatchSize(10),
+		WithFlushInterval(time.Hour),
+		WithRetry(2, time.Millisecond, time.Millisecond),
+		WithErrorHandler(func(err *WriteError) { handled = append(handled, err) }))
+
+	ctx := context.Background()
+	assert.NoError(t, writer.Write(ctx, []any{int64(1), "aa"}))
+	assert.NoError(t, writer.Flush(ctx))
+	assert.NoError(t, writer.Write(ctx, []any{int64(2), "bad"}))
+	assert.Error(t, writer.Write(ctx, []any{int64(3)}))
+	assert.Error(t, writer.Flush(ctx))
+	assert.NoError(t, writer.Write(ctx, []any{int64(3), "cc"}))
+	assert.NoError(t, writer.Close())
+
+	assert.Equal(t, []any{float64(1), "aa", float64(3), "cc"}, inserted)
+	assert.Equal(t, WriterStats{Written: 2, Failed: 1}, writer.Stats())
+	assert.Len(t, handled, 1)
+	assert.Equal(t, [][]any{{int64(2), "bad"}}, handled[0].Rows)
+	assert.EqualError(t, handled[0].Err, "execute error: insert failed")
+
+	werr, ok := <-writer.Errors()
+	assert.True(t, ok)
+	assert.Equal(t, handled[0], werr)
+	_, ok = <-writer.Errors()
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, writer.Write(ctx, []any{int64(4), "dd"}), ErrWriterClosed)
+}
+
+func TestWriterDropWhenFull(t *testing.T) {
+	block := make(chan struct{})
+	db := newTestDB(t, func(w http.ResponseWriter, r *http.Request) {
+		var req queryReq
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Input != nil {
+			<-block
+		}
+		w.Write([]byte(`{"code": 0, "msg": "ok"}`))
+	})
+
+	writer := NewWriter(db, "demo", nil,
+		WithBatchSize(1),
+		WithQueueSize(1),
+		WithQueueFullPolicy(Drop))
+
+	ctx := context.Background()
+	// the first row is taken by writer goroutine and blocked on insert
+	assert.NoError(t, writer.Write(ctx, []any{int64(1)}))
+	assert.Eventually(t, func() bool { return len(writer.queue) == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, writer.Write(ctx, []any{int64(2)}))
+	assert.ErrorIs(t, writer.Write(ctx, []any{int64(3)}), ErrQueueFull)
+	assert.Equal(t, WriterStats{Dropped: 1, Queued: 2}, writer.Stats())
+
+	close(block)
+	assert.NoError(t, writer.Close())
+	assert.Equal(t, WriterStats{Written: 2, Dropped: 1}, writer.Stats())
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(&ChunkError{Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}))
+	assert.True(t, isTransient(&url.Error{Op: "Post", Err: io.EOF}))
+	assert.False(t, isTransient(&ChunkError{Err: errors.New("execute error: insert failed")}))
+	assert.False(t, isTransient(&url.Error{Op: "Post", Err: context.DeadlineExceeded}))
+	assert.False(t, isTransient(&ChunkError{Err: context.Canceled}))
+}
+
+func TestWriterCloseDuringBackoff(t *testing.T) {
+	srv := emulator.NewServer()
+	defer srv.Close()
+
+	var attempts atomic.Int32
+	connector, err := NewConnector(&Config{Host: srv.Host(), DB: "test_db", Hooks: []Hook{HookFuncs{
+		BeforeFunc: func(ctx context.Context, req *Request) (context.Context, *Result, error) {
+			if strings.HasPrefix(req.SQL, "INSERT") {
+				attempts.Add(1)
+				return ctx, nil, io.ErrUnexpectedEOF
+			}
+			return ctx, nil, nil
+		},
+	}}})
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE t1 (c1 string)")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	w := NewWriter(db, "t1", nil, WithBatchSize(1), WithQueueSize(1), WithRetry(3, time.Hour, time.Hour))
+	require.NoError(t, w.Write(ctx, []any{"a"}))
+	require.Eventually(t, func() bool { return attempts.Load() == 1 }, 5*time.Second, time.Millisecond)
+	// queued while "a" waits for retry, and "c" blocked by the full queue
+	require.NoError(t, w.Write(ctx, []any{"b"}))
+	blocked := make(chan error, 1)
+	go func() { blocked <- w.Write(ctx, []any{"c"}) }()
+
+	start := time.Now()
+	err = w.Close()
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, ErrWriterClosed, <-blocked)
+	// retried without backoff
+	assert.Equal(t, int32(8), attempts.Load())
+	assert.Equal(t, WriterStats{Failed: 2}, w.Stats())
+}
+
+func TestWriterNull(t *testing.T) {
+	srv := emulator.NewServer()
+	defer srv.Close()
+	db, err := sql.Open("openmldb", srv.DSN("test_db"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE t1 (c1 string, c2 int)")
+	require.NoError(t, err)
+
+	var failed []*WriteError
+	w := NewWriter(db, "t1", []string{"c1", "c2"}, WithErrorHandler(func(e *WriteError) { failed = append(failed, e) }))
+	require.NoError(t, w.Write(context.Background(), []any{"aa", nil}))
+	require.NoError(t, w.Write(context.Background(), []any{nil, Null[int32]{}}))
+	require.NoError(t, w.Close())
+	assert.Empty(t, failed)
+	assert.Equal(t, WriterStats{Written: 2}, w.Stats())
+
+	var n int64
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM t1 WHERE c2 IS NULL").Scan(&n))
+	assert.Equal(t, int64(2), n)
+}
+
+func TestWriterLogRetry(t *testing.T) {
+	srv := emulator.NewServer()
+	defer srv.Close()
+
+	failed := false
+	connector, err := NewConnector(&Config{Host: srv.Host(), DB: "test_db", Hooks: []Hook{HookFuncs{
+		BeforeFunc: func(ctx context.Context, req *Request) (context.Context, *Result, error) {
+			if strings.HasPrefix(req.SQL, "INSERT") && !failed {
+				failed = true
+				return ctx, nil, io.ErrUnexpectedEOF
+			}
+			return ctx, nil, nil
+		},
+	}}})
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE t1 (c1 string)")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	w := NewWriter(db, "t1", nil, WithRetry(3, time.Millisecond, time.Millisecond), WithLogger(logger))
+	require.NoError(t, w.Write(context.Background(), []any{"aa"}))
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, int64(1), w.Stats().Written)
+	assert.Equal(t, "level=WARN msg=\"openmldb writer retry\" table=t1 attempt=1 rows=1 backoff=1ms error=\"unexpected EOF\"\n", buf.String())
+}